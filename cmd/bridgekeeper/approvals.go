@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"bridgekeeper/internal/hitl"
+	"bridgekeeper/internal/policy"
+)
+
+const approvalsUsage = `usage: bridgekeeper approvals <command> [flags]
+
+commands:
+  list [-all]              show remembered approvals for this workspace
+  revoke <id>...           forget remembered approvals
+  prune [-policy path]     drop expired approvals, and with -policy, approvals
+                           recorded under a different policy for this workspace
+`
+
+// runApprovals implements the "approvals" subcommand, which manages the
+// approvals remembered across sessions. It returns the process exit code.
+func runApprovals(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, approvalsUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("approvals "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	defaultPath, _ := hitl.DefaultMemoryPath()
+	file := fs.String("file", defaultPath, "approval memory file")
+	all := fs.Bool("all", false, "list approvals for every workspace")
+	policyPath := fs.String("policy", "", "policy YAML file or directory in effect for this workspace")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *file == "" {
+		fmt.Fprintln(stderr, "error: cannot determine the approval memory file; pass -file")
+		return 1
+	}
+
+	workspace, err := os.Getwd()
+	if err == nil {
+		workspace, err = filepath.Abs(workspace)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: cannot determine working directory: %v\n", err)
+		return 1
+	}
+	memory := hitl.NewMemory(*file, workspace, "")
+
+	switch args[0] {
+	case "list":
+		entries, err := memory.List()
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		listApprovals(stdout, entries, workspace, *all)
+	case "revoke":
+		if fs.NArg() == 0 {
+			fmt.Fprint(stderr, approvalsUsage)
+			return 2
+		}
+		status := 0
+		for _, id := range fs.Args() {
+			removed, err := memory.Revoke(id)
+			switch {
+			case err != nil:
+				fmt.Fprintf(stderr, "error: %v\n", err)
+				return 1
+			case !removed:
+				fmt.Fprintf(stderr, "no remembered approval %s\n", id)
+				status = 1
+			default:
+				fmt.Fprintf(stdout, "Revoked %s.\n", id)
+			}
+		}
+		return status
+	case "prune":
+		if *policyPath != "" {
+			pf, err := policy.LoadPath(*policyPath)
+			if err != nil {
+				fmt.Fprintf(stderr, "error: loading policy path: %v\n", err)
+				return 1
+			}
+			memory.PolicyHash = pf.Hash()
+		}
+		n, err := memory.Prune(*policyPath != "")
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Pruned %d remembered approvals.\n", n)
+	default:
+		fmt.Fprint(stderr, approvalsUsage)
+		return 2
+	}
+	return 0
+}
+
+func listApprovals(out io.Writer, entries []hitl.Remembered, workspace string, all bool) {
+	now := time.Now()
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	header := "ID\tTOOL\tRULE\tARGUMENTS\tUSES\tEXPIRES"
+	if all {
+		header += "\tWORKSPACE"
+	}
+	fmt.Fprintln(tw, header)
+
+	shown := 0
+	for _, entry := range entries {
+		if !all && entry.Workspace != workspace {
+			continue
+		}
+		expires := entry.Expires.Local().Format(time.DateOnly)
+		if entry.Expired(now) {
+			expires = "expired"
+		}
+		line := fmt.Sprintf("%s\t%s/%s\t%s\t%s\t%d\t%s", entry.ID, entry.Tool, entry.Action, entry.Rule, entry.Summary, entry.Uses, expires)
+		if all {
+			line += "\t" + entry.Workspace
+		}
+		fmt.Fprintln(tw, line)
+		shown++
+	}
+	if shown == 0 {
+		fmt.Fprintln(out, "No remembered approvals.")
+		return
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"bridgekeeper/internal/hitl"
+	"bridgekeeper/internal/policy"
+	"bridgekeeper/internal/redact"
+)
+
+// approverOptions carries the command-line settings for approval channels.
+type approverOptions struct {
+	addr         string
+	callbackAddr string // webhook callback listener; addr when empty
+	webhook      string
+	callback     string
+	secretEnv    string
+	spool        string
+	workspace    string // the spool directory must lie outside it
+	timeout      time.Duration
+	redactor     *redact.Redactor
+	grants       *hitl.Grants
+	remember     time.Duration // offered by the terminal approver; zero disables
+}
+
+// buildApprover returns the approver named by spec, a comma-separated list of
+// channels. Several channels are combined into a quorum that asks all of them
+// at once, or into a chain that asks them in order when mode is "chain". The
+// returned function releases every channel's resources.
+func buildApprover(spec, mode string, opts approverOptions) (hitl.Approver, func(), error) {
+	names := approverNames(spec)
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("no approver given")
+	}
+	if opts.callbackAddr == "" {
+		opts.callbackAddr = opts.addr
+	}
+	if slices.Contains(names, "web") && slices.Contains(names, "webhook") && sameFixedAddr(opts.addr, opts.callbackAddr) {
+		return nil, nil, fmt.Errorf("web and webhook approvers cannot both listen on %s; set -approver-callback-addr for the webhook callbacks", opts.addr)
+	}
+
+	var closers []func() error
+	closeAll := func() {
+		for _, c := range closers {
+			_ = c()
+		}
+	}
+
+	// Session grants and remembered approvals let one channel approve calls
+	// for later, which would bypass the other members of a quorum or chain.
+	if len(names) > 1 {
+		opts.grants = nil
+		opts.remember = 0
+	}
+
+	members := make([]hitl.Member, 0, len(names))
+	for _, name := range names {
+		approver, closer, err := newApprover(name, opts)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+		members = append(members, hitl.Member{Name: name, Approver: approver})
+	}
+	if len(members) == 1 {
+		return members[0].Approver, closeAll, nil
+	}
+
+	var (
+		approver hitl.Approver
+		err      error
+	)
+	switch mode {
+	case "quorum":
+		approver, err = hitl.NewQuorum(1, members...)
+	case "chain":
+		approver, err = hitl.NewChain(members...)
+	default:
+		err = fmt.Errorf("unknown approver mode %q (want quorum or chain)", mode)
+	}
+	if err != nil {
+		closeAll()
+		return nil, nil, err
+	}
+	return approver, closeAll, nil
+}
+
+// approverNames splits an -approver list into channel names.
+func approverNames(spec string) []string {
+	var names []string
+	for _, name := range strings.Split(spec, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// sameFixedAddr reports whether two listen addresses name the same fixed
+// port. Port 0 picks a free port on each listen, so it never collides.
+func sameFixedAddr(a, b string) bool {
+	_, port, err := net.SplitHostPort(a)
+	return err == nil && port != "0" && a == b
+}
+
+// approverShortfall lists the capabilities in pf that require more distinct
+// approvers than channels can supply. The mediator counts each channel once,
+// so their calls would always be denied.
+func approverShortfall(pf *policy.PolicyFile, channels int) []string {
+	var short []string
+	for _, c := range pf.Capabilities {
+		if c.Approval != nil && c.Approval.RequiredApprovers > channels {
+			short = append(short, fmt.Sprintf("capability %q requires %d approvers but %d approval channel(s) are configured; its calls will be denied", c.Name, c.Approval.RequiredApprovers, channels))
+		}
+	}
+	return short
+}
+
+// newApprover sets up a single approval channel.
+func newApprover(kind string, opts approverOptions) (hitl.Approver, func() error, error) {
+	switch kind {
+	case "web":
+		wa, err := hitl.NewWebApprover(opts.addr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot start web approver: %w", err)
+		}
+		wa.Redactor = opts.redactor
+		fmt.Fprintf(os.Stderr, "bridgekeeper: approve pending tool calls at %s\n", wa.URL())
+		return wa, wa.Close, nil
+	case "webhook":
+		wh, err := hitl.NewWebhookApprover(opts.webhook, opts.callback, []byte(os.Getenv(opts.secretEnv)))
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot set up webhook approver: %w (secret read from $%s)", err, opts.secretEnv)
+		}
+		if err := wh.Listen(opts.callbackAddr); err != nil {
+			return nil, nil, fmt.Errorf("cannot start webhook callback server: %w", err)
+		}
+		wh.Redactor = opts.redactor
+		wh.Timeout = opts.timeout
+		fmt.Fprintf(os.Stderr, "bridgekeeper: waiting for webhook callbacks at %s\n", wh.CallbackURL)
+		return wh, wh.Close, nil
+	case "spool":
+		sa, err := hitl.NewSpoolApprover(opts.spool, opts.workspace)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot set up spool approver: %w", err)
+		}
+		sa.Redactor = opts.redactor
+		sa.Timeout = opts.timeout
+		fmt.Fprintf(os.Stderr, "bridgekeeper: spooling approval requests to %s\n", sa.Dir)
+		return sa, nil, nil
+	case "terminal":
+		ta, err := hitl.NewTerminalApprover()
+		if err != nil {
+			// If we can't open /dev/tty (e.g. in a pipe), fall back to auto-deny.
+			fmt.Fprintf(os.Stderr, "warning: cannot open terminal for approval, falling back to auto-deny: %v\n", err)
+			return &hitl.AutoDenier{}, nil, nil
+		}
+		ta.Grants = opts.grants
+		ta.RememberFor = opts.remember
+		ta.Redactor = opts.redactor
+		return ta, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown approver %q (want terminal, web, webhook, or spool)", kind)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"bridgekeeper/internal/canary"
+)
+
+const canaryUsage = `usage: bridgekeeper canary <command> [flags]
+
+commands:
+  plant [-kind k] <path>...           write a new honeytoken file at each path
+  plant -env NAME [-kind k] <path>... append a canary variable to each env file
+  list [-all]                         show canaries planted in this workspace
+  remove <id>...                      forget canaries; their files are left alone
+`
+
+// runCanary implements the "canary" subcommand, which plants fake
+// credentials and manages the registry the runtime watches for them. It
+// returns the process exit code.
+func runCanary(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, canaryUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("canary "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	defaultPath, _ := canary.DefaultPath()
+	file := fs.String("file", defaultPath, "canary registry file")
+	kind := fs.String("kind", canary.AWS, "kind of credential to imitate: "+strings.Join(canary.Kinds(), ", "))
+	env := fs.String("env", "", "append the canary to an env file as this variable instead of writing a new file")
+	all := fs.Bool("all", false, "list canaries for every workspace")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *file == "" {
+		fmt.Fprintln(stderr, "error: cannot determine the canary registry file; pass -file")
+		return 1
+	}
+
+	workspace, err := os.Getwd()
+	if err == nil {
+		workspace, err = filepath.Abs(workspace)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: cannot determine working directory: %v\n", err)
+		return 1
+	}
+	registry, err := canary.Open(*file)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "plant":
+		if fs.NArg() == 0 {
+			fmt.Fprint(stderr, canaryUsage)
+			return 2
+		}
+		for _, path := range fs.Args() {
+			path, err := filepath.Abs(path)
+			if err != nil {
+				fmt.Fprintf(stderr, "error: %v\n", err)
+				return 1
+			}
+			var token canary.Token
+			if *env != "" {
+				token, err = registry.PlantEnv(path, workspace, *env, *kind)
+			} else {
+				token, err = registry.PlantFile(path, workspace, *kind)
+			}
+			if err != nil {
+				fmt.Fprintf(stderr, "error: %v\n", err)
+				return 1
+			}
+			fmt.Fprintf(stdout, "Planted %s canary %s in %s.\n", token.Kind, token.ID, token.Location)
+		}
+	case "list":
+		listCanaries(stdout, registry.List(), workspace, *all)
+	case "remove":
+		if fs.NArg() == 0 {
+			fmt.Fprint(stderr, canaryUsage)
+			return 2
+		}
+		status := 0
+		for _, id := range fs.Args() {
+			removed, err := registry.Remove(id)
+			switch {
+			case err != nil:
+				fmt.Fprintf(stderr, "error: %v\n", err)
+				return 1
+			case !removed:
+				fmt.Fprintf(stderr, "no canary %s\n", id)
+				status = 1
+			default:
+				fmt.Fprintf(stdout, "Removed %s.\n", id)
+			}
+		}
+		return status
+	default:
+		fmt.Fprint(stderr, canaryUsage)
+		return 2
+	}
+	return 0
+}
+
+func listCanaries(out io.Writer, tokens []canary.Token, workspace string, all bool) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	header := "ID\tKIND\tLOCATION\tPLANTED"
+	if all {
+		header += "\tWORKSPACE"
+	}
+	fmt.Fprintln(tw, header)
+
+	shown := 0
+	for _, token := range tokens {
+		if !all && token.Workspace != workspace {
+			continue
+		}
+		line := fmt.Sprintf("%s\t%s\t%s\t%s", token.ID, token.Kind, token.Location, token.Created.Local().Format(time.DateOnly))
+		if all {
+			line += "\t" + token.Workspace
+		}
+		fmt.Fprintln(tw, line)
+		shown++
+	}
+	if shown == 0 {
+		fmt.Fprintln(out, "No canaries planted.")
+		return
+	}
+	_ = tw.Flush()
+}
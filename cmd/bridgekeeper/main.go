@@ -2,17 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	osuser "os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 
 	bkagent "bridgekeeper/internal/agent"
 	"bridgekeeper/internal/audit"
+	"bridgekeeper/internal/canary"
 	"bridgekeeper/internal/console"
 	"bridgekeeper/internal/hitl"
 	"bridgekeeper/internal/policy"
@@ -20,7 +24,9 @@ import (
 	"bridgekeeper/internal/runtime"
 	"bridgekeeper/internal/sandbox"
 	"bridgekeeper/internal/tools"
+	"bridgekeeper/internal/types"
 
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 )
 
@@ -33,6 +39,85 @@ func deferredShutdown() {
 	}
 }
 
+// atExit holds cleanups that must run however main ends; deferred calls are
+// skipped by os.Exit.
+var atExit []func()
+
+// cleanup runs the atExit cleanups, newest first, once.
+func cleanup() {
+	for len(atExit) > 0 {
+		last := atExit[len(atExit)-1]
+		atExit = atExit[:len(atExit)-1]
+		last()
+	}
+}
+
+// exit runs the atExit cleanups and exits with code.
+func exit(code int) {
+	cleanup()
+	os.Exit(code)
+}
+
+// fatal logs v and exits through exit.
+func fatal(v ...any) {
+	log.Print(v...)
+	exit(1)
+}
+
+// osUserName returns the login name of the OS user running the process. It
+// falls back to the numeric user ID, never to $USER, since the principal's
+// roles are granted by this name.
+func osUserName() string {
+	if current, err := osuser.Current(); err == nil && current.Username != "" {
+		return current.Username
+	}
+	return strconv.Itoa(os.Getuid())
+}
+
+// loadRedactor builds the redactor from rulesPath, or when that is empty from
+// redact.yaml next to the policy if one exists, or else from the built-in
+// rules.
+func loadRedactor(policyPath, rulesPath string) (*redact.Redactor, error) {
+	if rulesPath == "" {
+		dir := policyPath
+		if info, err := os.Stat(policyPath); err == nil && !info.IsDir() {
+			dir = filepath.Dir(policyPath)
+		}
+		candidate := filepath.Join(dir, "redact.yaml")
+		if _, err := os.Stat(candidate); err != nil {
+			return redact.New(), nil
+		}
+		rulesPath = candidate
+	}
+	rf, err := redact.LoadRules(rulesPath)
+	if err != nil {
+		return nil, err
+	}
+	return redact.NewFromRules(rf)
+}
+
+// loadSensitivity applies a sensitivity map to validator: the file at path,
+// else sensitivity.yaml next to the policy when present. Without either the
+// validator keeps its built-in map.
+func loadSensitivity(validator *sandbox.Validator, policyPath, path string) error {
+	if path == "" {
+		dir := policyPath
+		if info, err := os.Stat(policyPath); err == nil && !info.IsDir() {
+			dir = filepath.Dir(policyPath)
+		}
+		candidate := filepath.Join(dir, "sensitivity.yaml")
+		if _, err := os.Stat(candidate); err != nil {
+			return nil
+		}
+		path = candidate
+	}
+	m, err := sandbox.LoadSensitivity(path)
+	if err != nil {
+		return err
+	}
+	return validator.SetSensitivity(m)
+}
+
 func loadGeminiAPIKey() string {
 	err := godotenv.Load()
 	if err != nil {
@@ -41,13 +126,12 @@ func loadGeminiAPIKey() string {
 
 	apiKey := os.Getenv("GEMINI_API_KEY")
 	if apiKey == "" {
-		log.Fatal("GEMINI_API_KEY is not set.")
+		fatal("GEMINI_API_KEY is not set.")
 	}
 	return apiKey
 }
 
-func runGeminiModel(mediator *runtime.Mediator, registry *tools.Registry, pf *policy.PolicyFile) {
-	ctx := context.Background()
+func runGeminiModel(ctx context.Context, mediator *runtime.Mediator, registry *tools.Registry, pf *policy.PolicyFile) {
 	var conciseMode bool = true
 
 	apiKey := loadGeminiAPIKey()
@@ -56,7 +140,7 @@ func runGeminiModel(mediator *runtime.Mediator, registry *tools.Registry, pf *po
 	agent := bkagent.NewGeminiAgent(ctx, apiKey, mediator, registry)
 	session, err := console.NewSession(os.Stdin, os.Stdout)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
 	printGeminiCommands(agent)
@@ -68,7 +152,7 @@ func runGeminiModel(mediator *runtime.Mediator, registry *tools.Registry, pf *po
 				fmt.Println("\nGoodbye!")
 				return
 			}
-			log.Fatal(err)
+			fatal(err)
 		}
 
 		input = strings.TrimSpace(input)
@@ -95,6 +179,9 @@ func runGeminiModel(mediator *runtime.Mediator, registry *tools.Registry, pf *po
 			case "/policy":
 				fmt.Println(policy.FormatPolicy(pf))
 
+			case "/grants":
+				manageGrants(mediator.Grants, parts)
+
 			case "/concise":
 				toggleGeminiConciseness(&conciseMode)
 
@@ -122,6 +209,10 @@ func getModelResponse(agent *bkagent.GeminiAgent, ctx context.Context, input str
 
 	// Uses the new autonomous execution loop
 	response, err := agent.SendMessageWithTools(ctx, input, conciseMode)
+	if errors.Is(err, hitl.ErrStopTurn) {
+		fmt.Println("Turn stopped by approver.")
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -138,12 +229,38 @@ func printGeminiCommands(agent *bkagent.GeminiAgent) {
 	fmt.Println("  /list          - List available models")
 	fmt.Println("  /model <name>  - Select a model (e.g., /model gemini-1.5-pro)")
 	fmt.Println("  /policy        - Show the current loaded policy")
+	fmt.Println("  /grants        - List session approvals (/grants revoke <id>, /grants clear)")
 	fmt.Println("  /concise       - Toggle the verboseness of the Model")
 	fmt.Println("  <your prompt>  - Chat with the AI (Auto-Tools Enabled)")
 	fmt.Println("  /exit          - Quit")
 	fmt.Println("-------------------------------")
 }
 
+func manageGrants(grants *hitl.Grants, parts []string) {
+	switch {
+	case len(parts) == 1:
+		list := grants.List()
+		if len(list) == 0 {
+			fmt.Println("No session grants.")
+			return
+		}
+		for _, grant := range list {
+			fmt.Println(grant)
+		}
+	case parts[1] == "revoke" && len(parts) == 3:
+		id, err := strconv.Atoi(strings.TrimPrefix(parts[2], "#"))
+		if err != nil || !grants.Revoke(id) {
+			fmt.Printf("No grant %s.\n", parts[2])
+			return
+		}
+		fmt.Printf("Revoked grant #%d.\n", id)
+	case parts[1] == "clear" && len(parts) == 2:
+		fmt.Printf("Cleared %d grants.\n", grants.Clear())
+	default:
+		fmt.Println("Usage: /grants [revoke <id> | clear]")
+	}
+}
+
 func fetchGeminiModels(agent *bkagent.GeminiAgent, ctx context.Context) {
 	fmt.Println("Fetching available models...")
 	models, err := agent.ListModels(ctx)
@@ -176,11 +293,59 @@ func toggleGeminiConciseness(conciseMode *bool) {
 
 // ///// MAIN ///////
 func main() {
+	// A confined git, go or cargo is started through this binary; it never
+	// returns in that case.
+	sandbox.ExecConfined()
+
+	if len(os.Args) > 1 && os.Args[1] == "approvals" {
+		os.Exit(runApprovals(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "canary" {
+		os.Exit(runCanary(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	policyPath := flag.String("policy", "policies", "path to policy YAML file or directory")
+	redactRules := flag.String("redact-rules", "", "redaction rules YAML (default: redact.yaml next to the policy, if present)")
+	sensitivityMap := flag.String("sensitivity", "", "path sensitivity map YAML (default: sensitivity.yaml next to the policy, if present, else built-in)")
+	vault := flag.Bool("vault", false, "show the model placeholders like ⟦SECRET_1⟧ instead of [REDACTED], resolved again only in workspace file writes a person approves")
 	logFile := flag.String("log-file", "", "audit log file path (default: stderr)")
 	verbose := flag.Bool("verbose", false, "enable verbose output")
-	noHITL := flag.Bool("no-hitl", false, "disable human-in-the-loop approval (auto-approve all)")
+	noHITL := flag.Bool("no-hitl", false, "disable human-in-the-loop approval (auto-approve all, except calls whose policy requires more than one approver, which are denied)")
+	approverKind := flag.String("approver", "terminal", "how ask decisions are approved: terminal, web, webhook, spool, or a comma-separated list of them")
+	approverMode := flag.String("approver-mode", "quorum", "how a list of approvers is combined: quorum (ask all at once) or chain (ask in order)")
+	approverAddr := flag.String("approver-addr", "127.0.0.1:0", "listen address for the web approver, and for webhook callbacks unless -approver-callback-addr is set")
+	approverCallbackAddr := flag.String("approver-callback-addr", "", "listen address for webhook callbacks (default: -approver-addr); needed with -approver web,webhook on a fixed port")
+	approverTimeout := flag.Duration("approver-timeout", 0, "how long webhook and spool approvers wait before denying (default 5m)")
+	approverWebhook := flag.String("approver-webhook", "", "URL the webhook approver posts approval requests to")
+	approverCallback := flag.String("approver-callback-url", "", "externally reachable callback URL sent to the webhook (default: derived from the callback listen address)")
+	approverSecretEnv := flag.String("approver-secret-env", "BRIDGEKEEPER_WEBHOOK_SECRET", "environment variable holding the webhook signing secret")
+	approverSpool := flag.String("approver-spool", "", "directory the spool approver writes requests to and polls for responses")
+	defaultMemory, _ := hitl.DefaultMemoryPath()
+	approvalMemory := flag.String("approval-memory", defaultMemory, "file of approvals remembered across sessions (empty disables)")
+	defaultCanaries, _ := canary.DefaultPath()
+	canaryRegistry := flag.String("canaries", defaultCanaries, "canary registry written by \"bridgekeeper canary plant\" (empty disables)")
+	rememberFor := flag.Duration("remember-for", hitl.DefaultRememberFor, "how long the terminal approver's \"remember\" answer lasts")
 	mode := flag.String("mode", "", "mode to run the agent in (ollama or gemini)")
+	landlock := flag.Bool("landlock", true, "confine git, go and cargo to the workspace and toolchain with Landlock where the kernel supports it")
+	decisionCache := flag.Int("decision-cache", 0, "cache up to N policy decisions for identical calls (0 disables)")
+	user := flag.String("user", "", "principal user name; must be the OS user running bridgekeeper (default: the OS user)")
+	var requestedRoles []string
+	flag.Func("role", "act with only this role of those the policy grants the OS user (repeatable; default: all of them)", func(raw string) error {
+		if strings.TrimSpace(raw) == "" {
+			return fmt.Errorf("role must not be empty")
+		}
+		requestedRoles = append(requestedRoles, strings.TrimSpace(raw))
+		return nil
+	})
+	labels := map[string]string{}
+	flag.Func("label", "deployment label key=value visible to policy when conditions (repeatable)", func(raw string) error {
+		key, value, ok := strings.Cut(raw, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("label must be key=value")
+		}
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		return nil
+	})
 	flag.Parse()
 
 	pf, err := policy.LoadPath(*policyPath)
@@ -188,7 +353,22 @@ func main() {
 		fmt.Fprintf(os.Stderr, "error: loading policy path: %v\n", err)
 		os.Exit(1)
 	}
-	policyEngine := policy.NewEngine(pf)
+	// The principal is the OS user, with the roles the policy grants it;
+	// the flags can only confirm or narrow that, never claim more.
+	osUser := osUserName()
+	if *user != "" && *user != osUser {
+		fmt.Fprintf(os.Stderr, "error: cannot act as user %q: the principal is the OS user %q\n", *user, osUser)
+		os.Exit(1)
+	}
+	roles, err := pf.RolesFor(osUser, requestedRoles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	policyEngine := policy.NewEngine(pf, policy.WithDecisionCache(*decisionCache))
+	for _, warning := range policyEngine.Warnings() {
+		fmt.Fprintf(os.Stderr, "warning: policy: %s\n", warning)
+	}
 
 	// Set up audit log writer.
 	var auditWriter *os.File
@@ -220,21 +400,63 @@ func main() {
 		fmt.Fprintf(os.Stderr, "error: cannot initialize sandbox validator: %v\n", err)
 		os.Exit(1)
 	}
+	if err := loadSensitivity(validator, *policyPath, *sensitivityMap); err != nil {
+		fmt.Fprintf(os.Stderr, "error: loading sensitivity map: %v\n", err)
+		os.Exit(1)
+	}
+	validator.ConfineSubprocesses = *landlock
+	// Subprocesses leave files in the scratch directory; remove it on every
+	// way out, early exits included.
+	atExit = append(atExit, func() {
+		if err := validator.RemoveScratch(); err != nil {
+			log.Printf("shutdown %v", err)
+		}
+	})
+	defer cleanup()
 	registry := tools.NewRegistry(workspaceRoot, validator)
 
 	// Set up approver.
+	redactor, err := loadRedactor(*policyPath, *redactRules)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: loading redaction rules: %v\n", err)
+		exit(1)
+	}
+	auditLogger.SetRedactor(redactor)
+	grants := hitl.NewGrants(auditLogger)
+	if *approvalMemory == "" {
+		*rememberFor = 0
+	}
 	var approver runtime.Approver
 	if *noHITL {
+		// Auto-approval is a single channel too: calls that need several
+		// approvers are still denied.
+		for _, warning := range approverShortfall(pf, 1) {
+			fmt.Fprintf(os.Stderr, "warning: -no-hitl: %s\n", warning)
+		}
 		approver = &hitl.AutoApprover{}
 	} else {
-		ta, err := hitl.NewTerminalApprover()
+		for _, warning := range approverShortfall(pf, len(approverNames(*approverKind))) {
+			fmt.Fprintf(os.Stderr, "warning: -approver %s: %s\n", *approverKind, warning)
+		}
+		built, closeApprover, err := buildApprover(*approverKind, *approverMode, approverOptions{
+			addr:         *approverAddr,
+			callbackAddr: *approverCallbackAddr,
+			webhook:      *approverWebhook,
+			callback:     *approverCallback,
+			secretEnv:    *approverSecretEnv,
+			spool:        *approverSpool,
+			workspace:    workspaceRoot,
+			timeout:      *approverTimeout,
+			redactor:     redactor,
+			grants:       grants,
+			remember:     *rememberFor,
+		})
 		if err != nil {
-			// If we can't open /dev/tty (e.g. in a pipe), fall back to auto-deny.
-			fmt.Fprintf(os.Stderr, "warning: cannot open terminal for approval, falling back to auto-deny: %v\n", err)
-			approver = &hitl.AutoDenier{}
-		} else {
-			approver = ta
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
 		}
+		defer closeApprover()
+		approver = built
 	}
 
 	// Set up signal handling.
@@ -247,21 +469,69 @@ func main() {
 		<-sigCh
 		cancel()
 	}()
+
+	ctx = policy.WithEvalContext(ctx, policy.EvalContext{
+		Mode:   strings.ToLower(*mode),
+		TTY:    console.IsTerminal(os.Stdin, os.Stdout),
+		Env:    policy.EnvFromOS(),
+		Labels: labels,
+		Principal: types.Principal{
+			User:    osUser,
+			Agent:   strings.ToLower(*mode),
+			Session: uuid.NewString(),
+			Roles:   roles,
+		},
+	})
+	var memory *hitl.Memory
+	if *approvalMemory != "" {
+		memory = hitl.NewMemory(*approvalMemory, workspaceRoot, pf.Hash())
+		memory.Redactor = redactor
+		memory.Audit = auditLogger
+	}
 	mediator := &runtime.Mediator{
 		Policy:   policyEngine,
 		Approver: approver,
+		Grants:   grants,
+		Memory:   memory,
 		Audit:    auditLogger,
 		Sandbox:  validator,
-		Redactor: redact.New(),
+		Redactor: redactor,
+	}
+	if *vault {
+		mediator.Vault = redact.NewVault()
+	}
+	if *canaryRegistry != "" {
+		canaries, err := canary.Open(*canaryRegistry)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: loading canary registry: %v\n", err)
+			exit(1)
+		}
+		mediator.Canaries = canaries
 	}
 	toolchain := runtimeVersionTools(registry)
 
 	if *mode == "" {
 		fmt.Println("Invalid selection please select Gemini or Ollama with --mode flag.")
-		os.Exit(1)
+		exit(1)
 	}
 
-	auditLogger.Log(audit.Info, "runtime_started", map[string]any{"mode": *mode})
+	landlockABI := validator.ConfinementABI()
+	auditLogger.Log(audit.Info, "runtime_started", map[string]any{
+		"mode":         *mode,
+		"principal":    policy.EvalContextFrom(ctx).Principal,
+		"landlock_abi": landlockABI,
+	})
+	switch {
+	case landlockABI > 0:
+		if *verbose {
+			fmt.Fprintf(os.Stderr, "bridgekeeper: subprocesses confined by Landlock ABI v%d\n", landlockABI)
+		}
+	case *landlock:
+		auditLogger.Log(audit.Warning, "subprocess_confinement_unavailable", map[string]any{
+			"reason": "landlock is not supported by this kernel",
+		})
+		fmt.Fprintln(os.Stderr, "bridgekeeper: warning: Landlock is not supported by this kernel; subprocesses run with full filesystem access")
+	}
 	if *verbose {
 		fmt.Fprintf(os.Stderr, "bridgekeeper: workspace root %s\n", workspaceRoot)
 	}
@@ -273,7 +543,7 @@ func main() {
 		// This just runs through a list of prompts for testing
 
 		if err := runtime.Initialize(11434); nil != err {
-			log.Fatalf("Could not initialize: %s", err)
+			fatal("Could not initialize: ", err)
 		}
 
 		// Call the anonymous function once main exits scope
@@ -300,11 +570,13 @@ func main() {
 	case "gemini", "Gemini":
 		/////// GEMINI ///////
 		// This actually runs as a chat
-		runGeminiModel(mediator, registry, pf)
+		// The chat loop outlives individual signals, so keep the evaluation
+		// context but drop cancellation.
+		runGeminiModel(context.WithoutCancel(ctx), mediator, registry, pf)
 
 	default:
 		fmt.Fprintf(os.Stderr, "Usage: %s --mode <ollama|gemini>\n", os.Args[0])
-		os.Exit(1)
+		exit(1)
 	}
 }
 
@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"time"
 
 	"bridgekeeper/internal/policy"
 	"bridgekeeper/internal/types"
@@ -26,8 +28,51 @@ type evalOutput struct {
 func main() {
 	policyPath := flag.String("policy", "policies", "path to policy YAML file or directory")
 	inputPath := flag.String("input", "-", "input NDJSON path, or '-' for stdin")
+	mode := flag.String("mode", "", "runtime mode to evaluate when conditions against")
+	model := flag.String("model", "", "model name to evaluate when conditions against")
+	tty := flag.Bool("tty", false, "evaluate as if an interactive terminal is attached")
+	now := flag.String("now", "", "RFC3339 timestamp to evaluate time windows at (default: current time)")
+	user := flag.String("user", "", "principal user to evaluate principal selectors against")
+	agentName := flag.String("agent", "", "principal agent name")
+	session := flag.String("session", "", "principal session ID")
+	var roles []string
+	flag.Func("role", "role held by the principal (repeatable)", func(raw string) error {
+		roles = append(roles, strings.TrimSpace(raw))
+		return nil
+	})
+	labels := map[string]string{}
+	flag.Func("label", "deployment label key=value (repeatable)", func(raw string) error {
+		key, value, ok := strings.Cut(raw, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return errors.New("label must be key=value")
+		}
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		return nil
+	})
 	flag.Parse()
 
+	ec := policy.EvalContext{
+		Mode:   *mode,
+		TTY:    *tty,
+		Model:  *model,
+		Env:    policy.EnvFromOS(),
+		Labels: labels,
+		Principal: types.Principal{
+			User:    *user,
+			Agent:   *agentName,
+			Session: *session,
+			Roles:   roles,
+		},
+	}
+	if *now != "" {
+		parsed, err := time.Parse(time.RFC3339, *now)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: parsing -now: %v\n", err)
+			os.Exit(1)
+		}
+		ec.Now = parsed
+	}
+
 	pf, err := policy.LoadPath(*policyPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: loading policy path: %v\n", err)
@@ -35,6 +80,9 @@ func main() {
 	}
 
 	eng := policy.NewEngine(pf)
+	for _, warning := range eng.Warnings() {
+		fmt.Fprintf(os.Stderr, "warning: policy: %s\n", warning)
+	}
 
 	in, closeFn, err := openInput(*inputPath)
 	if err != nil {
@@ -45,7 +93,7 @@ func main() {
 		defer closeFn()
 	}
 
-	parseErrors, err := run(context.Background(), in, os.Stdout, eng)
+	parseErrors, err := run(policy.WithEvalContext(context.Background(), ec), in, os.Stdout, eng)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: evaluating input: %v\n", err)
 		os.Exit(1)
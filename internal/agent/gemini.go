@@ -7,6 +7,7 @@ import (
 	"math/rand/v2"
 	"strings"
 
+	"bridgekeeper/internal/policy"
 	"bridgekeeper/internal/runtime"
 	"bridgekeeper/internal/tools"
 	"bridgekeeper/internal/types"
@@ -47,6 +48,8 @@ func (agent *GeminiAgent) CurrentModel() string {
 }
 
 func (agent *GeminiAgent) SendMessageWithTools(ctx context.Context, prompt string, conciseMode bool) (string, error) {
+	ctx = policy.WithModel(ctx, agent.currentModel)
+
 	if agent.chatSession == nil || agent.isConcise != conciseMode {
 		config := agent.getChatConfig(conciseMode)
 		chat, err := agent.client.Chats.Create(ctx, agent.currentModel, config, nil)
@@ -85,6 +88,9 @@ func (agent *GeminiAgent) SendMessageWithTools(ctx context.Context, prompt strin
 					return agent.executeTool(ctx, funcCall.Name, args)
 				})
 				if err != nil {
+					// The chat now holds a function call with no response, which
+					// the API rejects on the next turn, so start a fresh chat.
+					agent.chatSession = nil
 					return "", err
 				}
 
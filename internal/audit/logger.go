@@ -15,10 +15,11 @@ const (
 	Info
 	Warning
 	Error
+	Critical // a likely attack in progress, such as a canary token being moved
 )
 
 func (s Severity) String() string {
-	return [...]string{"DEBUG", "INFO", "WARN", "ERROR"}[s]
+	return [...]string{"DEBUG", "INFO", "WARN", "ERROR", "CRITICAL"}[s]
 }
 
 // Event is a structured audit record written as JSONL.
@@ -29,11 +30,18 @@ type Event struct {
 	Fields   map[string]any `json:"fields,omitempty"`
 }
 
+// Redactor masks secrets within an event's fields. The tool named by the
+// event's "tool" field, if any, selects tool-specific rules.
+type Redactor interface {
+	RedactValueFor(tool string, v any) any
+}
+
 // Logger writes structured audit events to an injected writer.
 type Logger struct {
 	mu       sync.Mutex
 	out      io.Writer
 	minLevel Severity
+	redactor Redactor
 }
 
 // NewLogger creates a structured logger. A nil writer produces a no-op logger.
@@ -44,6 +52,14 @@ func NewLogger(out io.Writer, minLevel Severity) *Logger {
 	}
 }
 
+// SetRedactor makes the logger pass every field of every event through r
+// before writing it. A nil r writes fields as given.
+func (l *Logger) SetRedactor(r Redactor) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redactor = r
+}
+
 // Log emits a JSONL audit event. Errors are intentionally ignored because audit
 // failures must not crash the runtime.
 func (l *Logger) Log(severity Severity, message string, fields map[string]any) {
@@ -51,6 +67,13 @@ func (l *Logger) Log(severity Severity, message string, fields map[string]any) {
 		return
 	}
 
+	l.mu.Lock()
+	redactor := l.redactor
+	l.mu.Unlock()
+	if redactor != nil && len(fields) > 0 {
+		fields = redactFields(redactor, fields)
+	}
+
 	event := Event{
 		Time:     time.Now().UTC().Format(time.RFC3339Nano),
 		Severity: severity.String(),
@@ -67,3 +90,24 @@ func (l *Logger) Log(severity Severity, message string, fields map[string]any) {
 	defer l.mu.Unlock()
 	_, _ = l.out.Write(append(data, '\n'))
 }
+
+// redactFields redacts a copy of fields. The fields are first reduced to
+// plain JSON values, so strings inside structs and typed slices are redacted
+// as well; fields that cannot be encoded are dropped rather than written raw.
+func redactFields(r Redactor, fields map[string]any) map[string]any {
+	unencodable := map[string]any{"error": "audit fields could not be encoded for redaction"}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return unencodable
+	}
+	var plain map[string]any
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return unencodable
+	}
+	tool, _ := fields["tool"].(string)
+	redacted, ok := r.RedactValueFor(tool, plain).(map[string]any)
+	if !ok {
+		return unencodable
+	}
+	return redacted
+}
@@ -0,0 +1,329 @@
+// Package canary plants fake credentials, or canary tokens, in a workspace
+// and recognises them when they turn up again.
+//
+// A canary looks like a real secret of its kind but authorises nothing, and
+// each value is random, so it cannot appear by chance. Nothing legitimate
+// ever needs to read one back, send one over the network or copy one into
+// another file: seeing a canary value in a tool call or its output means the
+// agent is moving secrets it found.
+package canary
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of canary, each shaped like the credential it imitates.
+const (
+	AWS      = "aws"      // an AWS access key ID, AKIA...
+	GitHub   = "github"   // a GitHub personal access token, ghp_...
+	OpenAI   = "openai"   // an OpenAI API key, sk-...
+	Password = "password" // a random password
+)
+
+// kinds describes each kind of canary: the variable it is planted under and
+// how its value is generated.
+var kinds = map[string]struct {
+	variable string
+	generate func() (string, error)
+}{
+	AWS:      {"AWS_ACCESS_KEY_ID", func() (string, error) { return randomString("AKIA", "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567", 16) }},
+	GitHub:   {"GITHUB_TOKEN", func() (string, error) { return randomString("ghp_", alphanumeric, 36) }},
+	OpenAI:   {"OPENAI_API_KEY", func() (string, error) { return randomString("sk-", alphanumeric, 48) }},
+	Password: {"DB_PASSWORD", func() (string, error) { return randomString("", alphanumeric, 20) }},
+}
+
+const alphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// Kinds returns the supported kinds of canary, sorted.
+func Kinds() []string {
+	out := make([]string, 0, len(kinds))
+	for kind := range kinds {
+		out = append(out, kind)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Token is one planted canary.
+type Token struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	Location  string    `json:"location"`            // the file planted in, with "#NAME" for a variable in an env file
+	Workspace string    `json:"workspace,omitempty"` // the workspace the file belongs to
+	Created   time.Time `json:"created"`
+}
+
+// registryFile is the on-disk form of a Registry.
+type registryFile struct {
+	Version int     `json:"version"`
+	Tokens  []Token `json:"tokens"`
+}
+
+// Registry tracks planted canaries. It is kept as a JSON file with
+// owner-only permissions, outside the workspace so that the agent cannot read
+// the list, and loaded once when opened. A Registry with an empty Path lives
+// in memory only. It is safe for concurrent use within a process.
+type Registry struct {
+	Path string
+
+	mu     sync.Mutex
+	tokens []Token
+}
+
+// NewRegistry returns an empty registry kept in memory only.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Open loads the registry kept at path. A missing file is an empty registry.
+func Open(path string) (*Registry, error) {
+	r := &Registry{Path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f registryFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse canary registry %s: %w", path, err)
+	}
+	if f.Version != 1 {
+		return nil, fmt.Errorf("canary registry %s has unsupported version %d", path, f.Version)
+	}
+	r.tokens = f.Tokens
+	return r, nil
+}
+
+// DefaultPath returns the registry under the user's config directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bridgekeeper", "canaries.json"), nil
+}
+
+// Generate returns a new canary of kind without planting it anywhere.
+func (r *Registry) Generate(kind string) (Token, error) {
+	spec, ok := kinds[kind]
+	if !ok {
+		return Token{}, fmt.Errorf("unknown canary kind %q (want one of %s)", kind, strings.Join(Kinds(), ", "))
+	}
+	value, err := spec.generate()
+	if err != nil {
+		return Token{}, err
+	}
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return Token{}, err
+	}
+	return Token{
+		ID:      hex.EncodeToString(id),
+		Kind:    kind,
+		Value:   value,
+		Created: time.Now().UTC(),
+	}, nil
+}
+
+// PlantFile writes a new honeytoken file at path holding a canary of kind,
+// in .env form, and records it. An existing file is never overwritten.
+func (r *Registry) PlantFile(path, workspace, kind string) (Token, error) {
+	token, err := r.Generate(kind)
+	if err != nil {
+		return Token{}, err
+	}
+	line := kinds[kind].variable + "=" + token.Value + "\n"
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return Token{}, err
+	}
+	if _, err := f.WriteString(line); err != nil {
+		f.Close()
+		return Token{}, err
+	}
+	if err := f.Close(); err != nil {
+		return Token{}, err
+	}
+	token.Location, token.Workspace = path, workspace
+	return token, r.add(token)
+}
+
+// PlantEnv appends a canary of kind to the env file at path as variable
+// name, creating the file if needed, and records it. The name defaults to
+// the usual variable for the kind, such as GITHUB_TOKEN.
+func (r *Registry) PlantEnv(path, workspace, name, kind string) (Token, error) {
+	token, err := r.Generate(kind)
+	if err != nil {
+		return Token{}, err
+	}
+	if name == "" {
+		name = kinds[kind].variable
+	}
+	prefix := ""
+	if data, err := os.ReadFile(path); err == nil && len(data) > 0 && data[len(data)-1] != '\n' {
+		prefix = "\n"
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return Token{}, err
+	}
+	if _, err := f.WriteString(prefix + name + "=" + token.Value + "\n"); err != nil {
+		f.Close()
+		return Token{}, err
+	}
+	if err := f.Close(); err != nil {
+		return Token{}, err
+	}
+	token.Location, token.Workspace = path+"#"+name, workspace
+	return token, r.add(token)
+}
+
+// Remove forgets the canary with the given ID and reports whether it was
+// known. The file it was planted in is left alone.
+func (r *Registry) Remove(id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := slices.IndexFunc(r.tokens, func(t Token) bool { return t.ID == id })
+	if i < 0 {
+		return false, nil
+	}
+	tokens := slices.Delete(slices.Clone(r.tokens), i, i+1)
+	if err := r.save(tokens); err != nil {
+		return false, err
+	}
+	r.tokens = tokens
+	return true, nil
+}
+
+// List returns every canary, ordered by creation time.
+func (r *Registry) List() []Token {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := slices.Clone(r.tokens)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	return out
+}
+
+// Find returns the canaries whose values appear in text.
+func (r *Registry) Find(text string) []Token {
+	if r == nil || text == "" {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var found []Token
+	for _, token := range r.tokens {
+		if strings.Contains(text, token.Value) {
+			found = append(found, token)
+		}
+	}
+	return found
+}
+
+// FindValue is Find applied to every string within a JSON-like value, such
+// as a tool call's arguments. Each canary is reported once.
+func (r *Registry) FindValue(v any) []Token {
+	if r == nil {
+		return nil
+	}
+	var found []Token
+	var walk func(any)
+	walk = func(v any) {
+		switch x := v.(type) {
+		case string:
+			for _, token := range r.Find(x) {
+				if !slices.ContainsFunc(found, func(t Token) bool { return t.ID == token.ID }) {
+					found = append(found, token)
+				}
+			}
+		case []any:
+			for _, item := range x {
+				walk(item)
+			}
+		case map[string]any:
+			for _, item := range x {
+				walk(item)
+			}
+		}
+	}
+	walk(v)
+	return found
+}
+
+// add records token and saves the registry.
+func (r *Registry) add(token Token) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tokens := append(slices.Clone(r.tokens), token)
+	if err := r.save(tokens); err != nil {
+		return err
+	}
+	r.tokens = tokens
+	return nil
+}
+
+// save writes tokens to the registry file, replacing it atomically.
+func (r *Registry) save(tokens []Token) error {
+	if r.Path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(registryFile{Version: 1, Tokens: tokens}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.Path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.Path)
+}
+
+// randomString returns prefix followed by n characters drawn uniformly from
+// alphabet.
+func randomString(prefix, alphabet string, n int) (string, error) {
+	var b strings.Builder
+	b.WriteString(prefix)
+	limit := big.NewInt(int64(len(alphabet)))
+	for range n {
+		i, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(alphabet[i.Int64()])
+	}
+	return b.String(), nil
+}
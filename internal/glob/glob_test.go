@@ -0,0 +1,116 @@
+package glob
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompilePath_Match(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{"/etc/**", "/etc/passwd", true},
+		{"/etc/**", "/etc/nginx/nginx.conf", true},
+		{"/etc/**", "/etc", false},
+		{"/etc/**", "/home/user/file.txt", false},
+		{"/home/**/.ssh/*", "/home/alice/.ssh/id_rsa", true},
+		{"/home/**/.ssh/*", "/home/.ssh/id_rsa", true},
+		{"/home/**/.ssh/*", "/home/alice/projects/notes.txt", false},
+		{"/home/**/.ssh/*", "/home/alice/.ssh/keys/id_rsa", false},
+		{"**/*.pem", "/srv/certs/server.pem", true},
+		{"**/*.pem", "/srv/certs/server.pem.txt", false},
+		{"*.pem", "/srv/certs/server.pem", true},
+		{".env", "/work/app/.env", true},
+		{".env", "/work/app/.envrc", false},
+		{"/tmp/*.txt", "/tmp/log.txt", true},
+		{"/tmp/*.txt", "/tmp/sub/log.txt", false},
+		{"/tmp/log.[ch]", "/tmp/log.c", true},
+		{"/tmp/log.[!ch]", "/tmp/log.c", false},
+		{"/tmp/log.[^ch]", "/tmp/log.o", true},
+		{"/tmp/[a-c]?.go", "/tmp/b1.go", true},
+		{"/tmp/[a-c]?.go", "/tmp/d1.go", false},
+		{`/tmp/\*.go`, "/tmp/*.go", true},
+		{`/tmp/\*.go`, "/tmp/x.go", false},
+		{"/a/**/b/**/c", "/a/b/c", true},
+		{"/a/**/b/**/c", "/a/x/b/y/z/c", true},
+		{"/data/", "/data", true},
+	}
+
+	for _, tt := range tests {
+		p, err := CompilePath(tt.pattern)
+		if err != nil {
+			t.Fatalf("CompilePath(%q) error = %v", tt.pattern, err)
+		}
+		if got := p.Match(tt.value); got != tt.want {
+			t.Errorf("CompilePath(%q).Match(%q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCompileText_Match(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{"echo *", "echo hi", true},
+		{"echo *", "echo", false},
+		{"ls *", "ls /some/path", true},
+		{"rm **", "rm -rf /", true},
+		{"*", "", true},
+		{"", "", true},
+		{"", "x", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"a*a", "a", false},
+		{"a*a", "aa", true},
+		{"*ab*ab", "abxab", true},
+		{"*ab*ab", "ab", false},
+		{"git [sl]*", "git status", true},
+		{"git [sl]*", "git push", false},
+		{"*a*a*a*b", strings.Repeat("a", 64), false},
+		{"*a*a*a*b", strings.Repeat("a", 64) + "b", true},
+		{"héllo wörld", "héllo wörld", true},
+		{"h?llo", "héllo", true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchText(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("MatchText(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, pattern := range []string{"/tmp/[abc", `/tmp/x\`, "/tmp/[z-a]"} {
+		if _, err := CompilePath(pattern); err == nil {
+			t.Errorf("CompilePath(%q) expected error", pattern)
+		}
+	}
+}
+
+func TestList_Negation(t *testing.T) {
+	list, err := CompilePathList([]string{"/work/**", "!/work/public/**", "/work/public/secret.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		value string
+		want  bool
+		by    string
+	}{
+		{"/work/src/main.go", true, "/work/**"},
+		{"/work/public/index.html", false, ""},
+		{"/work/public/secret.txt", true, "/work/public/secret.txt"},
+		{"/other/file", false, ""},
+	}
+	for _, tt := range tests {
+		got, by := list.Match(tt.value)
+		if got != tt.want || by != tt.by {
+			t.Errorf("Match(%q) = (%v, %q), want (%v, %q)", tt.value, got, by, tt.want, tt.by)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package glob
+
+// List is an ordered set of patterns evaluated with gitignore semantics: the
+// last pattern that matches a value decides, and a negated pattern that
+// matches un-does earlier matches.
+type List []*Pattern
+
+// CompilePathList compiles patterns with CompilePath.
+func CompilePathList(patterns []string) (List, error) {
+	return compileList(patterns, CompilePath)
+}
+
+// CompileTextList compiles patterns with CompileText.
+func CompileTextList(patterns []string) (List, error) {
+	return compileList(patterns, CompileText)
+}
+
+func compileList(patterns []string, compile func(string) (*Pattern, error)) (List, error) {
+	list := make(List, 0, len(patterns))
+	for _, raw := range patterns {
+		p, err := compile(raw)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+// Match reports whether value is included by the list and, when it is, the
+// pattern responsible.
+func (l List) Match(value string) (bool, string) {
+	matched := false
+	var by string
+	for _, p := range l {
+		if !p.Match(value) {
+			continue
+		}
+		matched = !p.Negated()
+		by = p.String()
+	}
+	if !matched {
+		return false, ""
+	}
+	return true, by
+}
@@ -2,15 +2,21 @@ package hitl
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"runtime"
 	"strings"
+	"time"
 
 	"bridgekeeper/internal/console"
+	"bridgekeeper/internal/redact"
 	"bridgekeeper/internal/types"
 )
 
+// defaultPageLines is used when the terminal height is unknown.
+const defaultPageLines = 40
+
 // AutoApprover automatically approves requests (used when --no-hitl flag is passed).
 type AutoApprover struct{}
 
@@ -18,7 +24,20 @@ type AutoApprover struct{}
 type AutoDenier struct{}
 
 // TerminalApprover prompts a human user in the terminal to approve or deny an action.
+// When Grants is set, the prompt also offers session-wide answers that are
+// recorded there for the mediator to consult before asking again.
+//
+// The prompt shows a redacted preview of the call, including a diff for file
+// writes, paged to PageLines lines at a time (the terminal height when zero).
+//
+// When RememberFor is set, the prompt also offers to remember the approval
+// across sessions for that long; the mediator stores it.
 type TerminalApprover struct {
+	Grants      *Grants
+	Redactor    *redact.Redactor
+	PageLines   int
+	RememberFor time.Duration
+
 	in      *os.File
 	out     *os.File
 	session *console.Session
@@ -55,34 +74,283 @@ func NewTerminalApprover() (*TerminalApprover, error) {
 	return &TerminalApprover{in: in, out: out, session: session}, nil
 }
 
-func (a *AutoApprover) Approve(_ context.Context, _ types.ToolCall, _ types.PolicyDecision) (bool, error) {
-	return true, nil
+func (a *AutoApprover) Approve(_ context.Context, _ types.ToolCall, _ types.PolicyDecision) (types.ApprovalResult, error) {
+	return types.ApprovalResult{Approved: true, Approver: "auto"}, nil
 }
 
-func (a *AutoDenier) Approve(_ context.Context, _ types.ToolCall, _ types.PolicyDecision) (bool, error) {
-	return false, nil
+func (a *AutoDenier) Approve(_ context.Context, _ types.ToolCall, _ types.PolicyDecision) (types.ApprovalResult, error) {
+	return types.ApprovalResult{Approved: false, Approver: "auto"}, nil
 }
 
-func (t *TerminalApprover) Approve(ctx context.Context, call types.ToolCall, decision types.PolicyDecision) (bool, error) {
+// Approve prompts for a decision. When the policy requires justification, an
+// empty explanation denies the call; when it asks for several approvers, the
+// call is denied at once, since the terminal is a single channel. The
+// approver may also answer "e" to approve the call with edited arguments. A
+// deadline on ctx bounds the prompt.
+func (t *TerminalApprover) Approve(ctx context.Context, call types.ToolCall, decision types.PolicyDecision) (types.ApprovalResult, error) {
 	if t == nil || t.in == nil || t.out == nil || t.session == nil {
-		return false, fmt.Errorf("terminal approver is not initialized")
+		return types.ApprovalResult{}, fmt.Errorf("terminal approver is not initialized")
 	}
 
 	select {
 	case <-ctx.Done():
-		return false, ctx.Err()
+		return types.ApprovalResult{}, ctx.Err()
 	default:
 	}
 
-	line, err := t.session.ReadLine(fmt.Sprintf("Approve tool call? tool=%s action=%s reason=%s [y/N]: ", call.Tool, call.Action, decision.Reason))
+	// Not every input supports deadlines; without one the mediator still
+	// enforces the timeout, it just cannot interrupt the read. Ending ctx also
+	// interrupts the read, so an approver that lost a quorum race gives the
+	// terminal back.
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = t.in.SetReadDeadline(deadline)
+	}
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		_ = t.in.SetReadDeadline(time.Now())
+		close(interrupted)
+	})
+	defer func() {
+		if !stop() {
+			<-interrupted
+		}
+		_ = t.in.SetReadDeadline(time.Time{})
+	}()
+
+	settings := types.ApprovalSettings{}
+	if decision.Approval != nil {
+		settings = *decision.Approval
+	}
+	if err := t.page(ctx, RenderRequest(call, decision, t.Redactor)); err != nil {
+		return types.ApprovalResult{}, err
+	}
+
+	// The mediator counts the terminal as one approver however many people
+	// stand at it, so a call needing several must be approved on more
+	// channels; inside a quorum or chain each member casts a single vote and
+	// never sees this.
+	if settings.RequiredApprovers > 1 {
+		reason := fmt.Sprintf("policy requires %d distinct approvers and the terminal counts as one; list more channels with -approver", settings.RequiredApprovers)
+		fmt.Fprintf(t.out, "%s. Denying.\n", reason)
+		return types.ApprovalResult{Comment: reason}, nil
+	}
+
+	// Standing grants and remembered approvals only make sense when a plain
+	// "yes" would do.
+	offerGrants := t.Grants != nil && !settings.RequireJustification
+	offerRemember := t.RememberFor > 0 && !settings.RequireJustification
+	choices := "[y/N/e/d]"
+	if offerGrants || offerRemember {
+		options := []string{"y = approve once"}
+		choices = "[y/N"
+		if offerGrants {
+			options = append(options, fmt.Sprintf("s = approve %s/%s for this session", call.Tool, call.Action), "p = approve this path for this session")
+			choices += "/s/p"
+		}
+		if offerRemember {
+			options = append(options, fmt.Sprintf("r = remember this approval for %s", formatDays(t.RememberFor)))
+			choices += "/r"
+		}
+		options = append(options, "e = edit arguments", "d = deny and stop the turn", "anything else denies")
+		choices += "/e/d]"
+		fmt.Fprintf(t.out, "  %s\n", strings.Join(options, ", "))
+	}
+
+	line, err := t.readLine(ctx, fmt.Sprintf("Approve %s/%s? %s: ", call.Tool, call.Action, choices))
 	if err != nil {
-		return false, err
+		return types.ApprovalResult{}, err
+	}
+	result := types.ApprovalResult{}
+	switch answer := strings.ToLower(strings.TrimSpace(line)); {
+	case answer == "y" || answer == "yes":
+	case answer == "d":
+		return types.ApprovalResult{}, ErrStopTurn
+	case offerGrants && (answer == "s" || answer == "p"):
+		scope := ScopeToolAction
+		if answer == "p" {
+			scope = ScopePath
+		}
+		grant, err := t.Grants.Allow(scope, call, decision)
+		if err != nil {
+			fmt.Fprintf(t.out, "Cannot record grant: %v; denying.\n", err)
+			return types.ApprovalResult{}, nil
+		}
+		fmt.Fprintf(t.out, "Granted %s. Use /grants to review or revoke.\n", grant)
+		return types.ApprovalResult{Approved: true, Comment: fmt.Sprintf("session grant #%d", grant.ID)}, nil
+	case offerRemember && answer == "r":
+		patterns, err := t.rememberPatterns(ctx, call)
+		if err != nil {
+			return types.ApprovalResult{}, err
+		}
+		return types.ApprovalResult{
+			Approved: true,
+			Remember: &types.RememberRequest{For: t.RememberFor, Patterns: patterns},
+		}, nil
+	case answer == "e":
+		args, ok, err := t.edit(ctx, call, decision)
+		if err != nil {
+			return types.ApprovalResult{}, err
+		}
+		if !ok {
+			return types.ApprovalResult{}, nil
+		}
+		result.Args = args
+	default:
+		return types.ApprovalResult{}, nil
+	}
+
+	if settings.RequireJustification {
+		justification, err := t.readLine(ctx, "Justification (required): ")
+		if err != nil {
+			return types.ApprovalResult{}, err
+		}
+		if strings.TrimSpace(justification) == "" {
+			fmt.Fprintf(t.out, "A justification is required; denying.\n")
+			return types.ApprovalResult{}, nil
+		}
+		result.Justification = strings.TrimSpace(justification)
 	}
 
-	switch strings.ToLower(strings.TrimSpace(line)) {
+	result.Approved = true
+	return result, nil
+}
+
+// edit collects argument edits as key=value lines and asks the reviewer to
+// confirm the edited call. Values that parse as JSON keep their JSON type, so
+// args=["log","-n","5"] sets a list; anything else is taken as a string. A
+// line of the form -key removes the argument.
+func (t *TerminalApprover) edit(ctx context.Context, call types.ToolCall, decision types.PolicyDecision) (map[string]any, bool, error) {
+	args := make(map[string]any, len(call.Args))
+	for key, value := range call.Args {
+		args[key] = value
+	}
+
+	fmt.Fprintf(t.out, "Enter edits as key=value (or -key to remove); an empty line finishes.\n")
+	for {
+		line, err := t.readLine(ctx, "edit> ")
+		if err != nil {
+			return nil, false, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if key, ok := strings.CutPrefix(line, "-"); ok && !strings.Contains(key, "=") {
+			delete(args, key)
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			fmt.Fprintf(t.out, "Expected key=value.\n")
+			continue
+		}
+		var value any
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			value = raw
+		}
+		args[strings.TrimSpace(key)] = value
+	}
+
+	edited := call
+	edited.Args = args
+	if err := t.page(ctx, RenderRequest(edited, decision, t.Redactor)); err != nil {
+		return nil, false, err
+	}
+	answer, err := t.readLine(ctx, "Approve the edited call? [y/N]: ")
+	if err != nil {
+		return nil, false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
 	case "y", "yes":
-		return true, nil
+		return args, true, nil
 	default:
-		return false, nil
+		return nil, false, nil
+	}
+}
+
+// rememberPatterns lets the reviewer widen a remembered approval with
+// key=glob lines, so "path=docs/**" also covers other files under docs.
+// Arguments without a pattern must match exactly.
+func (t *TerminalApprover) rememberPatterns(ctx context.Context, call types.ToolCall) (map[string]string, error) {
+	fmt.Fprintf(t.out, "Widen with argument patterns as key=glob; an empty line remembers these exact arguments.\n")
+	var patterns map[string]string
+	for {
+		line, err := t.readLine(ctx, "pattern> ")
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return patterns, nil
+		}
+		key, pattern, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			fmt.Fprintf(t.out, "Expected key=glob.\n")
+			continue
+		}
+		if _, ok := call.Args[key].(string); !ok {
+			fmt.Fprintf(t.out, "The call has no string argument %q.\n", key)
+			continue
+		}
+		if _, err := compileArgPattern(key, pattern); err != nil {
+			fmt.Fprintf(t.out, "Invalid pattern: %v\n", err)
+			continue
+		}
+		if patterns == nil {
+			patterns = map[string]string{}
+		}
+		patterns[key] = pattern
+	}
+}
+
+// formatDays renders a duration as whole days when it is at least a day.
+func formatDays(d time.Duration) string {
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d days", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
+// page writes text to the terminal a screenful at a time. The reviewer can
+// press Enter for the next page or q to skip to the decision prompt.
+func (t *TerminalApprover) page(ctx context.Context, text string) error {
+	lines := splitLines(text)
+	size := t.PageLines
+	if size <= 0 {
+		size = console.Height(t.out) - 2
+	}
+	if size <= 0 {
+		size = defaultPageLines
+	}
+
+	for len(lines) > 0 {
+		n := min(size, len(lines))
+		for _, line := range lines[:n] {
+			fmt.Fprintln(t.out, line)
+		}
+		lines = lines[n:]
+		if len(lines) == 0 {
+			break
+		}
+		answer, err := t.readLine(ctx, fmt.Sprintf("-- %d more lines: Enter to continue, q to skip --", len(lines)))
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(strings.TrimSpace(answer), "q") {
+			fmt.Fprintf(t.out, "(%d lines not shown)\n", len(lines))
+			break
+		}
+	}
+	return nil
+}
+
+// readLine reads one answer, reporting ctx's error when the read was cut short
+// by the context deadline.
+func (t *TerminalApprover) readLine(ctx context.Context, prompt string) (string, error) {
+	line, err := t.session.ReadLine(prompt)
+	if err != nil && ctx.Err() != nil {
+		return "", ctx.Err()
 	}
+	return line, err
 }
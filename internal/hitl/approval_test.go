@@ -0,0 +1,188 @@
+package hitl
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bridgekeeper/internal/console"
+	"bridgekeeper/internal/types"
+)
+
+// scriptedApprover returns a TerminalApprover that reads answers from a file
+// and writes its prompts to another, so tests can drive it without a TTY.
+func scriptedApprover(t *testing.T, answers string) (*TerminalApprover, func() string) {
+	t.Helper()
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "in")
+	if err := os.WriteFile(inPath, []byte(answers), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	in, err := os.Open(inPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.Create(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = in.Close()
+		_ = out.Close()
+	})
+
+	session, err := console.NewSession(in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := func() string {
+		data, _ := os.ReadFile(out.Name())
+		return string(data)
+	}
+	return &TerminalApprover{in: in, out: out, session: session}, output
+}
+
+func TestTerminalApprover_Answers(t *testing.T) {
+	call := types.ToolCall{Tool: "fs", Action: "write_file", Args: map[string]any{"path": "/ws/a.txt", "content": "x"}}
+	ask := types.PolicyDecision{Decision: types.Ask, Rule: "write-files"}
+
+	tests := []struct {
+		answer    string
+		approved  bool
+		err       error
+		grantKind GrantScope
+	}{
+		{answer: "y\n", approved: true},
+		{answer: "\n", approved: false},
+		{answer: "d\n", err: ErrStopTurn},
+		{answer: "s\n", approved: true, grantKind: ScopeToolAction},
+		{answer: "p\n", approved: true, grantKind: ScopePath},
+	}
+	for _, tt := range tests {
+		approver, _ := scriptedApprover(t, tt.answer)
+		approver.Grants = NewGrants(nil)
+
+		result, err := approver.Approve(context.Background(), call, ask)
+		if !errors.Is(err, tt.err) || result.Approved != tt.approved {
+			t.Errorf("answer %q: got (%v, %v), want (%v, %v)", tt.answer, result.Approved, err, tt.approved, tt.err)
+		}
+		grants := approver.Grants.List()
+		if tt.grantKind == "" {
+			if len(grants) != 0 {
+				t.Errorf("answer %q: unexpected grants %v", tt.answer, grants)
+			}
+			continue
+		}
+		if len(grants) != 1 || grants[0].Scope != tt.grantKind {
+			t.Errorf("answer %q: grants = %v, want one %s grant", tt.answer, grants, tt.grantKind)
+		}
+	}
+}
+
+func TestTerminalApprover_ApprovalSettings(t *testing.T) {
+	call := types.ToolCall{Tool: "pkg", Action: "install"}
+	justified := types.PolicyDecision{
+		Decision: types.Ask,
+		Rule:     "pkg-ops",
+		Approval: &types.ApprovalSettings{RequireJustification: true},
+	}
+
+	tests := []struct {
+		answers string
+		want    bool
+	}{
+		{answers: "y\nneeded for the build\n", want: true},
+		{answers: "y\n\n", want: false},
+		{answers: "n\n", want: false},
+		{answers: "s\n", want: false},
+	}
+	for _, tt := range tests {
+		approver, _ := scriptedApprover(t, tt.answers)
+		approver.Grants = NewGrants(nil)
+		result, err := approver.Approve(context.Background(), call, justified)
+		if err != nil {
+			t.Fatalf("answers %q: error = %v", tt.answers, err)
+		}
+		if result.Approved != tt.want {
+			t.Errorf("answers %q: approved = %v, want %v", tt.answers, result.Approved, tt.want)
+		}
+		if tt.want && result.Justification != "needed for the build" {
+			t.Errorf("answers %q: result = %+v, want the justification recorded", tt.answers, result)
+		}
+	}
+
+	// The terminal is one channel, so it cannot supply several approvers
+	// however many names are typed at it.
+	approver, output := scriptedApprover(t, "y\n")
+	result, err := approver.Approve(context.Background(), call, types.PolicyDecision{
+		Decision: types.Ask,
+		Rule:     "pkg-ops",
+		Approval: &types.ApprovalSettings{RequiredApprovers: 2},
+	})
+	if err != nil || result.Approved {
+		t.Fatalf("Approve() = %+v, %v; want a denial", result, err)
+	}
+	if !strings.Contains(output(), "list more channels with -approver") || strings.Contains(output(), "Approve pkg/install?") {
+		t.Errorf("expected an immediate denial naming the fix:\n%s", output())
+	}
+}
+
+func TestTerminalApprover_PagesLongPreview(t *testing.T) {
+	content := strings.Repeat("line\n", 50)
+	call := types.ToolCall{Tool: "fs", Action: "write_file", Args: map[string]any{"path": filepath.Join(t.TempDir(), "new.txt"), "content": content}}
+
+	approver, output := scriptedApprover(t, "\nq\ny\n")
+	approver.PageLines = 20
+
+	result, err := approver.Approve(context.Background(), call, types.PolicyDecision{Decision: types.Ask})
+	if err != nil || !result.Approved {
+		t.Fatalf("Approve() = %+v, %v; want approval", result, err)
+	}
+	out := output()
+	if strings.Count(out, "more lines: Enter to continue") != 2 {
+		t.Fatalf("expected two page breaks:\n%s", out)
+	}
+	if !strings.Contains(out, "lines not shown") {
+		t.Fatalf("expected skipped lines notice:\n%s", out)
+	}
+}
+
+func TestTerminalApprover_EditArguments(t *testing.T) {
+	call := types.ToolCall{Tool: "git", Action: "log", Args: map[string]any{
+		"args": []any{"log"},
+		"path": "/repo",
+	}}
+
+	approver, output := scriptedApprover(t, "e\nargs=[\"log\",\"-n\",\"5\"]\n-path\nnote=keep it short\n\ny\n")
+	result, err := approver.Approve(context.Background(), call, types.PolicyDecision{Decision: types.Ask, Rule: "git"})
+	if err != nil {
+		t.Fatalf("Approve() error = %v", err)
+	}
+	if !result.Approved {
+		t.Fatalf("expected approval, got %+v", result)
+	}
+	if _, ok := result.Args["path"]; ok {
+		t.Errorf("path should have been removed: %v", result.Args)
+	}
+	if result.Args["note"] != "keep it short" {
+		t.Errorf("note = %v, want string value", result.Args["note"])
+	}
+	if args, _ := stringList(result.Args["args"]); strings.Join(args, " ") != "log -n 5" {
+		t.Errorf("args = %v, want [log -n 5]", result.Args["args"])
+	}
+	if !strings.Contains(output(), "Command: git log -n 5") {
+		t.Errorf("edited call was not shown for confirmation:\n%s", output())
+	}
+	if _, ok := call.Args["path"]; !ok {
+		t.Error("editing must not mutate the original call")
+	}
+
+	approver, _ = scriptedApprover(t, "e\npath=/elsewhere\n\nn\n")
+	result, err = approver.Approve(context.Background(), call, types.PolicyDecision{Decision: types.Ask, Rule: "git"})
+	if err != nil || result.Approved || result.Args != nil {
+		t.Fatalf("declined edit = %+v, %v; want plain denial", result, err)
+	}
+}
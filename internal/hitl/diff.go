@@ -0,0 +1,145 @@
+package hitl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffCells bounds the LCS table so a huge file cannot stall the prompt.
+const maxDiffCells = 1 << 22
+
+// diffOp is one line of an edit script. old and new are the 0-based line
+// positions in each input before the op is applied.
+type diffOp struct {
+	kind byte // ' ', '-', or '+'
+	text string
+	old  int
+	new  int
+}
+
+// unifiedDiff returns a unified diff turning before into after with
+// contextLines lines around each change. It returns "" when the inputs are
+// identical.
+func unifiedDiff(beforeName, afterName, before, after string, contextLines int) string {
+	a, b := splitLines(before), splitLines(after)
+
+	ops, ok := diffLines(a, b)
+	if !ok {
+		return fmt.Sprintf("(too large to diff: %d lines before, %d lines after)\n", len(a), len(b))
+	}
+
+	var hunks [][2]int // [start, end) ranges of ops
+	for i, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		start, end := max(0, i-contextLines), min(len(ops), i+contextLines+1)
+		if n := len(hunks); n > 0 && start <= hunks[n-1][1] {
+			hunks[n-1][1] = end
+			continue
+		}
+		hunks = append(hunks, [2]int{start, end})
+	}
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", beforeName, afterName)
+	for _, h := range hunks {
+		oldCount, newCount := 0, 0
+		for _, op := range ops[h[0]:h[1]] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		first := ops[h[0]]
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(first.old, oldCount), hunkRange(first.new, newCount))
+		for _, op := range ops[h[0]:h[1]] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+		}
+	}
+	return out.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// diffLines computes a minimal line edit script with a longest-common-
+// subsequence table. Common leading and trailing lines are stripped first so
+// the table only covers the changed region. It reports false when that region
+// is too large.
+func diffLines(a, b []string) ([]diffOp, bool) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(midA)+1)*(len(midB)+1) > maxDiffCells {
+		return nil, false
+	}
+
+	// lcs[i][j] is the LCS length of midA[i:] and midB[j:].
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for k := 0; k < prefix; k++ {
+		ops = append(ops, diffOp{kind: ' ', text: a[k], old: k, new: k})
+	}
+	i, j := 0, 0
+	for i < len(midA) || j < len(midB) {
+		switch {
+		case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+			ops = append(ops, diffOp{kind: ' ', text: midA[i], old: prefix + i, new: prefix + j})
+			i++
+			j++
+		case j >= len(midB) || (i < len(midA) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', text: midA[i], old: prefix + i, new: prefix + j})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', text: midB[j], old: prefix + i, new: prefix + j})
+			j++
+		}
+	}
+	for k := 0; k < suffix; k++ {
+		ops = append(ops, diffOp{kind: ' ', text: a[len(a)-suffix+k], old: len(a) - suffix + k, new: len(b) - suffix + k})
+	}
+	return ops, true
+}
+
+// splitLines splits text into lines without their terminators. A trailing
+// newline does not produce an empty final line.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,196 @@
+package hitl
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"bridgekeeper/internal/audit"
+	"bridgekeeper/internal/types"
+)
+
+// ErrStopTurn is returned by an approver when the human denied the call and
+// asked for the rest of the model's turn to be abandoned.
+var ErrStopTurn = errors.New("approver denied the call and stopped the turn")
+
+// GrantScope says how broadly a session grant applies.
+type GrantScope string
+
+const (
+	// ScopeToolAction covers every call to the tool and action.
+	ScopeToolAction GrantScope = "tool"
+	// ScopePath covers calls to the tool and action with one exact path.
+	ScopePath GrantScope = "path"
+)
+
+// Grant is a standing approval recorded for the rest of the session. Grants
+// are keyed by the capability that asked, so an approval for one rule never
+// answers a different rule's question.
+type Grant struct {
+	ID      int        `json:"id"`
+	Scope   GrantScope `json:"scope"`
+	Tool    string     `json:"tool"`
+	Action  string     `json:"action"`
+	Rule    string     `json:"rule"`
+	Path    string     `json:"path,omitempty"`
+	Created time.Time  `json:"created"`
+	Uses    int        `json:"uses"`
+}
+
+func (g Grant) String() string {
+	target := g.Tool + "/" + g.Action
+	if g.Scope == ScopePath {
+		target += " " + g.Path
+	}
+	return fmt.Sprintf("#%d %s (rule %s, used %d times, since %s)", g.ID, target, g.Rule, g.Uses, g.Created.Format(time.Kitchen))
+}
+
+// Grants is an in-memory table of session grants. It is safe for concurrent
+// use. Every change is audited when Audit is set.
+type Grants struct {
+	Audit *audit.Logger
+
+	mu     sync.Mutex
+	nextID int
+	grants []Grant
+}
+
+// NewGrants returns an empty grant table that audits changes to logger.
+func NewGrants(logger *audit.Logger) *Grants {
+	return &Grants{Audit: logger}
+}
+
+// Allow records a grant covering call under the given scope and returns it.
+// A path grant requires the call to carry a "path" argument.
+func (g *Grants) Allow(scope GrantScope, call types.ToolCall, decision types.PolicyDecision) (Grant, error) {
+	if g == nil {
+		return Grant{}, errors.New("grant table is not configured")
+	}
+
+	grant := Grant{
+		Scope:  scope,
+		Tool:   call.Tool,
+		Action: call.Action,
+		Rule:   decision.Rule,
+	}
+	switch scope {
+	case ScopeToolAction:
+	case ScopePath:
+		grant.Path = grantPath(call)
+		if grant.Path == "" {
+			return Grant{}, errors.New("call has no path to grant")
+		}
+	default:
+		return Grant{}, fmt.Errorf("unknown grant scope %q", scope)
+	}
+
+	g.mu.Lock()
+	g.nextID++
+	grant.ID = g.nextID
+	grant.Created = time.Now()
+	g.grants = append(g.grants, grant)
+	g.mu.Unlock()
+
+	g.Audit.Log(audit.Info, "session_grant_added", map[string]any{
+		"grant":  grant.ID,
+		"scope":  grant.Scope,
+		"tool":   grant.Tool,
+		"action": grant.Action,
+		"rule":   grant.Rule,
+		"path":   grant.Path,
+	})
+	return grant, nil
+}
+
+// Match returns the first grant covering call and decision, counting the use.
+// Decisions that demand more than a single yes, such as multiple approvers or
+// a justification, are never answered from the table.
+func (g *Grants) Match(call types.ToolCall, decision types.PolicyDecision) (Grant, bool) {
+	if g == nil {
+		return Grant{}, false
+	}
+	if a := decision.Approval; a != nil && (a.RequiredApprovers > 1 || a.RequireJustification) {
+		return Grant{}, false
+	}
+
+	path := grantPath(call)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i := range g.grants {
+		grant := &g.grants[i]
+		if grant.Tool != call.Tool || grant.Action != call.Action || grant.Rule != decision.Rule {
+			continue
+		}
+		if grant.Scope == ScopePath && grant.Path != path {
+			continue
+		}
+		grant.Uses++
+		return *grant, true
+	}
+	return Grant{}, false
+}
+
+// List returns a snapshot of the current grants in creation order.
+func (g *Grants) List() []Grant {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Grant(nil), g.grants...)
+}
+
+// Revoke removes the grant with the given ID and reports whether it existed.
+func (g *Grants) Revoke(id int) bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	var removed *Grant
+	for i, grant := range g.grants {
+		if grant.ID == id {
+			removed = &grant
+			g.grants = append(g.grants[:i], g.grants[i+1:]...)
+			break
+		}
+	}
+	g.mu.Unlock()
+
+	if removed == nil {
+		return false
+	}
+	g.Audit.Log(audit.Info, "session_grant_revoked", map[string]any{
+		"grant": removed.ID,
+		"uses":  removed.Uses,
+	})
+	return true
+}
+
+// Clear removes every grant and returns how many were removed.
+func (g *Grants) Clear() int {
+	if g == nil {
+		return 0
+	}
+	g.mu.Lock()
+	n := len(g.grants)
+	g.grants = nil
+	g.mu.Unlock()
+
+	if n > 0 {
+		g.Audit.Log(audit.Info, "session_grants_cleared", map[string]any{"count": n})
+	}
+	return n
+}
+
+// grantPath returns the cleaned "path" argument of call, or "" when absent.
+func grantPath(call types.ToolCall) string {
+	path, _ := call.Args["path"].(string)
+	if strings.TrimSpace(path) == "" {
+		return ""
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,72 @@
+package hitl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"bridgekeeper/internal/audit"
+	"bridgekeeper/internal/types"
+)
+
+func TestGrants_ToolActionAndPathScopes(t *testing.T) {
+	var auditOut bytes.Buffer
+	grants := NewGrants(audit.NewLogger(&auditOut, audit.Info))
+	ask := types.PolicyDecision{Decision: types.Ask, Rule: "write-files"}
+
+	write := func(path string) types.ToolCall {
+		return types.ToolCall{Tool: "fs", Action: "write_file", Args: map[string]any{"path": path}}
+	}
+
+	if _, ok := grants.Match(write("notes.txt"), ask); ok {
+		t.Fatal("empty table should not match")
+	}
+
+	pathGrant, err := grants.Allow(ScopePath, write("./docs/../notes.txt"), ask)
+	if err != nil {
+		t.Fatalf("Allow(path) error = %v", err)
+	}
+	if _, ok := grants.Match(write("notes.txt"), ask); !ok {
+		t.Fatal("path grant should match the cleaned path")
+	}
+	if _, ok := grants.Match(write("other.txt"), ask); ok {
+		t.Fatal("path grant should not match a different path")
+	}
+	if _, ok := grants.Match(write("notes.txt"), types.PolicyDecision{Decision: types.Ask, Rule: "other-rule"}); ok {
+		t.Fatal("grant should not answer a different rule")
+	}
+
+	if _, err := grants.Allow(ScopePath, types.ToolCall{Tool: "pkg", Action: "install"}, ask); err == nil {
+		t.Fatal("path grant without a path should fail")
+	}
+
+	toolGrant, err := grants.Allow(ScopeToolAction, write("a.txt"), ask)
+	if err != nil {
+		t.Fatalf("Allow(tool) error = %v", err)
+	}
+	if got, ok := grants.Match(write("other.txt"), ask); !ok || got.ID != toolGrant.ID {
+		t.Fatalf("tool grant should match any path, got %+v, %v", got, ok)
+	}
+
+	strict := ask
+	strict.Approval = &types.ApprovalSettings{RequireJustification: true}
+	if _, ok := grants.Match(write("other.txt"), strict); ok {
+		t.Fatal("grants must not answer decisions that require justification")
+	}
+
+	if !grants.Revoke(pathGrant.ID) || grants.Revoke(pathGrant.ID) {
+		t.Fatal("Revoke should succeed once")
+	}
+	if n := grants.Clear(); n != 1 {
+		t.Fatalf("Clear() = %d, want 1", n)
+	}
+	if len(grants.List()) != 0 {
+		t.Fatal("expected no grants after Clear")
+	}
+
+	for _, event := range []string{"session_grant_added", "session_grant_revoked", "session_grants_cleared"} {
+		if !strings.Contains(auditOut.String(), event) {
+			t.Errorf("audit log missing %s", event)
+		}
+	}
+}
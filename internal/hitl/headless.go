@@ -0,0 +1,98 @@
+package hitl
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"bridgekeeper/internal/redact"
+	"bridgekeeper/internal/types"
+)
+
+// defaultHeadlessTimeout bounds how long headless approvers wait when neither
+// the approver nor the policy sets a timeout.
+const defaultHeadlessTimeout = 5 * time.Minute
+
+// ApprovalRequest describes a pending call to approvers that run without a
+// human at this process's terminal: webhooks and spool directories. Arguments
+// are redacted before they leave the process.
+type ApprovalRequest struct {
+	ID       string                  `json:"id"`
+	Tool     string                  `json:"tool"`
+	Action   string                  `json:"action"`
+	Args     any                     `json:"args,omitempty"`
+	Rule     string                  `json:"rule"`
+	Reason   string                  `json:"reason"`
+	Approval *types.ApprovalSettings `json:"approval,omitempty"`
+	Preview  string                  `json:"preview"`
+	Callback string                  `json:"callback,omitempty"`
+	Created  time.Time               `json:"created"`
+	Expires  time.Time               `json:"expires"`
+}
+
+// ApprovalResponse is a headless reviewer's answer to an ApprovalRequest.
+type ApprovalResponse struct {
+	ID            string `json:"id"`
+	Approved      bool   `json:"approved"`
+	Approver      string `json:"approver,omitempty"`
+	Comment       string `json:"comment,omitempty"`
+	Justification string `json:"justification,omitempty"`
+}
+
+func newApprovalRequest(call types.ToolCall, decision types.PolicyDecision, redactor *redact.Redactor, expires time.Time) (ApprovalRequest, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ApprovalRequest{}, err
+	}
+	now := time.Now().UTC()
+	return ApprovalRequest{
+		ID:       hex.EncodeToString(id),
+		Tool:     call.Tool,
+		Action:   call.Action,
+		Args:     redactor.RedactValue(call.Args),
+		Rule:     decision.Rule,
+		Reason:   decision.Reason,
+		Approval: decision.Approval,
+		Preview:  RenderRequest(call, decision, redactor),
+		Created:  now,
+		Expires:  expires.UTC(),
+	}, nil
+}
+
+// result converts a response into an ApprovalResult, denying approvals that
+// omit a justification the policy requires.
+func (r ApprovalResponse) result(settings *types.ApprovalSettings) types.ApprovalResult {
+	out := types.ApprovalResult{
+		Approved:      r.Approved,
+		Approver:      r.Approver,
+		Comment:       r.Comment,
+		Justification: strings.TrimSpace(r.Justification),
+	}
+	if out.Approved && settings != nil && settings.RequireJustification && out.Justification == "" {
+		out.Approved = false
+		out.Comment = "approval rejected: justification required"
+	}
+	return out
+}
+
+// headlessDeadline returns when a headless approver stops waiting: after its
+// configured timeout, or earlier if ctx ends first. Policy approval timeouts
+// reach approvers through ctx, so the mediator can apply on_timeout.
+func headlessDeadline(ctx context.Context, configured time.Duration) time.Time {
+	if configured <= 0 {
+		configured = defaultHeadlessTimeout
+	}
+	deadline := time.Now().Add(configured)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		return ctxDeadline
+	}
+	return deadline
+}
+
+// denial is the fail-closed answer for headless approvers that could not get a
+// usable response.
+func denial(comment string) types.ApprovalResult {
+	return types.ApprovalResult{Approved: false, Comment: comment}
+}
@@ -0,0 +1,363 @@
+package hitl
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bridgekeeper/internal/audit"
+	"bridgekeeper/internal/glob"
+	"bridgekeeper/internal/redact"
+	"bridgekeeper/internal/types"
+)
+
+const (
+	// DefaultRememberFor is how long a remembered approval lasts when the
+	// approver does not say.
+	DefaultRememberFor = 30 * 24 * time.Hour
+	// MaxRememberFor caps how long any approval is remembered.
+	MaxRememberFor = 90 * 24 * time.Hour
+)
+
+// Remembered is an approval kept across sessions. It answers only calls in
+// the same workspace, under the same policy, for the same rule, whose
+// arguments match: patterned arguments by glob, the rest exactly.
+type Remembered struct {
+	ID          string            `json:"id"`
+	Workspace   string            `json:"workspace"`
+	PolicyHash  string            `json:"policy_hash"`
+	Tool        string            `json:"tool"`
+	Action      string            `json:"action"`
+	Rule        string            `json:"rule"`
+	Fingerprint string            `json:"fingerprint"` // digest of the arguments not covered by Patterns
+	Patterns    map[string]string `json:"patterns,omitempty"`
+	Summary     string            `json:"summary,omitempty"` // redacted arguments, for listing
+	Approver    string            `json:"approver,omitempty"`
+	Created     time.Time         `json:"created"`
+	Expires     time.Time         `json:"expires"`
+	LastUsed    time.Time         `json:"last_used,omitempty"`
+	Uses        int               `json:"uses"`
+}
+
+// Expired reports whether r no longer applies at now.
+func (r Remembered) Expired(now time.Time) bool {
+	return !now.Before(r.Expires)
+}
+
+// memoryFile is the on-disk form of a Memory.
+type memoryFile struct {
+	Version   int          `json:"version"`
+	Approvals []Remembered `json:"approvals"`
+}
+
+// Memory is a persistent store of remembered approvals, kept as a JSON file
+// with owner-only permissions. Entries are scoped to Workspace and PolicyHash:
+// changing the policy, or working in another directory, asks again. It is
+// safe for concurrent use within a process. Every change is audited when
+// Audit is set.
+type Memory struct {
+	Path       string
+	Workspace  string
+	PolicyHash string
+	Redactor   *redact.Redactor
+	Audit      *audit.Logger
+
+	mu sync.Mutex
+}
+
+// NewMemory returns a store kept at path for the given workspace and policy.
+func NewMemory(path, workspace, policyHash string) *Memory {
+	return &Memory{Path: path, Workspace: workspace, PolicyHash: policyHash}
+}
+
+// DefaultMemoryPath returns the approval store under the user's config
+// directory.
+func DefaultMemoryPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bridgekeeper", "approvals.json"), nil
+}
+
+// Remember stores an approval for call. Decisions that need more than a
+// single yes, such as several approvers or a justification, are refused.
+func (m *Memory) Remember(call types.ToolCall, decision types.PolicyDecision, approver string, req types.RememberRequest) (Remembered, error) {
+	if m == nil {
+		return Remembered{}, errors.New("approval memory is not configured")
+	}
+	if !rememberable(decision) {
+		return Remembered{}, errors.New("approvals needing several approvers or a justification are not remembered")
+	}
+	for key, pattern := range req.Patterns {
+		if _, err := compileArgPattern(key, pattern); err != nil {
+			return Remembered{}, fmt.Errorf("pattern for %q: %w", key, err)
+		}
+		if _, ok := call.Args[key].(string); !ok {
+			return Remembered{}, fmt.Errorf("pattern for %q: the call has no string argument %q", key, key)
+		}
+	}
+	fingerprint, err := argsFingerprint(call.Args, req.Patterns)
+	if err != nil {
+		return Remembered{}, err
+	}
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return Remembered{}, err
+	}
+
+	ttl := req.For
+	if ttl <= 0 {
+		ttl = DefaultRememberFor
+	}
+	ttl = min(ttl, MaxRememberFor)
+	now := time.Now().UTC()
+	entry := Remembered{
+		ID:          hex.EncodeToString(id),
+		Workspace:   m.Workspace,
+		PolicyHash:  m.PolicyHash,
+		Tool:        call.Tool,
+		Action:      call.Action,
+		Rule:        decision.Rule,
+		Fingerprint: fingerprint,
+		Patterns:    req.Patterns,
+		Summary:     argsSummary(call.Args, req.Patterns, m.Redactor),
+		Approver:    approver,
+		Created:     now,
+		Expires:     now.Add(ttl),
+	}
+
+	err = m.update(func(f *memoryFile) bool {
+		f.Approvals = append(f.Approvals, entry)
+		return true
+	})
+	if err != nil {
+		return Remembered{}, err
+	}
+	m.Audit.Log(audit.Info, "approval_remembered", map[string]any{
+		"remembered": entry.ID,
+		"tool":       entry.Tool,
+		"action":     entry.Action,
+		"rule":       entry.Rule,
+		"patterns":   entry.Patterns,
+		"expires":    entry.Expires,
+	})
+	return entry, nil
+}
+
+// Match returns the unexpired entry answering call and decision, counting the
+// use. Any error reading the store is treated as no match.
+func (m *Memory) Match(call types.ToolCall, decision types.PolicyDecision) (Remembered, bool) {
+	if m == nil || !rememberable(decision) {
+		return Remembered{}, false
+	}
+	now := time.Now().UTC()
+	var found Remembered
+	err := m.update(func(f *memoryFile) bool {
+		for i := range f.Approvals {
+			entry := &f.Approvals[i]
+			if entry.Workspace != m.Workspace || entry.PolicyHash != m.PolicyHash || entry.Expired(now) {
+				continue
+			}
+			if entry.Tool != call.Tool || entry.Action != call.Action || entry.Rule != decision.Rule {
+				continue
+			}
+			if !entry.matches(call.Args) {
+				continue
+			}
+			entry.Uses++
+			entry.LastUsed = now
+			found = *entry
+			return true
+		}
+		return false
+	})
+	if err != nil || found.ID == "" {
+		return Remembered{}, false
+	}
+	return found, true
+}
+
+// List returns every stored entry, across workspaces and policies, ordered by
+// creation time.
+func (m *Memory) List() ([]Remembered, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(f.Approvals, func(i, j int) bool { return f.Approvals[i].Created.Before(f.Approvals[j].Created) })
+	return f.Approvals, nil
+}
+
+// Revoke removes the entry with the given ID and reports whether it existed.
+func (m *Memory) Revoke(id string) (bool, error) {
+	removed := false
+	err := m.update(func(f *memoryFile) bool {
+		for i, entry := range f.Approvals {
+			if entry.ID == id {
+				f.Approvals = append(f.Approvals[:i], f.Approvals[i+1:]...)
+				removed = true
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil || !removed {
+		return false, err
+	}
+	m.Audit.Log(audit.Info, "approval_memory_revoked", map[string]any{"remembered": id})
+	return true, nil
+}
+
+// Prune removes expired entries. When stalePolicy is set it also removes
+// entries for this workspace recorded under a different policy, which can
+// never match again. It returns how many entries were removed.
+func (m *Memory) Prune(stalePolicy bool) (int, error) {
+	now := time.Now().UTC()
+	removed := 0
+	err := m.update(func(f *memoryFile) bool {
+		kept := f.Approvals[:0]
+		for _, entry := range f.Approvals {
+			stale := stalePolicy && entry.Workspace == m.Workspace && entry.PolicyHash != m.PolicyHash
+			if entry.Expired(now) || stale {
+				removed++
+				continue
+			}
+			kept = append(kept, entry)
+		}
+		f.Approvals = kept
+		return removed > 0
+	})
+	if err != nil {
+		return 0, err
+	}
+	if removed > 0 {
+		m.Audit.Log(audit.Info, "approval_memory_pruned", map[string]any{"count": removed})
+	}
+	return removed, nil
+}
+
+// update loads the store, applies change, and writes it back when change
+// reports a modification.
+func (m *Memory) update(change func(*memoryFile) bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := m.load()
+	if err != nil {
+		return err
+	}
+	if !change(&f) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(m.Path), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(m.Path, f)
+}
+
+func (m *Memory) load() (memoryFile, error) {
+	data, err := os.ReadFile(m.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return memoryFile{Version: 1}, nil
+	}
+	if err != nil {
+		return memoryFile{}, err
+	}
+	var f memoryFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return memoryFile{}, fmt.Errorf("parse approval memory %s: %w", m.Path, err)
+	}
+	if f.Version != 1 {
+		return memoryFile{}, fmt.Errorf("approval memory %s has unsupported version %d", m.Path, f.Version)
+	}
+	return f, nil
+}
+
+func (r Remembered) matches(args map[string]any) bool {
+	for key, pattern := range r.Patterns {
+		value, ok := args[key].(string)
+		if !ok {
+			return false
+		}
+		p, err := compileArgPattern(key, pattern)
+		if err != nil {
+			return false
+		}
+		if key == "path" {
+			value = filepath.Clean(value)
+		}
+		if !p.Match(value) {
+			return false
+		}
+	}
+	fingerprint, err := argsFingerprint(args, r.Patterns)
+	return err == nil && fingerprint == r.Fingerprint
+}
+
+// rememberable reports whether a single yes answers decision, the same rule
+// session grants follow.
+func rememberable(decision types.PolicyDecision) bool {
+	a := decision.Approval
+	return a == nil || (a.RequiredApprovers <= 1 && !a.RequireJustification)
+}
+
+// compileArgPattern compiles the glob for one argument: path semantics for
+// "path", plain text otherwise.
+func compileArgPattern(key, pattern string) (*glob.Pattern, error) {
+	if key == "path" {
+		return glob.CompilePath(pattern)
+	}
+	return glob.CompileText(pattern)
+}
+
+// argsFingerprint digests the arguments not covered by patterns. Paths are
+// cleaned first, so "./a.txt" and "a.txt" remember the same call. JSON
+// encoding sorts map keys, which makes the digest stable.
+func argsFingerprint(args map[string]any, patterns map[string]string) (string, error) {
+	normalized := make(map[string]any, len(args))
+	for key, value := range args {
+		if _, ok := patterns[key]; ok {
+			continue
+		}
+		if s, ok := value.(string); ok && key == "path" {
+			value = filepath.Clean(s)
+		}
+		normalized[key] = value
+	}
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func argsSummary(args map[string]any, patterns map[string]string, redactor *redact.Redactor) string {
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if pattern, ok := patterns[key]; ok {
+			parts = append(parts, fmt.Sprintf("%s~%q", key, pattern))
+			continue
+		}
+		parts = append(parts, key+"="+previewValue(redactor.RedactValue(args[key])))
+	}
+	return strings.Join(parts, " ")
+}
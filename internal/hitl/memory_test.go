@@ -0,0 +1,154 @@
+package hitl
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bridgekeeper/internal/types"
+)
+
+func newTestMemory(t *testing.T) *Memory {
+	t.Helper()
+	return NewMemory(filepath.Join(t.TempDir(), "config", "approvals.json"), "/work/a", "policy-1")
+}
+
+func TestMemory_RememberAndMatch(t *testing.T) {
+	m := newTestMemory(t)
+	decision := types.PolicyDecision{Decision: types.Ask, Rule: "write"}
+	call := types.ToolCall{Tool: "fs", Action: "write_file", Args: map[string]any{"path": "./docs/a.md", "content": "x"}}
+
+	entry, err := m.Remember(call, decision, "alice", types.RememberRequest{For: time.Hour})
+	if err != nil {
+		t.Fatalf("Remember() error = %v", err)
+	}
+	if got := entry.Expires.Sub(entry.Created); got != time.Hour {
+		t.Errorf("expiry = %v, want 1h", got)
+	}
+
+	tests := []struct {
+		name     string
+		memory   *Memory
+		call     types.ToolCall
+		decision types.PolicyDecision
+		want     bool
+	}{
+		{"same call", m, call, decision, true},
+		{"cleaned path", m, types.ToolCall{Tool: "fs", Action: "write_file", Args: map[string]any{"path": "docs/a.md", "content": "x"}}, decision, true},
+		{"different content", m, types.ToolCall{Tool: "fs", Action: "write_file", Args: map[string]any{"path": "docs/a.md", "content": "y"}}, decision, false},
+		{"different rule", m, call, types.PolicyDecision{Decision: types.Ask, Rule: "other"}, false},
+		{"other workspace", NewMemory(m.Path, "/work/b", "policy-1"), call, decision, false},
+		{"changed policy", NewMemory(m.Path, "/work/a", "policy-2"), call, decision, false},
+		{"justification required", m, call, types.PolicyDecision{Decision: types.Ask, Rule: "write", Approval: &types.ApprovalSettings{RequireJustification: true}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := tt.memory.Match(tt.call, tt.decision); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A fresh Memory reads what the first one wrote, including use counts.
+	entries, err := NewMemory(m.Path, "", "").List()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("List() = %v, %v; want one entry", entries, err)
+	}
+	if entries[0].Uses != 2 || entries[0].Approver != "alice" {
+		t.Errorf("entry = %+v, want 2 uses by alice", entries[0])
+	}
+	info, err := os.Stat(m.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("memory file mode = %o, want 600", perm)
+	}
+}
+
+func TestMemory_Patterns(t *testing.T) {
+	m := newTestMemory(t)
+	decision := types.PolicyDecision{Decision: types.Ask, Rule: "write"}
+	call := types.ToolCall{Tool: "fs", Action: "write_file", Args: map[string]any{"path": "docs/a.md", "mode": "append"}}
+
+	if _, err := m.Remember(call, decision, "", types.RememberRequest{Patterns: map[string]string{"path": "docs/**"}}); err != nil {
+		t.Fatalf("Remember() error = %v", err)
+	}
+	if _, ok := m.Match(types.ToolCall{Tool: "fs", Action: "write_file", Args: map[string]any{"path": "docs/guide/b.md", "mode": "append"}}, decision); !ok {
+		t.Error("expected pattern to cover another file under docs")
+	}
+	if _, ok := m.Match(types.ToolCall{Tool: "fs", Action: "write_file", Args: map[string]any{"path": "src/main.go", "mode": "append"}}, decision); ok {
+		t.Error("expected pattern not to cover src/main.go")
+	}
+	if _, ok := m.Match(types.ToolCall{Tool: "fs", Action: "write_file", Args: map[string]any{"path": "docs/b.md", "mode": "truncate"}}, decision); ok {
+		t.Error("expected unpatterned argument to require an exact match")
+	}
+
+	if _, err := m.Remember(call, decision, "", types.RememberRequest{Patterns: map[string]string{"url": "*"}}); err == nil {
+		t.Error("expected a pattern for a missing argument to be rejected")
+	}
+}
+
+func TestMemory_RevokeAndPrune(t *testing.T) {
+	m := newTestMemory(t)
+	decision := types.PolicyDecision{Decision: types.Ask, Rule: "read"}
+	keep, err := m.Remember(types.ToolCall{Tool: "fs", Action: "read_file", Args: map[string]any{"path": "a"}}, decision, "", types.RememberRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone, err := m.Remember(types.ToolCall{Tool: "fs", Action: "read_file", Args: map[string]any{"path": "b"}}, decision, "", types.RememberRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := m.Revoke(gone.ID); err != nil || !ok {
+		t.Fatalf("Revoke() = %v, %v; want true", ok, err)
+	}
+	if ok, _ := m.Revoke(gone.ID); ok {
+		t.Error("second Revoke() = true, want false")
+	}
+
+	// Age one entry past its expiry and record another under an old policy.
+	data, _ := os.ReadFile(m.Path)
+	var f memoryFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatal(err)
+	}
+	expired := f.Approvals[0]
+	expired.ID, expired.Expires = "old", time.Now().Add(-time.Minute)
+	stale := f.Approvals[0]
+	stale.ID, stale.PolicyHash = "stale", "policy-0"
+	f.Approvals = append(f.Approvals, expired, stale)
+	if err := writeFileAtomic(m.Path, f); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := m.Prune(false); err != nil || n != 1 {
+		t.Errorf("Prune(false) = %d, %v; want 1", n, err)
+	}
+	if n, err := m.Prune(true); err != nil || n != 1 {
+		t.Errorf("Prune(true) = %d, %v; want 1", n, err)
+	}
+	entries, _ := m.List()
+	if len(entries) != 1 || entries[0].ID != keep.ID {
+		t.Errorf("List() = %+v, want only %s", entries, keep.ID)
+	}
+}
+
+func TestMemory_CorruptFileFailsClosed(t *testing.T) {
+	m := newTestMemory(t)
+	if err := os.MkdirAll(filepath.Dir(m.Path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(m.Path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Match(types.ToolCall{Tool: "fs", Action: "read_file"}, types.PolicyDecision{Rule: "read"}); ok {
+		t.Error("Match() on a corrupt store = true, want false")
+	}
+	if _, err := m.Remember(types.ToolCall{Tool: "fs", Action: "read_file"}, types.PolicyDecision{Rule: "read"}, "", types.RememberRequest{}); err == nil {
+		t.Error("Remember() on a corrupt store succeeded; want error")
+	}
+}
@@ -0,0 +1,262 @@
+package hitl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bridgekeeper/internal/types"
+)
+
+// Approver answers ask decisions. It has the same shape as runtime.Approver so
+// the approvers in this package, including Quorum and Chain, can be composed
+// and handed to the mediator.
+type Approver interface {
+	Approve(context.Context, types.ToolCall, types.PolicyDecision) (types.ApprovalResult, error)
+}
+
+// Member is a named approval channel taking part in a Quorum or Chain, such as
+// "terminal", "web", or "webhook".
+type Member struct {
+	Name     string
+	Approver Approver
+}
+
+// Quorum asks all of its members at once and approves a call when enough of
+// them approve: the policy's required_approvers, or Required when that is
+// larger, and at least one. Approvals are counted per distinct approver
+// identity, so one person answering on two channels counts once. As soon as
+// the outcome is settled the remaining members are cancelled.
+//
+// Members see the decision with required_approvers cleared, since each of
+// them casts a single vote. A member that fails votes no; a member that
+// approves with edited arguments also votes no, because the other members
+// approved the original call.
+type Quorum struct {
+	Members  []Member
+	Required int
+}
+
+// Chain asks its members one after another, and approves a call only when
+// every member approves it. A member that approves with edited arguments
+// passes the edited call on to the members after it. When the policy requires
+// several approvers, the chain must also collect that many distinct
+// identities.
+type Chain struct {
+	Members []Member
+}
+
+// NewQuorum returns a quorum over members requiring at least required
+// approvals.
+func NewQuorum(required int, members ...Member) (*Quorum, error) {
+	if err := checkMembers(members); err != nil {
+		return nil, err
+	}
+	if required > len(members) {
+		return nil, fmt.Errorf("quorum of %d cannot be reached by %d members", required, len(members))
+	}
+	return &Quorum{Members: members, Required: required}, nil
+}
+
+// NewChain returns a chain asking members in order.
+func NewChain(members ...Member) (*Chain, error) {
+	if err := checkMembers(members); err != nil {
+		return nil, err
+	}
+	return &Chain{Members: members}, nil
+}
+
+func checkMembers(members []Member) error {
+	if len(members) == 0 {
+		return errors.New("at least one approver is required")
+	}
+	seen := make(map[string]bool, len(members))
+	for _, m := range members {
+		if m.Name == "" || m.Approver == nil {
+			return errors.New("each approver needs a name and an implementation")
+		}
+		if seen[m.Name] {
+			return fmt.Errorf("approver %q is listed twice", m.Name)
+		}
+		seen[m.Name] = true
+	}
+	return nil
+}
+
+// Approve collects votes until the quorum is reached or can no longer be
+// reached. It returns an error only when ctx ends or a member asks to stop the
+// turn.
+func (q *Quorum) Approve(ctx context.Context, call types.ToolCall, decision types.PolicyDecision) (types.ApprovalResult, error) {
+	required := max(q.Required, 1)
+	if decision.Approval != nil {
+		required = max(required, decision.Approval.RequiredApprovers)
+	}
+	if required > len(q.Members) {
+		return types.ApprovalResult{
+			Comment: fmt.Sprintf("policy requires %d approvers but only %d are configured", required, len(q.Members)),
+		}, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type answer struct {
+		member string
+		result types.ApprovalResult
+		err    error
+	}
+	answers := make(chan answer, len(q.Members))
+	memberDecision := singleVote(decision)
+	for _, m := range q.Members {
+		go func() {
+			result, err := m.Approver.Approve(ctx, call, memberDecision)
+			answers <- answer{member: m.Name, result: result, err: err}
+		}()
+	}
+
+	var tally tally
+	for pending := len(q.Members); pending > 0; pending-- {
+		a := <-answers
+		if errors.Is(a.err, ErrStopTurn) {
+			return types.ApprovalResult{}, ErrStopTurn
+		}
+		if a.err != nil && ctx.Err() != nil {
+			return types.ApprovalResult{}, ctx.Err()
+		}
+		if a.err == nil && a.result.Approved && a.result.Args != nil {
+			a.result.Approved = false
+			a.result.Comment = "edited arguments cannot be approved by a quorum"
+		}
+		tally.add(a.member, a.result, a.err)
+
+		switch {
+		case tally.approvals() >= required:
+			return tally.result(true, ""), nil
+		case tally.approvals()+pending-1 < required:
+			return tally.result(false, fmt.Sprintf("quorum not reached: %d of %d required approvals", tally.approvals(), required)), nil
+		}
+	}
+	return tally.result(false, "quorum not reached"), nil
+}
+
+// Approve asks each member in turn, stopping at the first that does not
+// approve.
+func (c *Chain) Approve(ctx context.Context, call types.ToolCall, decision types.PolicyDecision) (types.ApprovalResult, error) {
+	var tally tally
+	var edited map[string]any
+	memberDecision := singleVote(decision)
+	for _, m := range c.Members {
+		result, err := m.Approver.Approve(ctx, call, memberDecision)
+		if errors.Is(err, ErrStopTurn) {
+			return types.ApprovalResult{}, ErrStopTurn
+		}
+		if err != nil && ctx.Err() != nil {
+			return types.ApprovalResult{}, ctx.Err()
+		}
+		tally.add(m.Name, result, err)
+		if err != nil || !result.Approved {
+			return tally.result(false, fmt.Sprintf("denied at %s", m.Name)), nil
+		}
+		if result.Args != nil {
+			edited = result.Args
+			call.Args = result.Args
+		}
+	}
+
+	if decision.Approval != nil && tally.approvals() < decision.Approval.RequiredApprovers {
+		return tally.result(false, fmt.Sprintf("chain collected %d of %d required distinct approvers", tally.approvals(), decision.Approval.RequiredApprovers)), nil
+	}
+	out := tally.result(true, "")
+	out.Args = edited
+	return out, nil
+}
+
+// singleVote returns decision as a member of a multi-party approval sees it:
+// one answer is expected of each member.
+func singleVote(decision types.PolicyDecision) types.PolicyDecision {
+	if decision.Approval == nil || decision.Approval.RequiredApprovers <= 1 {
+		return decision
+	}
+	settings := *decision.Approval
+	settings.RequiredApprovers = 0
+	decision.Approval = &settings
+	return decision
+}
+
+// tally collects the votes of a multi-party approval.
+type tally struct {
+	votes          []types.Vote
+	approvers      []string        // distinct approving identities, in order
+	seen           map[string]bool // lower-cased approving identities
+	justifications []string
+	comments       []string
+}
+
+// add records one member's answer. A member that is itself a Quorum or Chain
+// contributes its own votes to the trail but a single vote to the count.
+func (t *tally) add(member string, result types.ApprovalResult, err error) {
+	now := time.Now().UTC()
+	if err != nil {
+		t.votes = append(t.votes, types.Vote{Member: member, Error: err.Error(), Time: now})
+		return
+	}
+	if len(result.Votes) > 0 {
+		for _, v := range result.Votes {
+			v.Member = member + "/" + v.Member
+			t.votes = append(t.votes, v)
+		}
+	} else {
+		t.votes = append(t.votes, types.Vote{
+			Member:   member,
+			Approver: result.Approver,
+			Approved: result.Approved,
+			Comment:  result.Comment,
+			Time:     now,
+		})
+	}
+	if result.Comment != "" {
+		t.comments = append(t.comments, member+": "+result.Comment)
+	}
+	if !result.Approved {
+		return
+	}
+
+	identity := result.Approver
+	if identity == "" || identity == "auto" {
+		identity = member
+	}
+	if t.seen == nil {
+		t.seen = map[string]bool{}
+	}
+	if t.seen[strings.ToLower(identity)] {
+		return
+	}
+	t.seen[strings.ToLower(identity)] = true
+	t.approvers = append(t.approvers, identity)
+	if result.Justification != "" {
+		t.justifications = append(t.justifications, result.Justification)
+	}
+}
+
+func (t *tally) approvals() int {
+	return len(t.approvers)
+}
+
+func (t *tally) result(approved bool, note string) types.ApprovalResult {
+	comments := t.comments
+	if note != "" {
+		comments = append([]string{note}, comments...)
+	}
+	out := types.ApprovalResult{
+		Approved: approved,
+		Comment:  strings.Join(comments, "; "),
+		Votes:    t.votes,
+	}
+	if approved {
+		out.Approver = strings.Join(t.approvers, ", ")
+		out.Justification = strings.Join(t.justifications, "; ")
+	}
+	return out
+}
@@ -0,0 +1,235 @@
+package hitl
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"bridgekeeper/internal/types"
+)
+
+// voter answers with a fixed result after an optional delay, or when ctx ends.
+type voter struct {
+	result types.ApprovalResult
+	err    error
+	delay  time.Duration
+	seen   *types.PolicyDecision
+}
+
+func (v voter) Approve(ctx context.Context, _ types.ToolCall, decision types.PolicyDecision) (types.ApprovalResult, error) {
+	if v.seen != nil {
+		*v.seen = decision
+	}
+	select {
+	case <-time.After(v.delay):
+		return v.result, v.err
+	case <-ctx.Done():
+		return types.ApprovalResult{}, ctx.Err()
+	}
+}
+
+func yes(name string) voter {
+	return voter{result: types.ApprovalResult{Approved: true, Approver: name}}
+}
+func no(name string) voter { return voter{result: types.ApprovalResult{Approver: name, Comment: "no"}} }
+
+// after delays v's answer, so tests can fix the order votes arrive in.
+func (v voter) after(d time.Duration) voter {
+	v.delay = d
+	return v
+}
+
+func askWith(required int) types.PolicyDecision {
+	return types.PolicyDecision{Decision: types.Ask, Rule: "http-post", Approval: &types.ApprovalSettings{RequiredApprovers: required}}
+}
+
+func TestQuorum(t *testing.T) {
+	never := voter{delay: time.Hour}
+	tests := []struct {
+		name     string
+		required int
+		members  []Member
+		want     bool
+		approver string
+		votes    int
+	}{
+		{
+			name:     "two of three",
+			required: 2,
+			members:  []Member{{"terminal", yes("alice")}, {"web", no("bob").after(10 * time.Millisecond)}, {"webhook", yes("carol").after(20 * time.Millisecond)}},
+			want:     true,
+			approver: "alice, carol",
+			votes:    3,
+		},
+		{
+			name:     "settles without waiting for the last member",
+			required: 2,
+			members:  []Member{{"terminal", yes("alice")}, {"web", yes("bob").after(10 * time.Millisecond)}, {"webhook", never}},
+			want:     true,
+			approver: "alice, bob",
+			votes:    2,
+		},
+		{
+			name:     "denials make the quorum unreachable",
+			required: 2,
+			members:  []Member{{"terminal", no("alice")}, {"web", no("bob")}, {"webhook", never}},
+			want:     false,
+			votes:    2,
+		},
+		{
+			name:     "same person on two channels counts once",
+			required: 2,
+			members:  []Member{{"terminal", yes("alice")}, {"web", yes("Alice")}},
+			want:     false,
+			votes:    2,
+		},
+		{
+			name:     "failing member votes no",
+			required: 1,
+			members:  []Member{{"webhook", voter{err: errors.New("unreachable")}}, {"web", yes("bob").after(10 * time.Millisecond)}},
+			want:     true,
+			approver: "bob",
+			votes:    2,
+		},
+		{
+			name:     "edited approval votes no",
+			required: 1,
+			members:  []Member{{"terminal", voter{result: types.ApprovalResult{Approved: true, Args: map[string]any{"url": "x"}}}}},
+			want:     false,
+			votes:    1,
+		},
+		{
+			name:     "more approvers required than configured",
+			required: 3,
+			members:  []Member{{"terminal", yes("alice")}, {"web", yes("bob")}},
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &Quorum{Members: tt.members}
+			got, err := q.Approve(context.Background(), types.ToolCall{Tool: "http", Action: "post"}, askWith(tt.required))
+			if err != nil {
+				t.Fatalf("Approve() error = %v", err)
+			}
+			if got.Approved != tt.want {
+				t.Errorf("Approved = %v, want %v (%+v)", got.Approved, tt.want, got)
+			}
+			if got.Approver != tt.approver {
+				t.Errorf("Approver = %q, want %q", got.Approver, tt.approver)
+			}
+			if len(got.Votes) != tt.votes {
+				t.Errorf("votes = %+v, want %d", got.Votes, tt.votes)
+			}
+			for _, v := range got.Votes {
+				if v.Member == "" || v.Time.IsZero() {
+					t.Errorf("vote %+v lacks member or time", v)
+				}
+			}
+		})
+	}
+}
+
+func TestQuorum_MembersCastSingleVotes(t *testing.T) {
+	var seen types.PolicyDecision
+	q := &Quorum{Members: []Member{{"terminal", voter{result: types.ApprovalResult{Approved: true}, seen: &seen}}, {"web", yes("bob")}}}
+	decision := askWith(2)
+
+	if _, err := q.Approve(context.Background(), types.ToolCall{}, decision); err != nil {
+		t.Fatal(err)
+	}
+	if seen.Approval.RequiredApprovers != 0 {
+		t.Errorf("member saw required_approvers = %d, want 0", seen.Approval.RequiredApprovers)
+	}
+	if decision.Approval.RequiredApprovers != 2 {
+		t.Error("caller's decision was modified")
+	}
+}
+
+func TestQuorum_StopTurnAndCancel(t *testing.T) {
+	q := &Quorum{Members: []Member{{"terminal", voter{err: ErrStopTurn}}, {"web", voter{delay: time.Hour}}}}
+	if _, err := q.Approve(context.Background(), types.ToolCall{}, askWith(1)); !errors.Is(err, ErrStopTurn) {
+		t.Errorf("Approve() error = %v, want ErrStopTurn", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	q = &Quorum{Members: []Member{{"web", voter{delay: time.Hour}}}}
+	if _, err := q.Approve(ctx, types.ToolCall{}, askWith(1)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Approve() error = %v, want deadline exceeded", err)
+	}
+}
+
+// editor approves with a fixed argument change.
+type editor struct{ key, value string }
+
+func (e editor) Approve(_ context.Context, call types.ToolCall, _ types.PolicyDecision) (types.ApprovalResult, error) {
+	args := map[string]any{}
+	for k, v := range call.Args {
+		args[k] = v
+	}
+	args[e.key] = e.value
+	return types.ApprovalResult{Approved: true, Approver: e.key, Args: args}, nil
+}
+
+func TestChain(t *testing.T) {
+	call := types.ToolCall{Tool: "pkg", Action: "install", Args: map[string]any{"name": "left-pad"}}
+
+	c := &Chain{Members: []Member{{"terminal", editor{"version", "1.3.0"}}, {"webhook", editor{"registry", "internal"}}}}
+	got, err := c.Approve(context.Background(), call, askWith(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Approved || got.Args["version"] != "1.3.0" || got.Args["registry"] != "internal" {
+		t.Errorf("Approve() = %+v, want approval with both edits", got)
+	}
+
+	c = &Chain{Members: []Member{{"terminal", yes("alice")}, {"webhook", no("sec")}, {"web", yes("bob")}}}
+	got, err = c.Approve(context.Background(), call, askWith(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Approved || len(got.Votes) != 2 || !strings.Contains(got.Comment, "denied at webhook") {
+		t.Errorf("Approve() = %+v, want denial at the second member", got)
+	}
+
+	c = &Chain{Members: []Member{{"terminal", yes("alice")}, {"web", yes("alice")}}}
+	if got, _ := c.Approve(context.Background(), call, askWith(2)); got.Approved {
+		t.Errorf("Approve() = %+v, want denial for a single distinct approver", got)
+	}
+}
+
+func TestChain_NestedQuorumVotes(t *testing.T) {
+	inner := &Quorum{Members: []Member{{"web", yes("bob")}, {"webhook", yes("carol").after(10 * time.Millisecond)}}, Required: 2}
+	c := &Chain{Members: []Member{{"terminal", yes("alice")}, {"security", inner}}}
+
+	got, err := c.Approve(context.Background(), types.ToolCall{}, askWith(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Approved {
+		t.Fatalf("Approve() = %+v, want approval", got)
+	}
+	var members []string
+	for _, v := range got.Votes {
+		members = append(members, v.Member)
+	}
+	if strings.Join(members, ",") != "terminal,security/web,security/webhook" {
+		t.Errorf("vote members = %v", members)
+	}
+}
+
+func TestNewQuorum_Validates(t *testing.T) {
+	if _, err := NewQuorum(1); err == nil {
+		t.Error("expected empty quorum to be rejected")
+	}
+	if _, err := NewQuorum(1, Member{"web", yes("a")}, Member{"web", yes("b")}); err == nil {
+		t.Error("expected duplicate member names to be rejected")
+	}
+	if _, err := NewQuorum(3, Member{"web", yes("a")}, Member{"terminal", yes("b")}); err == nil {
+		t.Error("expected unreachable quorum to be rejected")
+	}
+}
@@ -0,0 +1,204 @@
+package hitl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"bridgekeeper/internal/redact"
+	"bridgekeeper/internal/sanitize"
+	"bridgekeeper/internal/types"
+)
+
+const (
+	// maxPreviewFileBytes caps how much of an existing file is read to diff a
+	// proposed write against.
+	maxPreviewFileBytes = 1 << 20
+	// maxArgPreview caps how much of a single argument value is shown inline.
+	maxArgPreview = 200
+	// diffContextLines is the number of unchanged lines shown around a change.
+	diffContextLines = 3
+)
+
+// RenderRequest formats a pending tool call for a human reviewer: the tool,
+// the matched rule and reason, approval requirements, and a tool-specific view
+// of the arguments. Every argument and content line is passed through
+// redactor, which may be nil, and terminal control sequences are removed so
+// that the arguments cannot redraw the prompt around them.
+func RenderRequest(call types.ToolCall, decision types.PolicyDecision, redactor *redact.Redactor) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tool call requires approval\n")
+	fmt.Fprintf(&b, "  Tool:   %s/%s\n", call.Tool, call.Action)
+	fmt.Fprintf(&b, "  Rule:   %s\n", valueOr(decision.Rule, "(none)"))
+	fmt.Fprintf(&b, "  Reason: %s\n", valueOr(decision.Reason, "(none)"))
+	if line := approvalSummary(decision.Approval); line != "" {
+		fmt.Fprintf(&b, "  Approval: %s\n", line)
+	}
+
+	shown := map[string]bool{}
+	switch {
+	case call.Tool == "fs" && call.Action == "write_file":
+		renderWrite(&b, call.Args, redactor)
+		shown["path"], shown["content"] = true, true
+	case call.Tool == "http":
+		renderHTTP(&b, call.Args, redactor)
+		shown["url"], shown["body"], shown["payload"] = true, true, true
+	case call.Tool == "git":
+		if args, ok := stringList(call.Args["args"]); ok {
+			fmt.Fprintf(&b, "  Command: git %s\n", redactor.RedactText(strings.Join(args, " ")))
+			shown["args"] = true
+		}
+	case call.Tool == "shell":
+		if cmd, ok := call.Args["command"].(string); ok {
+			fmt.Fprintf(&b, "  Command: %s\n", redactor.RedactText(cmd))
+			shown["command"] = true
+		}
+	}
+
+	renderRemainingArgs(&b, call.Args, shown, redactor)
+	out, _ := sanitize.Text(b.String())
+	return out
+}
+
+func renderWrite(b *strings.Builder, args map[string]any, redactor *redact.Redactor) {
+	path, _ := args["path"].(string)
+	content, _ := args["content"].(string)
+	fmt.Fprintf(b, "  Path:   %s\n", redactor.RedactText(path))
+	fmt.Fprintf(b, "  Size:   %d bytes\n", len(content))
+
+	current, note := readCurrent(path)
+	if note != "" {
+		fmt.Fprintf(b, "  %s\n", note)
+	}
+	// Redact both sides whole before diffing, so secrets spanning several
+	// lines, such as private key blocks, are caught.
+	current, content = redactor.RedactText(current), redactor.RedactText(content)
+	name := redactor.RedactText(path)
+	diff := unifiedDiff(name, name+" (proposed)", current, content, diffContextLines)
+	if diff == "" {
+		fmt.Fprintf(b, "  Diff:   (no changes)\n")
+		return
+	}
+	fmt.Fprintf(b, "  Diff:\n")
+	for _, line := range splitLines(diff) {
+		fmt.Fprintf(b, "    %s\n", line)
+	}
+}
+
+// readCurrent returns the current contents of path for diffing, or "" with a
+// note explaining why they are not shown.
+func readCurrent(path string) (string, string) {
+	if path == "" {
+		return "", ""
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", "(new file)"
+	}
+	if err != nil {
+		return "", fmt.Sprintf("(cannot read current file: %v)", err)
+	}
+	if info.Size() > maxPreviewFileBytes {
+		return "", fmt.Sprintf("(current file is %d bytes; diffing against empty)", info.Size())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Sprintf("(cannot read current file: %v)", err)
+	}
+	return string(data), ""
+}
+
+func renderHTTP(b *strings.Builder, args map[string]any, redactor *redact.Redactor) {
+	if url, ok := args["url"].(string); ok {
+		fmt.Fprintf(b, "  URL:    %s\n", redactor.RedactText(url))
+	}
+	for _, key := range []string{"body", "payload"} {
+		body, ok := args[key].(string)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(b, "  Body:   %d bytes\n", len(body))
+		for _, line := range splitLines(redactor.RedactText(body)) {
+			fmt.Fprintf(b, "    %s\n", line)
+		}
+	}
+}
+
+func renderRemainingArgs(b *strings.Builder, args map[string]any, shown map[string]bool, redactor *redact.Redactor) {
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		if !shown[key] {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(b, "  Arguments:\n")
+	for _, key := range keys {
+		fmt.Fprintf(b, "    %s: %s\n", key, previewValue(redactor.RedactValue(args[key])))
+	}
+}
+
+func previewValue(v any) string {
+	var s string
+	if str, ok := v.(string); ok {
+		s = fmt.Sprintf("%q", str)
+	} else if data, err := json.Marshal(v); err == nil {
+		s = string(data)
+	} else {
+		s = fmt.Sprint(v)
+	}
+	if len(s) > maxArgPreview {
+		s = fmt.Sprintf("%s... (%d more bytes)", s[:maxArgPreview], len(s)-maxArgPreview)
+	}
+	return s
+}
+
+func approvalSummary(a *types.ApprovalSettings) string {
+	if a == nil {
+		return ""
+	}
+	var parts []string
+	if a.TimeoutSeconds > 0 {
+		parts = append(parts, fmt.Sprintf("answer within %ds or the call is denied", a.TimeoutSeconds))
+	}
+	if a.RequiredApprovers > 1 {
+		parts = append(parts, fmt.Sprintf("%d approvers required", a.RequiredApprovers))
+	}
+	if a.RequireJustification {
+		parts = append(parts, "justification required")
+	}
+	return strings.Join(parts, "; ")
+}
+
+func stringList(v any) ([]string, bool) {
+	switch list := v.(type) {
+	case []string:
+		return list, true
+	case []any:
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			out = append(out, s)
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+func valueOr(value, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,190 @@
+package hitl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"bridgekeeper/internal/redact"
+	"bridgekeeper/internal/types"
+)
+
+// defaultSpoolPoll is how often SpoolApprover checks for a response file.
+const defaultSpoolPoll = time.Second
+
+// SpoolApprover writes each pending call to <Dir>/<id>.request.json and polls
+// for <id>.approved or <id>.denied written by an external reviewer. A response
+// file may hold an ApprovalResponse as JSON or a plain-text comment. When both
+// files appear, or none appears before the timeout, the call is denied.
+type SpoolApprover struct {
+	Dir          string
+	PollInterval time.Duration // one second when zero
+	Timeout      time.Duration // five minutes when zero
+	Redactor     *redact.Redactor
+}
+
+// NewSpoolApprover returns an approver spooling requests into dir, creating it
+// with owner-only permissions if needed. Anyone who can write to dir can
+// approve calls, so dir must not lie inside workspace, where the agent's own
+// file writes could drop a response, and must not be writable by group or
+// others.
+func NewSpoolApprover(dir, workspace string) (*SpoolApprover, error) {
+	if dir == "" {
+		return nil, errors.New("spool directory is required")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve spool directory: %w", err)
+	}
+	if err := os.MkdirAll(abs, 0o700); err != nil {
+		return nil, fmt.Errorf("create spool directory: %w", err)
+	}
+	if workspace != "" && within(abs, workspace) {
+		return nil, fmt.Errorf("spool directory %s is inside the workspace %s", dir, workspace)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("check spool directory: %w", err)
+	}
+	if info.Mode().Perm()&0o022 != 0 {
+		return nil, fmt.Errorf("spool directory %s is writable by group or others (mode %#o)", dir, info.Mode().Perm())
+	}
+	return &SpoolApprover{Dir: abs}, nil
+}
+
+// within reports whether path is root or lies beneath it, comparing the
+// targets of any symbolic links.
+func within(path, root string) bool {
+	for _, p := range []*string{&path, &root} {
+		if resolved, err := filepath.EvalSymlinks(*p); err == nil {
+			*p = resolved
+		}
+	}
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Approve writes the request file and waits for a response file, ctx to end,
+// or the approver's timeout. The request and response files are removed when
+// it returns.
+func (s *SpoolApprover) Approve(ctx context.Context, call types.ToolCall, decision types.PolicyDecision) (types.ApprovalResult, error) {
+	deadline := headlessDeadline(ctx, s.Timeout)
+	req, err := newApprovalRequest(call, decision, s.Redactor, deadline)
+	if err != nil {
+		return denial("cannot create approval request: " + err.Error()), nil
+	}
+
+	requestPath := s.path(req.ID, ".request.json")
+	approvedPath := s.path(req.ID, ".approved")
+	deniedPath := s.path(req.ID, ".denied")
+	defer func() {
+		for _, path := range []string{requestPath, approvedPath, deniedPath} {
+			_ = os.Remove(path)
+		}
+	}()
+
+	if err := writeFileAtomic(requestPath, req); err != nil {
+		return denial("cannot write approval request: " + err.Error()), nil
+	}
+
+	interval := s.PollInterval
+	if interval <= 0 {
+		interval = defaultSpoolPoll
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	for {
+		if result, ok := s.poll(req.ID, approvedPath, deniedPath, decision.Approval); ok {
+			return result, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return types.ApprovalResult{}, ctx.Err()
+		case <-timer.C:
+			return denial("no spool response before the approval deadline"), nil
+		}
+	}
+}
+
+// poll reports the reviewer's answer once a response file exists.
+func (s *SpoolApprover) poll(id, approvedPath, deniedPath string, settings *types.ApprovalSettings) (types.ApprovalResult, bool) {
+	approved, approvedErr := os.ReadFile(approvedPath)
+	denied, deniedErr := os.ReadFile(deniedPath)
+	hasApproved, hasDenied := approvedErr == nil, deniedErr == nil
+
+	switch {
+	case hasApproved && hasDenied:
+		return denial("both approved and denied response files were written"), true
+	case hasDenied:
+		resp := parseSpoolResponse(id, denied)
+		resp.Approved = false
+		return resp.result(settings), true
+	case hasApproved:
+		resp := parseSpoolResponse(id, approved)
+		if resp.ID != id {
+			return denial("response file names a different request"), true
+		}
+		resp.Approved = true
+		return resp.result(settings), true
+	}
+	for _, err := range []error{approvedErr, deniedErr} {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return denial("cannot read spool response: " + err.Error()), true
+		}
+	}
+	return types.ApprovalResult{}, false
+}
+
+// parseSpoolResponse accepts a JSON ApprovalResponse or, failing that, treats
+// the file as a plain-text comment. The file name decides approval; a JSON
+// "approved" field is ignored.
+func parseSpoolResponse(id string, data []byte) ApprovalResponse {
+	var resp ApprovalResponse
+	if err := json.Unmarshal(data, &resp); err == nil {
+		if resp.ID == "" {
+			resp.ID = id
+		}
+		return resp
+	}
+	return ApprovalResponse{ID: id, Comment: strings.TrimSpace(string(data))}
+}
+
+func (s *SpoolApprover) path(id, suffix string) string {
+	return filepath.Join(s.Dir, id+suffix)
+}
+
+// writeFileAtomic writes v as JSON to path via a temporary file in the same
+// directory, so readers never see a partial file.
+func writeFileAtomic(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,191 @@
+package hitl
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"bridgekeeper/internal/types"
+)
+
+func newTestSpool(t *testing.T) *SpoolApprover {
+	t.Helper()
+	s, err := NewSpoolApprover(filepath.Join(t.TempDir(), "spool"), t.TempDir())
+	if err != nil {
+		t.Fatalf("NewSpoolApprover() error = %v", err)
+	}
+	s.PollInterval = 5 * time.Millisecond
+	s.Timeout = 2 * time.Second
+	return s
+}
+
+// respondToSpool waits for a request file in dir and writes the named
+// response files next to it.
+func respondToSpool(t *testing.T, dir string, responses map[string]string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*.request.json"))
+		if len(matches) == 1 {
+			data, err := os.ReadFile(matches[0])
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var req ApprovalRequest
+			if err := json.Unmarshal(data, &req); err != nil {
+				t.Errorf("decode request: %v", err)
+				return
+			}
+			for suffix, content := range responses {
+				if err := os.WriteFile(filepath.Join(dir, req.ID+suffix), []byte(content), 0o600); err != nil {
+					t.Error(err)
+				}
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("timed out waiting for a spooled request")
+}
+
+func TestSpoolApprover(t *testing.T) {
+	call := types.ToolCall{Tool: "fs", Action: "write_file", Args: map[string]any{"path": "notes.txt", "content": "hi"}}
+	decision := types.PolicyDecision{Decision: types.Ask, Rule: "write-files"}
+
+	tests := []struct {
+		name      string
+		responses map[string]string
+		settings  *types.ApprovalSettings
+		want      types.ApprovalResult
+	}{
+		{
+			name:      "plain text approval",
+			responses: map[string]string{".approved": "looks fine\n"},
+			want:      types.ApprovalResult{Approved: true, Comment: "looks fine"},
+		},
+		{
+			name:      "json denial",
+			responses: map[string]string{".denied": `{"approver":"bob","comment":"no"}`},
+			want:      types.ApprovalResult{Approved: false, Approver: "bob", Comment: "no"},
+		},
+		{
+			name:      "json approval with justification",
+			responses: map[string]string{".approved": `{"approver":"alice","justification":"ticket 12"}`},
+			settings:  &types.ApprovalSettings{RequireJustification: true},
+			want:      types.ApprovalResult{Approved: true, Approver: "alice", Justification: "ticket 12"},
+		},
+		{
+			name:      "missing justification",
+			responses: map[string]string{".approved": "ok"},
+			settings:  &types.ApprovalSettings{RequireJustification: true},
+			want:      types.ApprovalResult{Approved: false, Comment: "approval rejected: justification required"},
+		},
+		{
+			name:      "both files",
+			responses: map[string]string{".approved": "", ".denied": ""},
+			want:      types.ApprovalResult{Approved: false, Comment: "both approved and denied response files were written"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSpool(t)
+			if len(tt.responses) > 1 {
+				// Write both files before the first poll can see either.
+				s.PollInterval = 200 * time.Millisecond
+			}
+			go respondToSpool(t, s.Dir, tt.responses)
+
+			d := decision
+			d.Approval = tt.settings
+			got, err := s.Approve(context.Background(), call, d)
+			if err != nil {
+				t.Fatalf("Approve() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Approve() = %+v, want %+v", got, tt.want)
+			}
+			if entries, _ := os.ReadDir(s.Dir); len(entries) != 0 {
+				t.Errorf("spool directory not cleaned up: %d entries left", len(entries))
+			}
+		})
+	}
+}
+
+func TestSpoolApprover_TimeoutDenies(t *testing.T) {
+	s := newTestSpool(t)
+	s.Timeout = 30 * time.Millisecond
+
+	got, err := s.Approve(context.Background(), types.ToolCall{Tool: "fs", Action: "read_file"}, types.PolicyDecision{Decision: types.Ask})
+	if err != nil {
+		t.Fatalf("Approve() error = %v", err)
+	}
+	if got.Approved || !strings.Contains(got.Comment, "deadline") {
+		t.Errorf("Approve() = %+v, want timeout denial", got)
+	}
+}
+
+func TestSpoolApprover_RequestFile(t *testing.T) {
+	s := newTestSpool(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Approve(ctx, types.ToolCall{Tool: "http", Action: "get", Args: map[string]any{"url": "https://example.com"}}, types.PolicyDecision{Decision: types.Ask})
+		done <- err
+	}()
+
+	var info os.FileInfo
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		matches, _ := filepath.Glob(filepath.Join(s.Dir, "*.request.json"))
+		if len(matches) == 1 {
+			info, _ = os.Stat(matches[0])
+			break
+		}
+	}
+	if info == nil {
+		t.Fatal("request file was not written")
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("request file mode = %o, want 600", perm)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Approve() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestNewSpoolApprover_RefusesUnsafeDirectories(t *testing.T) {
+	workspace := t.TempDir()
+	if _, err := NewSpoolApprover(filepath.Join(workspace, ".approvals"), workspace); err == nil {
+		t.Error("spool inside the workspace was accepted")
+	}
+	if _, err := NewSpoolApprover(workspace, workspace); err == nil {
+		t.Error("the workspace itself was accepted as the spool")
+	}
+
+	shared := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(shared, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSpoolApprover(shared, workspace); err == nil {
+		t.Error("world-writable spool was accepted")
+	}
+
+	sibling := workspace + "-spool"
+	t.Cleanup(func() { os.RemoveAll(sibling) })
+	if _, err := NewSpoolApprover(sibling, workspace); err != nil {
+		t.Errorf("spool beside the workspace: %v", err)
+	}
+}
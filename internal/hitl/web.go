@@ -0,0 +1,361 @@
+package hitl
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bridgekeeper/internal/redact"
+	"bridgekeeper/internal/types"
+)
+
+//go:embed web.html
+var webPage []byte
+
+// maxDecisionBytes caps the size of a decision request body.
+const maxDecisionBytes = 64 << 10
+
+// WebApprover serves pending approvals over HTTP on a loopback address so a
+// reviewer can answer them from a browser when no terminal is available.
+// The URL printed at startup carries a one-time token. Opening it exchanges
+// the token for an HttpOnly session cookie and redirects to a clean address,
+// so the token is spent before it can leak from history or logs, and every
+// other page and API request must carry the session cookie.
+type WebApprover struct {
+	Redactor *redact.Redactor
+
+	listener net.Listener
+	server   *http.Server
+
+	mu      sync.Mutex
+	token   string // one-time login token; empty once spent
+	session string // session cookie value; empty until the token is spent
+	nextID  int
+	pending map[string]*webPending
+}
+
+// webSessionCookie names the cookie holding the reviewer's session.
+const webSessionCookie = "bridgekeeper_session"
+
+// webPending is one call waiting for a reviewer.
+type webPending struct {
+	id       string
+	call     types.ToolCall
+	decision types.PolicyDecision
+	preview  string
+	created  time.Time
+	approved []string // reviewers who approved so far
+	decided  bool
+	result   chan types.ApprovalResult
+}
+
+// PendingApproval is the JSON view of a pending call served by /api/pending.
+type PendingApproval struct {
+	ID       string                  `json:"id"`
+	Tool     string                  `json:"tool"`
+	Action   string                  `json:"action"`
+	Rule     string                  `json:"rule"`
+	Reason   string                  `json:"reason"`
+	Preview  string                  `json:"preview"`
+	Created  time.Time               `json:"created"`
+	Approval *types.ApprovalSettings `json:"approval,omitempty"`
+	Approved []string                `json:"approved,omitempty"`
+}
+
+// WebDecision is the body accepted by /api/decide.
+type WebDecision struct {
+	ID            string `json:"id"`
+	Approved      bool   `json:"approved"`
+	Reviewer      string `json:"reviewer"`
+	Comment       string `json:"comment,omitempty"`
+	Justification string `json:"justification,omitempty"`
+}
+
+// NewWebApprover listens on addr, which must be a loopback address such as
+// "127.0.0.1:0", and starts serving. Call Close to stop.
+func NewWebApprover(addr string) (*WebApprover, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("web approver address: %w", err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("web approver must listen on a loopback address, got %q", host)
+	}
+
+	token, err := randomHex()
+	if err != nil {
+		return nil, fmt.Errorf("generate web approver token: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WebApprover{
+		token:    token,
+		listener: listener,
+		pending:  make(map[string]*webPending),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", w.handlePage)
+	mux.HandleFunc("GET /api/pending", w.handlePending)
+	mux.HandleFunc("POST /api/decide", w.handleDecide)
+	w.server = &http.Server{
+		Handler:           w.guard(mux),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() { _ = w.server.Serve(listener) }()
+	return w, nil
+}
+
+// URL returns the address of the approval page including the one-time
+// token. It works once; it is empty after the token was spent.
+func (w *WebApprover) URL() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.token == "" {
+		return ""
+	}
+	return fmt.Sprintf("http://%s/?token=%s", w.listener.Addr(), w.token)
+}
+
+// Close stops the server. Calls still waiting for an answer are denied when
+// their contexts end.
+func (w *WebApprover) Close() error {
+	return w.server.Close()
+}
+
+// Approve publishes call as pending and waits for a reviewer's decision or for
+// ctx to end. When the policy requires several approvers, each approval must
+// come from a distinct reviewer and any denial ends the wait.
+func (w *WebApprover) Approve(ctx context.Context, call types.ToolCall, decision types.PolicyDecision) (types.ApprovalResult, error) {
+	p := &webPending{
+		call:     call,
+		decision: decision,
+		preview:  RenderRequest(call, decision, w.Redactor),
+		created:  time.Now(),
+		result:   make(chan types.ApprovalResult, 1),
+	}
+
+	w.mu.Lock()
+	w.nextID++
+	p.id = strconv.Itoa(w.nextID)
+	w.pending[p.id] = p
+	w.mu.Unlock()
+
+	defer func() {
+		w.mu.Lock()
+		delete(w.pending, p.id)
+		w.mu.Unlock()
+	}()
+
+	select {
+	case result := <-p.result:
+		return result, nil
+	case <-ctx.Done():
+		return types.ApprovalResult{}, ctx.Err()
+	}
+}
+
+// guard rejects requests without the session cookie, apart from the one that
+// spends the login token, and any request whose Host header does not name the
+// loopback listener, which blocks DNS rebinding.
+func (w *WebApprover) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Cache-Control", "no-store")
+		rw.Header().Set("X-Frame-Options", "DENY")
+		rw.Header().Set("X-Content-Type-Options", "nosniff")
+
+		if !w.allowedHost(r.Host) {
+			http.Error(rw, "forbidden host", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/" && r.URL.Query().Has("token") {
+			w.login(rw, r)
+			return
+		}
+		if !w.validSession(r) {
+			http.Error(rw, "not signed in: open the approval URL printed at startup", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// login spends the one-time token for a session cookie and redirects to the
+// page without the token in its address.
+func (w *WebApprover) login(rw http.ResponseWriter, r *http.Request) {
+	w.mu.Lock()
+	valid := w.token != "" && subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(w.token)) == 1
+	if valid {
+		session, err := randomHex()
+		if err != nil {
+			w.mu.Unlock()
+			http.Error(rw, "cannot start session", http.StatusInternalServerError)
+			return
+		}
+		w.token, w.session = "", session
+	}
+	session := w.session
+	w.mu.Unlock()
+
+	if !valid {
+		http.Error(rw, "invalid or already used token", http.StatusForbidden)
+		return
+	}
+	http.SetCookie(rw, &http.Cookie{
+		Name:     webSessionCookie,
+		Value:    session,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(rw, r, "/", http.StatusSeeOther)
+}
+
+func (w *WebApprover) validSession(r *http.Request) bool {
+	cookie, err := r.Cookie(webSessionCookie)
+	if err != nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.session != "" && subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(w.session)) == 1
+}
+
+// randomHex returns 128 random bits, hex encoded.
+func randomHex() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (w *WebApprover) allowedHost(host string) bool {
+	_, port, err := net.SplitHostPort(w.listener.Addr().String())
+	if err != nil {
+		return false
+	}
+	for _, name := range []string{"127.0.0.1", "localhost", "[::1]"} {
+		if host == name+":"+port {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *WebApprover) handlePage(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'")
+	_, _ = rw.Write(webPage)
+}
+
+func (w *WebApprover) handlePending(rw http.ResponseWriter, _ *http.Request) {
+	w.mu.Lock()
+	list := make([]PendingApproval, 0, len(w.pending))
+	for _, p := range w.pending {
+		list = append(list, PendingApproval{
+			ID:       p.id,
+			Tool:     p.call.Tool,
+			Action:   p.call.Action,
+			Rule:     p.decision.Rule,
+			Reason:   p.decision.Reason,
+			Preview:  p.preview,
+			Created:  p.created,
+			Approval: p.decision.Approval,
+			Approved: slices.Clone(p.approved),
+		})
+	}
+	w.mu.Unlock()
+
+	slices.SortFunc(list, func(a, b PendingApproval) int { return a.Created.Compare(b.Created) })
+	writeJSON(rw, http.StatusOK, list)
+}
+
+func (w *WebApprover) handleDecide(rw http.ResponseWriter, r *http.Request) {
+	var d WebDecision
+	if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, maxDecisionBytes)).Decode(&d); err != nil {
+		http.Error(rw, "invalid decision: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	d.Reviewer = strings.TrimSpace(d.Reviewer)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p, ok := w.pending[d.ID]
+	if !ok {
+		http.Error(rw, "no such pending approval", http.StatusNotFound)
+		return
+	}
+	if err := p.record(d); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(rw, http.StatusOK, map[string]any{"id": p.id, "approved": p.approved})
+}
+
+// record applies one reviewer's decision. Callers hold the approver's lock.
+func (p *webPending) record(d WebDecision) error {
+	settings := types.ApprovalSettings{}
+	if p.decision.Approval != nil {
+		settings = *p.decision.Approval
+	}
+	required := max(settings.RequiredApprovers, 1)
+
+	if p.decided {
+		return errors.New("already decided")
+	}
+	if !d.Approved {
+		p.finish(types.ApprovalResult{Approved: false, Approver: d.Reviewer, Comment: d.Comment})
+		return nil
+	}
+	if d.Reviewer == "" && required > 1 {
+		return errors.New("reviewer name is required")
+	}
+	if slices.ContainsFunc(p.approved, func(name string) bool { return strings.EqualFold(name, d.Reviewer) }) {
+		return errors.New("each approval must come from a distinct reviewer")
+	}
+	if settings.RequireJustification && strings.TrimSpace(d.Justification) == "" {
+		return errors.New("a justification is required")
+	}
+
+	p.approved = append(p.approved, d.Reviewer)
+	if len(p.approved) < required {
+		return nil
+	}
+	p.finish(types.ApprovalResult{
+		Approved:      true,
+		Approver:      strings.Join(p.approved, ", "),
+		Comment:       d.Comment,
+		Justification: strings.TrimSpace(d.Justification),
+	})
+	return nil
+}
+
+// finish delivers the result. Later decisions for the same call are rejected
+// until Approve removes it from the pending table.
+func (p *webPending) finish(result types.ApprovalResult) {
+	p.decided = true
+	p.result <- result
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(v)
+}
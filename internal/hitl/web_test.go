@@ -0,0 +1,249 @@
+package hitl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"bridgekeeper/internal/types"
+)
+
+// newTestWebApprover starts an approver and signs in with its one-time token.
+func newTestWebApprover(t *testing.T) *WebApprover {
+	t.Helper()
+	w, err := NewWebApprover("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("NewWebApprover() error = %v", err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	if resp := signIn(t, w.URL()); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("sign in: status = %d, want 303", resp.StatusCode)
+	}
+	return w
+}
+
+// signIn opens url without following the redirect.
+func signIn(t *testing.T, url string) *http.Response {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	return resp
+}
+
+// testSession returns the session cookie issued by newTestWebApprover.
+func (w *WebApprover) testSession() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.session
+}
+
+func (w *WebApprover) testRequest(t *testing.T, method, path, session string, body any) *http.Response {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, "http://"+w.listener.Addr().String()+path, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: webSessionCookie, Value: session})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+// waitPending polls the API until n approvals are pending.
+func (w *WebApprover) waitPending(t *testing.T, n int) []PendingApproval {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var list []PendingApproval
+		resp := w.testRequest(t, http.MethodGet, "/api/pending", w.testSession(), nil)
+		if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+			t.Fatal(err)
+		}
+		if len(list) == n {
+			return list
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d pending approvals", n)
+	return nil
+}
+
+func TestNewWebApprover_RequiresLoopback(t *testing.T) {
+	if _, err := NewWebApprover("0.0.0.0:0"); err == nil {
+		t.Fatal("expected non-loopback address to be rejected")
+	}
+}
+
+func TestWebApprover_Authentication(t *testing.T) {
+	w, err := NewWebApprover("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = w.Close() })
+	url := w.URL()
+	base := "http://" + w.listener.Addr().String()
+
+	if resp := w.testRequest(t, http.MethodGet, "/api/pending", "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("no session: status = %d, want 401", resp.StatusCode)
+	}
+	if resp := w.testRequest(t, http.MethodGet, "/", "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("page without session: status = %d, want 401", resp.StatusCode)
+	}
+	if resp := signIn(t, base+"/?token=wrong"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("wrong token: status = %d, want 403", resp.StatusCode)
+	}
+
+	resp := signIn(t, url)
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/" {
+		t.Fatalf("sign in: status = %d, location %q; want a redirect to /", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	var session *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == webSessionCookie {
+			session = c
+		}
+	}
+	if session == nil || !session.HttpOnly || session.SameSite != http.SameSiteStrictMode {
+		t.Fatalf("session cookie = %+v, want HttpOnly and SameSite=Strict", session)
+	}
+	if resp := signIn(t, url); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("reused token: status = %d, want 403", resp.StatusCode)
+	}
+	if w.URL() != "" {
+		t.Errorf("URL() = %q after sign in, want empty", w.URL())
+	}
+	if resp := w.testRequest(t, http.MethodGet, "/", session.Value, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("page: status = %d, want 200", resp.StatusCode)
+	}
+	if resp := w.testRequest(t, http.MethodGet, "/api/pending", "wrong", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong session: status = %d, want 401", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, base+"/api/pending", nil)
+	req.Host = "evil.example.com"
+	req.AddCookie(session)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("foreign host: status = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestWebApprover_ConcurrentDecisions(t *testing.T) {
+	w := newTestWebApprover(t)
+
+	type outcome struct {
+		result types.ApprovalResult
+		err    error
+	}
+	results := make(map[string]chan outcome)
+	for _, action := range []string{"install", "list"} {
+		ch := make(chan outcome, 1)
+		results[action] = ch
+		go func() {
+			result, err := w.Approve(context.Background(), types.ToolCall{Tool: "pkg", Action: action}, types.PolicyDecision{Decision: types.Ask, Rule: "pkg-ops"})
+			ch <- outcome{result, err}
+		}()
+	}
+
+	pending := w.waitPending(t, 2)
+	for _, p := range pending {
+		if !strings.Contains(p.Preview, "pkg/"+p.Action) {
+			t.Errorf("preview for %s missing tool: %q", p.ID, p.Preview)
+		}
+		resp := w.testRequest(t, http.MethodPost, "/api/decide", w.testSession(), WebDecision{
+			ID:       p.ID,
+			Approved: p.Action == "list",
+			Reviewer: "alice",
+			Comment:  "checked " + p.Action,
+		})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("decide %s: status = %d", p.ID, resp.StatusCode)
+		}
+	}
+
+	for action, want := range map[string]bool{"install": false, "list": true} {
+		got := <-results[action]
+		if got.err != nil || got.result.Approved != want || got.result.Comment != "checked "+action {
+			t.Errorf("%s: got %+v, %v; want approved=%v", action, got.result, got.err, want)
+		}
+	}
+
+	if resp := w.testRequest(t, http.MethodPost, "/api/decide", w.testSession(), WebDecision{ID: pending[0].ID, Approved: true}); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deciding a finished call: status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestWebApprover_RequiredApproversAndJustification(t *testing.T) {
+	w := newTestWebApprover(t)
+
+	done := make(chan types.ApprovalResult, 1)
+	go func() {
+		result, _ := w.Approve(context.Background(), types.ToolCall{Tool: "http", Action: "post"}, types.PolicyDecision{
+			Decision: types.Ask,
+			Approval: &types.ApprovalSettings{RequiredApprovers: 2, RequireJustification: true},
+		})
+		done <- result
+	}()
+	id := w.waitPending(t, 1)[0].ID
+
+	for _, tt := range []struct {
+		decision WebDecision
+		status   int
+	}{
+		{WebDecision{ID: id, Approved: true, Reviewer: "alice"}, http.StatusBadRequest},
+		{WebDecision{ID: id, Approved: true, Reviewer: "alice", Justification: "release"}, http.StatusOK},
+		{WebDecision{ID: id, Approved: true, Reviewer: "Alice", Justification: "release"}, http.StatusBadRequest},
+		{WebDecision{ID: id, Approved: true, Reviewer: "bob", Justification: "release"}, http.StatusOK},
+	} {
+		if resp := w.testRequest(t, http.MethodPost, "/api/decide", w.testSession(), tt.decision); resp.StatusCode != tt.status {
+			t.Fatalf("decide %+v: status = %d, want %d", tt.decision, resp.StatusCode, tt.status)
+		}
+	}
+
+	result := <-done
+	if !result.Approved || result.Approver != "alice, bob" || result.Justification != "release" {
+		t.Fatalf("result = %+v, want approval by alice and bob", result)
+	}
+}
+
+func TestWebApprover_ContextCancellation(t *testing.T) {
+	w := newTestWebApprover(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := w.Approve(ctx, types.ToolCall{Tool: "fs", Action: "write_file"}, types.PolicyDecision{Decision: types.Ask})
+		done <- err
+	}()
+	w.waitPending(t, 1)
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Approve() error = %v, want context.Canceled", err)
+	}
+	w.waitPending(t, 0)
+}
@@ -0,0 +1,237 @@
+package hitl
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bridgekeeper/internal/redact"
+	"bridgekeeper/internal/types"
+)
+
+const (
+	// SignatureHeader carries "sha256=<hex>" HMAC-SHA256 of timestamp + "." + body.
+	SignatureHeader = "X-Bridgekeeper-Signature"
+	// TimestampHeader carries the Unix time the message was signed.
+	TimestampHeader = "X-Bridgekeeper-Timestamp"
+
+	// maxSignatureSkew bounds how old a signed callback may be, so a captured
+	// callback cannot be replayed later.
+	maxSignatureSkew = 5 * time.Minute
+)
+
+// WebhookApprover posts each pending call to a webhook and waits for a signed
+// callback carrying the decision. Both directions are signed with a shared
+// secret; unsigned, late, or unknown callbacks are rejected, and any failure
+// to reach the webhook or hear back in time denies the call.
+type WebhookApprover struct {
+	WebhookURL  string        // where approval requests are posted
+	CallbackURL string        // externally reachable URL of Handler, sent with each request
+	Timeout     time.Duration // how long to wait for a callback; five minutes when zero
+	Client      *http.Client
+	Redactor    *redact.Redactor
+
+	secret []byte
+	server *http.Server
+
+	mu      sync.Mutex
+	pending map[string]*webhookPending
+}
+
+type webhookPending struct {
+	settings *types.ApprovalSettings
+	result   chan types.ApprovalResult
+}
+
+// NewWebhookApprover returns an approver that posts to webhookURL and accepts
+// callbacks signed with secret. Serve its Handler at callbackURL, or call
+// Listen to serve it from this process.
+func NewWebhookApprover(webhookURL, callbackURL string, secret []byte) (*WebhookApprover, error) {
+	if webhookURL == "" {
+		return nil, errors.New("webhook URL is required")
+	}
+	if len(secret) < 16 {
+		return nil, errors.New("webhook secret must be at least 16 bytes")
+	}
+	return &WebhookApprover{
+		WebhookURL:  webhookURL,
+		CallbackURL: callbackURL,
+		Client:      &http.Client{Timeout: 30 * time.Second},
+		secret:      secret,
+		pending:     make(map[string]*webhookPending),
+	}, nil
+}
+
+// Listen serves the callback handler at /callback on addr. When CallbackURL
+// is empty it is set from the listener's address. Call Close to stop.
+func (w *WebhookApprover) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if w.CallbackURL == "" {
+		w.CallbackURL = fmt.Sprintf("http://%s/callback", listener.Addr())
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/callback", w.Handler())
+	w.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() { _ = w.server.Serve(listener) }()
+	return nil
+}
+
+// Close stops the callback server started by Listen.
+func (w *WebhookApprover) Close() error {
+	if w.server == nil {
+		return nil
+	}
+	return w.server.Close()
+}
+
+// Approve posts the request and waits for its callback, ctx to end, or the
+// approver's timeout. Only a cancelled or expired ctx returns an error; every
+// other failure is a denial.
+func (w *WebhookApprover) Approve(ctx context.Context, call types.ToolCall, decision types.PolicyDecision) (types.ApprovalResult, error) {
+	deadline := headlessDeadline(ctx, w.Timeout)
+	req, err := newApprovalRequest(call, decision, w.Redactor, deadline)
+	if err != nil {
+		return denial("cannot create approval request: " + err.Error()), nil
+	}
+	req.Callback = w.CallbackURL
+
+	p := &webhookPending{settings: decision.Approval, result: make(chan types.ApprovalResult, 1)}
+	w.mu.Lock()
+	w.pending[req.ID] = p
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.pending, req.ID)
+		w.mu.Unlock()
+	}()
+
+	if err := w.post(ctx, req); err != nil {
+		if ctx.Err() != nil {
+			return types.ApprovalResult{}, ctx.Err()
+		}
+		return denial("webhook delivery failed: " + err.Error()), nil
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case result := <-p.result:
+		return result, nil
+	case <-ctx.Done():
+		return types.ApprovalResult{}, ctx.Err()
+	case <-timer.C:
+		return denial("no webhook callback before the approval deadline"), nil
+	}
+}
+
+func (w *WebhookApprover) post(ctx context.Context, req ApprovalRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	w.sign(httpReq.Header, body, time.Now())
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDecisionBytes))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Handler returns the HTTP handler that accepts signed decision callbacks.
+func (w *WebhookApprover) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxDecisionBytes))
+		if err != nil {
+			http.Error(rw, "cannot read body", http.StatusBadRequest)
+			return
+		}
+		if err := w.verify(r.Header, body, time.Now()); err != nil {
+			http.Error(rw, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		var resp ApprovalResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			http.Error(rw, "invalid decision: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Removing the entry makes each callback single-use.
+		w.mu.Lock()
+		p, ok := w.pending[resp.ID]
+		delete(w.pending, resp.ID)
+		w.mu.Unlock()
+		if !ok {
+			http.Error(rw, "no such pending approval", http.StatusNotFound)
+			return
+		}
+		p.result <- resp.result(p.settings)
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// Sign returns the signature header value for body signed at ts. It is
+// exported so webhook receivers written in Go can sign their callbacks.
+func Sign(secret, body []byte, ts time.Time) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%d.", ts.Unix())
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *WebhookApprover) sign(h http.Header, body []byte, ts time.Time) {
+	h.Set(TimestampHeader, strconv.FormatInt(ts.Unix(), 10))
+	h.Set(SignatureHeader, Sign(w.secret, body, ts))
+}
+
+func (w *WebhookApprover) verify(h http.Header, body []byte, now time.Time) error {
+	unix, err := strconv.ParseInt(h.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return errors.New("missing or invalid timestamp")
+	}
+	ts := time.Unix(unix, 0)
+	if now.Sub(ts).Abs() > maxSignatureSkew {
+		return errors.New("timestamp outside the allowed window")
+	}
+	got := h.Get(SignatureHeader)
+	want := Sign(w.secret, body, ts)
+	if !strings.HasPrefix(got, "sha256=") || !hmac.Equal([]byte(got), []byte(want)) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
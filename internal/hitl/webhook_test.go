@@ -0,0 +1,175 @@
+package hitl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"bridgekeeper/internal/redact"
+	"bridgekeeper/internal/types"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// newTestWebhook starts a callback server and a webhook receiver that hands
+// each verified request to respond.
+func newTestWebhook(t *testing.T, respond func(ApprovalRequest) (int, *ApprovalResponse)) *WebhookApprover {
+	t.Helper()
+	var approver *WebhookApprover
+	callback := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		approver.Handler().ServeHTTP(rw, r)
+	}))
+	t.Cleanup(callback.Close)
+
+	hook := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		if r.Header.Get(SignatureHeader) != Sign(testSecret, body, time.Unix(ts, 0)) {
+			t.Errorf("webhook request signature does not verify")
+		}
+		var req ApprovalRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		status, resp := respond(req)
+		rw.WriteHeader(status)
+		if resp != nil {
+			go postCallback(t, req.Callback, *resp, testSecret, time.Now())
+		}
+	}))
+	t.Cleanup(hook.Close)
+
+	approver, err := NewWebhookApprover(hook.URL, callback.URL, testSecret)
+	if err != nil {
+		t.Fatalf("NewWebhookApprover() error = %v", err)
+	}
+	approver.Timeout = 2 * time.Second
+	approver.Redactor = redact.New()
+	return approver
+}
+
+func postCallback(t *testing.T, url string, resp ApprovalResponse, secret []byte, ts time.Time) int {
+	body, _ := json.Marshal(resp)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		t.Error(err)
+		return 0
+	}
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(secret, body, ts))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err)
+		return 0
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestWebhookApprover_SignedCallback(t *testing.T) {
+	var seen ApprovalRequest
+	w := newTestWebhook(t, func(req ApprovalRequest) (int, *ApprovalResponse) {
+		seen = req
+		return http.StatusAccepted, &ApprovalResponse{ID: req.ID, Approved: true, Approver: "alice", Comment: "ok"}
+	})
+
+	call := types.ToolCall{Tool: "shell", Action: "exec", Args: map[string]any{"command": "echo api_key=sk-abcdefghijklmnopqrstuvwx"}}
+	got, err := w.Approve(context.Background(), call, types.PolicyDecision{Decision: types.Ask, Rule: "safe-shell"})
+	if err != nil {
+		t.Fatalf("Approve() error = %v", err)
+	}
+	if !got.Approved || got.Approver != "alice" || got.Comment != "ok" {
+		t.Errorf("Approve() = %+v, want approval by alice", got)
+	}
+	if seen.Rule != "safe-shell" || seen.Tool != "shell" {
+		t.Errorf("request = %+v, want shell call under safe-shell", seen)
+	}
+	if strings.Contains(seen.Preview, "sk-abc") {
+		t.Errorf("request preview leaks secret: %s", seen.Preview)
+	}
+}
+
+func TestWebhookApprover_FailsClosed(t *testing.T) {
+	call := types.ToolCall{Tool: "fs", Action: "read_file"}
+	decision := types.PolicyDecision{Decision: types.Ask}
+
+	t.Run("webhook error", func(t *testing.T) {
+		w := newTestWebhook(t, func(ApprovalRequest) (int, *ApprovalResponse) {
+			return http.StatusInternalServerError, nil
+		})
+		got, err := w.Approve(context.Background(), call, decision)
+		if err != nil || got.Approved {
+			t.Errorf("Approve() = %+v, %v; want denial", got, err)
+		}
+	})
+
+	t.Run("no callback", func(t *testing.T) {
+		w := newTestWebhook(t, func(ApprovalRequest) (int, *ApprovalResponse) {
+			return http.StatusAccepted, nil
+		})
+		w.Timeout = 50 * time.Millisecond
+		got, err := w.Approve(context.Background(), call, decision)
+		if err != nil || got.Approved {
+			t.Errorf("Approve() = %+v, %v; want denial", got, err)
+		}
+	})
+
+	t.Run("missing justification", func(t *testing.T) {
+		w := newTestWebhook(t, func(req ApprovalRequest) (int, *ApprovalResponse) {
+			return http.StatusAccepted, &ApprovalResponse{ID: req.ID, Approved: true}
+		})
+		d := types.PolicyDecision{Decision: types.Ask, Approval: &types.ApprovalSettings{RequireJustification: true}}
+		got, err := w.Approve(context.Background(), call, d)
+		if err != nil || got.Approved {
+			t.Errorf("Approve() = %+v, %v; want denial", got, err)
+		}
+	})
+}
+
+func TestWebhookApprover_RejectsBadCallbacks(t *testing.T) {
+	requests := make(chan ApprovalRequest, 1)
+	w := newTestWebhook(t, func(req ApprovalRequest) (int, *ApprovalResponse) {
+		requests <- req
+		return http.StatusAccepted, nil
+	})
+
+	done := make(chan types.ApprovalResult, 1)
+	go func() {
+		got, _ := w.Approve(context.Background(), types.ToolCall{Tool: "fs", Action: "read_file"}, types.PolicyDecision{Decision: types.Ask})
+		done <- got
+	}()
+	req := <-requests
+	approve := ApprovalResponse{ID: req.ID, Approved: true}
+
+	if status := postCallback(t, req.Callback, approve, []byte("wrong-secret-wrong-secret"), time.Now()); status != http.StatusUnauthorized {
+		t.Errorf("wrong secret: status = %d, want 401", status)
+	}
+	if status := postCallback(t, req.Callback, approve, testSecret, time.Now().Add(-time.Hour)); status != http.StatusUnauthorized {
+		t.Errorf("stale timestamp: status = %d, want 401", status)
+	}
+	if status := postCallback(t, req.Callback, ApprovalResponse{ID: "unknown", Approved: true}, testSecret, time.Now()); status != http.StatusNotFound {
+		t.Errorf("unknown id: status = %d, want 404", status)
+	}
+	if status := postCallback(t, req.Callback, approve, testSecret, time.Now()); status != http.StatusNoContent {
+		t.Errorf("valid callback: status = %d, want 204", status)
+	}
+	if status := postCallback(t, req.Callback, approve, testSecret, time.Now()); status != http.StatusNotFound {
+		t.Errorf("replayed callback: status = %d, want 404", status)
+	}
+	if got := <-done; !got.Approved {
+		t.Errorf("Approve() = %+v, want approval", got)
+	}
+}
+
+func TestNewWebhookApprover_RequiresSecret(t *testing.T) {
+	if _, err := NewWebhookApprover("http://127.0.0.1:1/hook", "", []byte("short")); err == nil {
+		t.Fatal("expected short secret to be rejected")
+	}
+}
@@ -0,0 +1,346 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"bridgekeeper/internal/glob"
+)
+
+// errCompoundCommand reports shell syntax that would run more than a single
+// program or alter its I/O, which argv rules cannot reason about.
+var errCompoundCommand = errors.New("compound command")
+
+// splitCommand parses a command line into argv using POSIX shell quoting rules.
+// It rejects anything beyond a single simple command: separators (; & && ||),
+// pipes, redirections, subshells, command substitution, and parameter
+// expansion all return errCompoundCommand.
+func splitCommand(cmd string) ([]string, error) {
+	var (
+		argv    []string
+		current strings.Builder
+		inWord  bool
+	)
+	flush := func() {
+		if inWord {
+			argv = append(argv, current.String())
+			current.Reset()
+			inWord = false
+		}
+	}
+
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i]
+		switch {
+		case c == ' ' || c == '\t':
+			flush()
+		case c == '\\':
+			if i+1 >= len(cmd) {
+				return nil, errors.New("trailing backslash")
+			}
+			if cmd[i+1] == '\n' {
+				return nil, fmt.Errorf("%w: line continuation", errCompoundCommand)
+			}
+			i++
+			current.WriteByte(cmd[i])
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(cmd[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			current.WriteString(cmd[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '"':
+			n, err := readDoubleQuoted(cmd[i+1:], &current)
+			if err != nil {
+				return nil, err
+			}
+			i += n
+			inWord = true
+		case strings.IndexByte(";&|<>()`\n\r", c) >= 0:
+			return nil, fmt.Errorf("%w: unquoted %q", errCompoundCommand, c)
+		case c == '$':
+			return nil, fmt.Errorf("%w: unquoted expansion", errCompoundCommand)
+		default:
+			current.WriteByte(c)
+			inWord = true
+		}
+	}
+	flush()
+
+	if len(argv) == 0 {
+		return nil, errors.New("empty command")
+	}
+	return argv, nil
+}
+
+// readDoubleQuoted consumes a double-quoted string body (after the opening
+// quote) into out and returns the bytes consumed including the closing quote.
+func readDoubleQuoted(s string, out *strings.Builder) (int, error) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return i + 1, nil
+		case '\\':
+			if i+1 < len(s) && strings.IndexByte("\"\\$`", s[i+1]) >= 0 {
+				i++
+				out.WriteByte(s[i])
+				continue
+			}
+			out.WriteByte(c)
+		case '$', '`':
+			return 0, fmt.Errorf("%w: expansion inside double quotes", errCompoundCommand)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return 0, errors.New("unterminated double quote")
+}
+
+// argPattern matches a single argv element.
+type argPattern struct {
+	raw string
+	re  *regexp.Regexp
+	g   *glob.Pattern
+}
+
+func compileArgPattern(raw string) (argPattern, error) {
+	if expr, ok := strings.CutPrefix(raw, "re:"); ok {
+		re, err := regexp.Compile(`^(?:` + expr + `)$`)
+		if err != nil {
+			return argPattern{}, fmt.Errorf("argument regex %q: %w", raw, err)
+		}
+		return argPattern{raw: raw, re: re}, nil
+	}
+	g, err := glob.CompileText(raw)
+	if err != nil {
+		return argPattern{}, err
+	}
+	return argPattern{raw: raw, g: g}, nil
+}
+
+func (p argPattern) match(value string) bool {
+	if p.re != nil {
+		return p.re.MatchString(value)
+	}
+	return p.g.Match(value)
+}
+
+// compiledArgv is the precompiled form of ArgvRules.
+type compiledArgv struct {
+	programs     []compiledProgramRule
+	denyPrograms []argPattern
+}
+
+type compiledProgramRule struct {
+	program     argPattern
+	argv        []argPattern
+	args        []argPattern
+	forbidFlags []string
+	// singleDashLong is set when forbidFlags has a multi-letter flag with a
+	// single dash, such as find's -exec: the program's single-dash
+	// arguments are then options, not bundles of letters.
+	singleDashLong bool
+}
+
+func compileArgv(rules *ArgvRules) (*compiledArgv, error) {
+	if rules == nil {
+		return nil, nil
+	}
+
+	out := &compiledArgv{}
+	for _, raw := range rules.DenyPrograms {
+		p, err := compileArgPattern(raw)
+		if err != nil {
+			return nil, err
+		}
+		out.denyPrograms = append(out.denyPrograms, p)
+	}
+
+	for _, rule := range rules.Programs {
+		if strings.TrimSpace(rule.Program) == "" {
+			return nil, errors.New("program rule without a program")
+		}
+		program, err := compileArgPattern(rule.Program)
+		if err != nil {
+			return nil, err
+		}
+		cr := compiledProgramRule{program: program, forbidFlags: rule.ForbidFlags}
+		for _, flag := range rule.ForbidFlags {
+			if len(flag) > 2 && flag[0] == '-' && flag[1] != '-' {
+				cr.singleDashLong = true
+			}
+		}
+		for _, raw := range rule.Argv {
+			p, err := compileArgPattern(raw)
+			if err != nil {
+				return nil, err
+			}
+			cr.argv = append(cr.argv, p)
+		}
+		for _, raw := range rule.Args {
+			p, err := compileArgPattern(raw)
+			if err != nil {
+				return nil, err
+			}
+			cr.args = append(cr.args, p)
+		}
+		out.programs = append(out.programs, cr)
+	}
+	return out, nil
+}
+
+// commandArgv extracts argv from a "command" arg. String commands are parsed
+// with shell quoting rules; array commands are taken as argv verbatim.
+func commandArgv(raw any) ([]string, error) {
+	switch v := raw.(type) {
+	case string:
+		return splitCommand(v)
+	case []string:
+		if len(v) == 0 {
+			return nil, errors.New("empty command")
+		}
+		return v, nil
+	case []any:
+		argv := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, errors.New("command array must contain only strings")
+			}
+			argv = append(argv, s)
+		}
+		if len(argv) == 0 {
+			return nil, errors.New("empty command")
+		}
+		return argv, nil
+	default:
+		return nil, errors.New("command must be a string or array of strings")
+	}
+}
+
+// checkArgv applies compiled argv rules to a "command" arg. Deny patterns
+// match the basename of argv[0], so "/bin/rm" is denied like "rm". Allow
+// rules match only a program named the way it will be found: a bare name
+// resolved through PATH, or the exact path PATH resolves that name to, so
+// "./cat" or a workspace script called cat cannot pass for cat. When several
+// rules name the same program, the call is allowed if any one of them
+// accepts it.
+func checkArgv(rules *compiledArgv, raw any) (string, bool) {
+	argv, err := commandArgv(raw)
+	if err != nil {
+		return fmt.Sprintf("command rejected: %v", err), false
+	}
+
+	program := filepath.Base(argv[0])
+	for _, p := range rules.denyPrograms {
+		if p.match(program) {
+			return fmt.Sprintf("program %q matches deny pattern %q", program, p.raw), false
+		}
+	}
+	if len(rules.programs) == 0 {
+		return "", true
+	}
+	if !onPath(argv[0]) {
+		return fmt.Sprintf("program %q must be named without a directory so that it resolves through PATH", argv[0]), false
+	}
+
+	firstFailure := ""
+	for _, rule := range rules.programs {
+		if !rule.program.match(program) {
+			continue
+		}
+		msg, ok := rule.check(program, argv[1:])
+		if ok {
+			return "", true
+		}
+		if firstFailure == "" {
+			firstFailure = msg
+		}
+	}
+	if firstFailure != "" {
+		return firstFailure, false
+	}
+	return fmt.Sprintf("program %q is not allowed", program), false
+}
+
+// onPath reports whether arg0 names a program as PATH would find it: a bare
+// name, or a path identical to what PATH resolves its basename to.
+func onPath(arg0 string) bool {
+	if !strings.Contains(arg0, "/") {
+		return arg0 != "" && arg0 != "." && arg0 != ".."
+	}
+	resolved, err := exec.LookPath(filepath.Base(arg0))
+	if err != nil {
+		return false
+	}
+	return filepath.Clean(arg0) == resolved
+}
+
+func (r compiledProgramRule) check(program string, args []string) (string, bool) {
+	for _, arg := range args {
+		for _, flag := range r.forbidFlags {
+			if flagMatches(flag, arg, r.singleDashLong) {
+				return fmt.Sprintf("%s argument %q uses forbidden flag %q", program, arg, flag), false
+			}
+		}
+	}
+
+	if r.argv != nil {
+		if len(args) != len(r.argv) {
+			return fmt.Sprintf("%s expects exactly %d arguments, got %d", program, len(r.argv), len(args)), false
+		}
+		for i, p := range r.argv {
+			if !p.match(args[i]) {
+				return fmt.Sprintf("%s argument %d %q does not match %q", program, i+1, args[i], p.raw), false
+			}
+		}
+	}
+
+	if len(r.args) > 0 {
+	next:
+		for _, arg := range args {
+			for _, p := range r.args {
+				if p.match(arg) {
+					continue next
+				}
+			}
+			return fmt.Sprintf("%s argument %q does not match any allowed argument pattern", program, arg), false
+		}
+	}
+	return "", true
+}
+
+// flagMatches reports whether arg uses flag. A multi-letter flag matches
+// with an "=value" suffix, in longer variants that begin with it (find's
+// -execdir for -exec), and for a "--" flag in abbreviations of at least two
+// letters that getopt would accept ("--to-com" for "--to-command").
+// Single-letter flags such as "-i" also match inside bundles like "-ni",
+// unless the rule's programs take single-dash long options, in which case
+// "-name" is an option of its own rather than a bundle holding "-n".
+func flagMatches(flag, arg string, singleDashLong bool) bool {
+	name, _, _ := strings.Cut(arg, "=")
+	if name == flag {
+		return true
+	}
+	if len(flag) > 2 {
+		if strings.HasPrefix(name, flag) {
+			return true
+		}
+		return strings.HasPrefix(flag, "--") && len(name) >= 4 && strings.HasPrefix(flag, name)
+	}
+	if singleDashLong {
+		return false
+	}
+	if len(flag) == 2 && flag[0] == '-' && flag[1] != '-' &&
+		len(arg) > 2 && arg[0] == '-' && arg[1] != '-' {
+		return strings.IndexByte(arg[1:], flag[1]) >= 0
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package policy
+
+import (
+	"container/list"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"bridgekeeper/internal/types"
+)
+
+// Option configures an Engine at construction time.
+type Option func(*Engine)
+
+// WithDecisionCache enables an LRU cache of up to size decisions keyed by the
+// normalized call and the evaluation context. Policies with time-window
+// conditions are never cached because the same call can change outcome as the
+// clock moves, nor are commands that name their program by path. A size of
+// zero or less leaves caching disabled.
+func WithDecisionCache(size int) Option {
+	return func(e *Engine) {
+		if size <= 0 || e.timeSensitive() {
+			return
+		}
+		e.cache = newDecisionCache(size, e.referencedEnv())
+	}
+}
+
+// timeSensitive reports whether any capability depends on the wall clock.
+func (e *Engine) timeSensitive() bool {
+	for _, cc := range e.capabilities {
+		if cc.When != nil && (cc.When.Hours != "" || len(cc.When.Days) > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// referencedEnv returns the sorted set of environment variable names that any
+// when block inspects. Only these contribute to cache keys so that unrelated
+// environment churn does not fragment the cache.
+func (e *Engine) referencedEnv() []string {
+	seen := map[string]bool{}
+	for _, cc := range e.capabilities {
+		if cc.When == nil {
+			continue
+		}
+		for _, want := range cc.When.Env {
+			key, _, _ := strings.Cut(want, "=")
+			seen[key] = true
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// cacheKey builds the cache key for call evaluated under ec. The second return
+// value is false when caching is disabled or the call cannot be keyed.
+func (e *Engine) cacheKey(call types.ToolCall, ec EvalContext) (string, bool) {
+	if e.cache == nil {
+		return "", false
+	}
+	// An argv[0] with a directory passes only while PATH resolves its name to
+	// that path, which changes with PATH and the filesystem, so the decision
+	// is never reused.
+	if raw, ok := call.Args["command"]; ok {
+		if argv, err := commandArgv(raw); err == nil && strings.Contains(argv[0], "/") {
+			return "", false
+		}
+	}
+
+	env := make(map[string]*string, len(e.cache.envKeys))
+	for _, key := range e.cache.envKeys {
+		if value, ok := ec.Env[key]; ok {
+			env[key] = &value
+		} else {
+			env[key] = nil
+		}
+	}
+
+	// encoding/json sorts map keys, so equal args always encode identically.
+	data, err := json.Marshal(struct {
+		Tool        string             `json:"t"`
+		Action      string             `json:"a"`
+		Args        map[string]any     `json:"g"`
+		Sensitivity types.Sensitivity  `json:"s"`
+		Mode        string             `json:"m"`
+		TTY         bool               `json:"y"`
+		Model       string             `json:"o"`
+		Labels      map[string]string  `json:"l"`
+		Env         map[string]*string `json:"e"`
+		Principal   types.Principal    `json:"p"`
+	}{call.Tool, call.Action, call.Args, call.Sensitivity, ec.Mode, ec.TTY, ec.Model, ec.Labels, env, ec.Principal})
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// decisionCache is a mutex-guarded LRU of policy decisions.
+type decisionCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+	envKeys []string
+}
+
+type cacheEntry struct {
+	key      string
+	decision types.PolicyDecision
+}
+
+func newDecisionCache(size int, envKeys []string) *decisionCache {
+	return &decisionCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+		envKeys: envKeys,
+	}
+}
+
+func (c *decisionCache) get(key string) (types.PolicyDecision, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return types.PolicyDecision{}, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).decision, true
+}
+
+func (c *decisionCache) put(key string, decision types.PolicyDecision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry).decision = decision
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, decision: decision})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *decisionCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
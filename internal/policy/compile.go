@@ -0,0 +1,143 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"bridgekeeper/internal/glob"
+	"bridgekeeper/internal/types"
+)
+
+// capabilityKey indexes capabilities by the tool and action they cover.
+type capabilityKey struct {
+	tool   string
+	action string
+}
+
+// compiledCapability pairs a capability with its precompiled matchers. err is
+// set when a pattern failed to compile; such capabilities fail closed.
+type compiledCapability struct {
+	Capability
+	paths     *compiledAllowDeny
+	commands  *compiledAllowDeny
+	argv      *compiledArgv
+	escalate  []compiledEscalation
+	approval  *types.ApprovalSettings
+	pii       types.PIIMode
+	injection *types.InjectionSettings
+	err       error
+}
+
+// compiledAllowDeny is the precompiled form of an AllowDeny rule.
+type compiledAllowDeny struct {
+	allow glob.List
+	deny  glob.List
+}
+
+// compile builds the tool/action index and precompiles every pattern so that
+// Evaluate never parses a pattern on the hot path. Problems found along the
+// way are recorded as warnings.
+func (e *Engine) compile() {
+	e.capabilities = make([]compiledCapability, len(e.policy.Capabilities))
+	e.index = make(map[capabilityKey][]int)
+
+	for i, cap := range e.policy.Capabilities {
+		cc := compiledCapability{Capability: cap}
+		if cap.Constraints != nil {
+			var pathErr, cmdErr, argvErr error
+			cc.paths, pathErr = compileAllowDeny(cap.Constraints.Paths, glob.CompilePathList)
+			cc.commands, cmdErr = compileAllowDeny(cap.Constraints.Commands, glob.CompileTextList)
+			cc.argv, argvErr = compileArgv(cap.Constraints.Argv)
+			switch {
+			case pathErr != nil:
+				cc.err = fmt.Errorf("path constraint: %w", pathErr)
+			case cmdErr != nil:
+				cc.err = fmt.Errorf("command constraint: %w", cmdErr)
+			case argvErr != nil:
+				cc.err = fmt.Errorf("argv constraint: %w", argvErr)
+			}
+			e.warnings = append(e.warnings, migrationWarnings(cap)...)
+		}
+		if cc.err == nil {
+			var escErr, approvalErr, piiErr, injectionErr error
+			whenErr := validateConditions(cap.When)
+			cc.escalate, escErr = compileEscalations(cap.Escalate)
+			cc.approval, approvalErr = compileApproval(cap.Approval)
+			cc.pii, piiErr = compilePII(cap.PII)
+			cc.injection, injectionErr = compileInjection(cap.Injection)
+			switch {
+			case whenErr != nil:
+				cc.err = fmt.Errorf("when: %w", whenErr)
+			case escErr != nil:
+				cc.err = fmt.Errorf("escalate: %w", escErr)
+			case approvalErr != nil:
+				cc.err = fmt.Errorf("approval: %w", approvalErr)
+			case piiErr != nil:
+				cc.err = fmt.Errorf("pii: %w", piiErr)
+			case injectionErr != nil:
+				cc.err = fmt.Errorf("injection: %w", injectionErr)
+			}
+		}
+		if cc.err != nil {
+			e.warnings = append(e.warnings, fmt.Sprintf("capability %q: %v; it will deny every call", cap.Name, cc.err))
+		}
+		e.capabilities[i] = cc
+
+		seen := make(map[string]bool, len(cap.Actions))
+		for _, action := range cap.Actions {
+			if seen[action] {
+				continue
+			}
+			seen[action] = true
+			key := capabilityKey{tool: cap.Tool, action: action}
+			e.index[key] = append(e.index[key], i)
+		}
+	}
+}
+
+// compilePII validates a capability's pii setting. Unset means mask.
+func compilePII(raw string) (types.PIIMode, error) {
+	switch mode := types.PIIMode(strings.ToLower(strings.TrimSpace(raw))); mode {
+	case "":
+		return types.PIIMask, nil
+	case types.PIIMask, types.PIIHash, types.PIIBlock, types.PIIAllow:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("must be mask, hash, block or allow, got %q", raw)
+	}
+}
+
+// compileInjection validates a capability's injection settings. Unset
+// settings leave the choice to the mediator.
+func compileInjection(in *Injection) (*types.InjectionSettings, error) {
+	if in == nil {
+		return nil, nil
+	}
+	if in.Threshold < 0 || in.Threshold > 1 {
+		return nil, fmt.Errorf("threshold must be between 0 and 1, got %v", in.Threshold)
+	}
+	out := &types.InjectionSettings{Action: types.InjectionAnnotate, Threshold: in.Threshold}
+	switch action := types.InjectionAction(strings.ToLower(strings.TrimSpace(in.Action))); action {
+	case "":
+	case types.InjectionAnnotate, types.InjectionQuarantine, types.InjectionStrip, types.InjectionDeny:
+		out.Action = action
+	default:
+		return nil, fmt.Errorf("action must be annotate, quarantine, strip or deny, got %q", in.Action)
+	}
+	return out, nil
+}
+
+func compileAllowDeny(ad *AllowDeny, compile func([]string) (glob.List, error)) (*compiledAllowDeny, error) {
+	if ad == nil {
+		return nil, nil
+	}
+	allow, err := compile(ad.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := compile(ad.Deny)
+	if err != nil {
+		return nil, err
+	}
+	return &compiledAllowDeny{allow: allow, deny: deny}, nil
+}
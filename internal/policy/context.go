@@ -0,0 +1,284 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"bridgekeeper/internal/glob"
+	"bridgekeeper/internal/types"
+)
+
+// EvalContext describes the environment a tool call is evaluated in. It is
+// carried on the context.Context passed to Engine.Evaluate so callers that do
+// not care about conditional capabilities can keep passing context.Background.
+type EvalContext struct {
+	Now    time.Time         `json:"now,omitempty"`
+	Mode   string            `json:"mode,omitempty"`   // e.g. "gemini", "ollama", "policycheck"
+	TTY    bool              `json:"tty"`              // true when a human is attached to a terminal
+	Model  string            `json:"model,omitempty"`  // model name driving the session
+	Env    map[string]string `json:"env,omitempty"`    // environment visible to env conditions
+	Labels map[string]string `json:"labels,omitempty"` // free-form deployment labels
+
+	Principal types.Principal `json:"principal,omitempty"` // who is acting
+}
+
+type evalContextKey struct{}
+
+// WithEvalContext returns a copy of ctx carrying ec.
+func WithEvalContext(ctx context.Context, ec EvalContext) context.Context {
+	return context.WithValue(ctx, evalContextKey{}, ec)
+}
+
+// EvalContextFrom returns the EvalContext stored on ctx, or the zero value when
+// none was attached.
+func EvalContextFrom(ctx context.Context) EvalContext {
+	if ctx == nil {
+		return EvalContext{}
+	}
+	ec, _ := ctx.Value(evalContextKey{}).(EvalContext)
+	return ec
+}
+
+// WithModel returns a copy of ctx whose EvalContext reports model as the active
+// model name. Other fields are preserved.
+func WithModel(ctx context.Context, model string) context.Context {
+	ec := EvalContextFrom(ctx)
+	ec.Model = model
+	return WithEvalContext(ctx, ec)
+}
+
+// WithPrincipal returns a copy of ctx whose EvalContext carries principal.
+// Other fields are preserved.
+func WithPrincipal(ctx context.Context, principal types.Principal) context.Context {
+	ec := EvalContextFrom(ctx)
+	ec.Principal = principal
+	return WithEvalContext(ctx, ec)
+}
+
+// EnvFromOS snapshots the process environment into a map suitable for
+// EvalContext.Env.
+func EnvFromOS() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if key != "" {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// conditionsMatch reports whether every populated field of c holds for ec.
+// An empty Conditions block always matches. Fields are ANDed together; list
+// fields match when any entry matches.
+func conditionsMatch(c *Conditions, ec EvalContext) (bool, error) {
+	if c == nil {
+		return true, nil
+	}
+
+	if c.Mode != "" && !strings.EqualFold(c.Mode, ec.Mode) {
+		return false, nil
+	}
+	if c.TTY != nil && *c.TTY != ec.TTY {
+		return false, nil
+	}
+	if len(c.Models) > 0 && !anyModelMatches(c.Models, ec.Model) {
+		return false, nil
+	}
+	for _, want := range c.Env {
+		key, value, hasValue := strings.Cut(want, "=")
+		got, ok := ec.Env[key]
+		if !ok || (hasValue && got != value) {
+			return false, nil
+		}
+	}
+	for key, value := range c.Labels {
+		if got, ok := ec.Labels[key]; !ok || got != value {
+			return false, nil
+		}
+	}
+
+	if c.Hours == "" && len(c.Days) == 0 {
+		return true, nil
+	}
+
+	now := ec.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if c.Timezone != "" {
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return false, fmt.Errorf("invalid timezone %q: %w", c.Timezone, err)
+		}
+		now = now.In(loc)
+	}
+
+	if len(c.Days) > 0 {
+		in, err := dayMatches(c.Days, now.Weekday())
+		if err != nil {
+			return false, err
+		}
+		if !in {
+			return false, nil
+		}
+	}
+	if c.Hours != "" {
+		in, err := withinHours(c.Hours, now)
+		if err != nil {
+			return false, err
+		}
+		if !in {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// anyModelMatches compares model against glob patterns so policies can
+// say "gemini-2.5-*" without enumerating every release.
+func anyModelMatches(patterns []string, model string) bool {
+	for _, pattern := range patterns {
+		if glob.MatchText(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateConditions checks the parts of c that are otherwise parsed only
+// during evaluation, so that a typo is reported when the policy loads rather
+// than silently denying at runtime.
+func validateConditions(c *Conditions) error {
+	if c == nil {
+		return nil
+	}
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q: %w", c.Timezone, err)
+		}
+	}
+	if _, err := dayMatches(c.Days, time.Sunday); err != nil {
+		return err
+	}
+	if c.Hours != "" {
+		if _, err := withinHours(c.Hours, time.Time{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dayMatches(days []string, weekday time.Weekday) (bool, error) {
+	matched := false
+	for _, raw := range days {
+		day, err := parseWeekday(raw)
+		if err != nil {
+			return false, err
+		}
+		matched = matched || day == weekday
+	}
+	return matched, nil
+}
+
+// parseWeekday accepts a day's English name or any prefix of it at least
+// three letters long, in any case: "mon", "Tues", "thursday".
+func parseWeekday(raw string) (time.Weekday, error) {
+	day := strings.ToLower(strings.TrimSpace(raw))
+	if len(day) >= 3 {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.HasPrefix(strings.ToLower(d.String()), day) {
+				return d, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid day %q: want a weekday name such as mon", raw)
+}
+
+// withinHours reports whether now falls inside a "HH:MM-HH:MM" window. Windows
+// whose end is before their start wrap past midnight, so "22:00-06:00" covers
+// the night shift.
+func withinHours(window string, now time.Time) (bool, error) {
+	startRaw, endRaw, ok := strings.Cut(window, "-")
+	if !ok {
+		return false, fmt.Errorf("invalid hours %q: want HH:MM-HH:MM", window)
+	}
+	start, err := parseClock(startRaw)
+	if err != nil {
+		return false, fmt.Errorf("invalid hours %q: %w", window, err)
+	}
+	end, err := parseClock(endRaw)
+	if err != nil {
+		return false, fmt.Errorf("invalid hours %q: %w", window, err)
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return minute >= start && minute < end, nil
+	}
+	return minute >= start || minute < end, nil
+}
+
+func parseClock(raw string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(raw))
+	if err != nil {
+		return 0, fmt.Errorf("bad clock value %q", raw)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// principalMatches reports whether p satisfies the capability's principal and
+// role selectors. Capabilities without selectors apply to everyone. When both
+// lists are set the principal must satisfy both.
+//
+// Principal selectors take the form "kind:pattern" where kind is user, agent,
+// or session; a bare pattern is shorthand for "user:pattern". Patterns use
+// text-mode globbing, so "agent:gemini*" covers every Gemini session.
+func principalMatches(cap Capability, p types.Principal) bool {
+	if len(cap.Principals) > 0 && !anyPrincipalSelectorMatches(cap.Principals, p) {
+		return false
+	}
+	if len(cap.Roles) > 0 && !anyRoleMatches(cap.Roles, p.Roles) {
+		return false
+	}
+	return true
+}
+
+func anyPrincipalSelectorMatches(selectors []string, p types.Principal) bool {
+	for _, selector := range selectors {
+		kind, pattern, ok := strings.Cut(selector, ":")
+		if !ok {
+			kind, pattern = "user", selector
+		}
+
+		var value string
+		switch strings.ToLower(strings.TrimSpace(kind)) {
+		case "user":
+			value = p.User
+		case "agent":
+			value = p.Agent
+		case "session":
+			value = p.Session
+		default:
+			continue
+		}
+		if value != "" && glob.MatchText(strings.TrimSpace(pattern), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyRoleMatches(want []string, have []string) bool {
+	for _, role := range want {
+		for _, held := range have {
+			if strings.EqualFold(role, held) {
+				return true
+			}
+		}
+	}
+	return false
+}
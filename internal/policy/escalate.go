@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"bridgekeeper/internal/glob"
+	"bridgekeeper/internal/types"
+)
+
+// compiledEscalation is the precompiled form of an Escalation.
+type compiledEscalation struct {
+	paths       glob.List
+	commands    glob.List
+	domains     []string
+	sensitivity types.Sensitivity
+	decision    types.Decision
+	reason      string
+}
+
+func compileEscalations(escalations []Escalation) ([]compiledEscalation, error) {
+	out := make([]compiledEscalation, 0, len(escalations))
+	for i, esc := range escalations {
+		if len(esc.Paths) == 0 && len(esc.Commands) == 0 && len(esc.Domains) == 0 && esc.Sensitivity == "" {
+			return nil, fmt.Errorf("escalation %d has no paths, commands, domains, or sensitivity", i+1)
+		}
+		sensitivity := types.Sensitivity(strings.ToLower(strings.TrimSpace(esc.Sensitivity)))
+		switch sensitivity {
+		case "", types.SensitivitySecret, types.SensitivityInternal:
+		default:
+			return nil, fmt.Errorf("escalation %d: unknown sensitivity %q", i+1, esc.Sensitivity)
+		}
+
+		decision := types.Ask
+		if strings.TrimSpace(esc.Decision) != "" {
+			var ok bool
+			decision, ok = normalizeDecision(esc.Decision)
+			if !ok || decision == types.Allow {
+				return nil, fmt.Errorf("escalation %d: decision must be ask or deny, got %q", i+1, esc.Decision)
+			}
+		}
+
+		paths, err := glob.CompilePathList(esc.Paths)
+		if err != nil {
+			return nil, fmt.Errorf("escalation %d: %w", i+1, err)
+		}
+		commands, err := glob.CompileTextList(esc.Commands)
+		if err != nil {
+			return nil, fmt.Errorf("escalation %d: %w", i+1, err)
+		}
+		out = append(out, compiledEscalation{
+			paths:       paths,
+			commands:    commands,
+			domains:     esc.Domains,
+			sensitivity: sensitivity,
+			decision:    decision,
+			reason:      esc.Reason,
+		})
+	}
+	return out, nil
+}
+
+// match reports whether call triggers the escalation and, if so, describes
+// which argument matched.
+func (esc compiledEscalation) match(call types.ToolCall) (string, bool) {
+	if esc.sensitivity != "" && call.Sensitivity == esc.sensitivity {
+		path, _ := call.Args["path"].(string)
+		return fmt.Sprintf("path %q is labelled %s", normalizePath(path), esc.sensitivity), true
+	}
+	if len(esc.paths) > 0 {
+		if path, _ := call.Args["path"].(string); path != "" {
+			path = normalizePath(path)
+			if matched, pattern := esc.paths.Match(path); matched {
+				return fmt.Sprintf("path %q matches escalation pattern %q", path, pattern), true
+			}
+		}
+	}
+	if len(esc.commands) > 0 {
+		if cmd, _ := call.Args["command"].(string); cmd != "" {
+			if matched, pattern := esc.commands.Match(cmd); matched {
+				return fmt.Sprintf("command %q matches escalation pattern %q", cmd, pattern), true
+			}
+		}
+	}
+	if len(esc.domains) > 0 {
+		if domain := extractDomain(call.Args); domain != "" {
+			for _, pattern := range esc.domains {
+				if matchDomain(pattern, domain) {
+					return fmt.Sprintf("domain %q matches escalation pattern %q", domain, pattern), true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// escalate applies the first matching escalation to decision. Escalations only
+// tighten: an allow may become ask or deny, an ask may become deny.
+func escalate(escalations []compiledEscalation, decision types.Decision, call types.ToolCall) (types.Decision, string) {
+	for _, esc := range escalations {
+		detail, ok := esc.match(call)
+		if !ok {
+			continue
+		}
+		if strictness(esc.decision) <= strictness(decision) {
+			return decision, ""
+		}
+		if esc.reason != "" {
+			detail = esc.reason + " (" + detail + ")"
+		}
+		return esc.decision, detail
+	}
+	return decision, ""
+}
+
+func strictness(d types.Decision) int {
+	switch d {
+	case types.Allow:
+		return 0
+	case types.Ask:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// compileApproval validates approval settings and converts them to the form
+// carried on PolicyDecision.
+func compileApproval(a *Approval) (*types.ApprovalSettings, error) {
+	if a == nil {
+		return nil, nil
+	}
+	if a.TimeoutSeconds < 0 {
+		return nil, errors.New("timeout_seconds must not be negative")
+	}
+	if a.RequiredApprovers < 0 {
+		return nil, errors.New("required_approvers must not be negative")
+	}
+
+	// An unanswered ask is always denied; approving on timeout would let a
+	// call through that nobody looked at.
+	onTimeout := types.Deny
+	if strings.TrimSpace(a.OnTimeout) != "" {
+		if decision, ok := normalizeDecision(a.OnTimeout); !ok || decision != types.Deny {
+			return nil, fmt.Errorf("on_timeout must be deny, got %q", a.OnTimeout)
+		}
+	}
+
+	return &types.ApprovalSettings{
+		TimeoutSeconds:       a.TimeoutSeconds,
+		OnTimeout:            onTimeout,
+		RequiredApprovers:    a.RequiredApprovers,
+		RequireJustification: a.RequireJustification,
+	}, nil
+}
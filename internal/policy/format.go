@@ -2,6 +2,7 @@ package policy
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -22,6 +23,15 @@ func FormatPolicy(pf *PolicyFile) string {
 		fmt.Fprintf(&b, "  Tool: %s\n", valueOrFallback(cap.Tool, "(unset)"))
 		fmt.Fprintf(&b, "  Actions: %s\n", joinOrFallback(cap.Actions, "(none)"))
 		fmt.Fprintf(&b, "  Decision: %s\n", valueOrFallback(cap.Decision, "(unset)"))
+		if len(cap.Principals) > 0 {
+			fmt.Fprintf(&b, "  Principals: %s\n", strings.Join(cap.Principals, ", "))
+		}
+		if len(cap.Roles) > 0 {
+			fmt.Fprintf(&b, "  Roles: %s\n", strings.Join(cap.Roles, ", "))
+		}
+		writeConditions(&b, cap.When)
+		writeEscalations(&b, cap.Escalate)
+		writeApproval(&b, cap.Approval)
 
 		if cap.Constraints == nil {
 			fmt.Fprintf(&b, "  Constraints: none\n")
@@ -31,6 +41,7 @@ func FormatPolicy(pf *PolicyFile) string {
 		fmt.Fprintf(&b, "  Constraints:\n")
 		writeAllowDeny(&b, "paths", cap.Constraints.Paths)
 		writeAllowDeny(&b, "commands", cap.Constraints.Commands)
+		writeArgv(&b, cap.Constraints.Argv)
 		writeAllowDeny(&b, "domains", cap.Constraints.Domains)
 		if cap.Constraints.MaxSizeBytes > 0 {
 			fmt.Fprintf(&b, "    max_size_bytes: %d\n", cap.Constraints.MaxSizeBytes)
@@ -43,6 +54,87 @@ func FormatPolicy(pf *PolicyFile) string {
 	return strings.TrimRight(b.String(), "\n")
 }
 
+func writeConditions(b *strings.Builder, c *Conditions) {
+	if c == nil {
+		return
+	}
+	var parts []string
+	if c.Mode != "" {
+		parts = append(parts, "mode="+c.Mode)
+	}
+	if c.TTY != nil {
+		parts = append(parts, fmt.Sprintf("tty=%t", *c.TTY))
+	}
+	if len(c.Models) > 0 {
+		parts = append(parts, "models="+strings.Join(c.Models, "|"))
+	}
+	if len(c.Env) > 0 {
+		parts = append(parts, "env="+strings.Join(c.Env, "|"))
+	}
+	for _, key := range sortedKeys(c.Labels) {
+		parts = append(parts, fmt.Sprintf("label:%s=%s", key, c.Labels[key]))
+	}
+	if c.Hours != "" {
+		parts = append(parts, "hours="+c.Hours)
+	}
+	if len(c.Days) > 0 {
+		parts = append(parts, "days="+strings.Join(c.Days, "|"))
+	}
+	if c.Timezone != "" {
+		parts = append(parts, "timezone="+c.Timezone)
+	}
+	fmt.Fprintf(b, "  When: %s\n", joinOrFallback(parts, "always"))
+}
+
+func writeEscalations(b *strings.Builder, escalations []Escalation) {
+	for _, esc := range escalations {
+		var parts []string
+		if len(esc.Paths) > 0 {
+			parts = append(parts, "paths="+strings.Join(esc.Paths, "|"))
+		}
+		if len(esc.Commands) > 0 {
+			parts = append(parts, "commands="+strings.Join(esc.Commands, "|"))
+		}
+		if len(esc.Domains) > 0 {
+			parts = append(parts, "domains="+strings.Join(esc.Domains, "|"))
+		}
+		if esc.Sensitivity != "" {
+			parts = append(parts, "sensitivity="+esc.Sensitivity)
+		}
+		if esc.Reason != "" {
+			parts = append(parts, fmt.Sprintf("reason=%q", esc.Reason))
+		}
+		fmt.Fprintf(b, "  Escalate to %s: %s\n", valueOrFallback(esc.Decision, "ask"), strings.Join(parts, ", "))
+	}
+}
+
+func writeApproval(b *strings.Builder, a *Approval) {
+	if a == nil {
+		return
+	}
+	var parts []string
+	if a.TimeoutSeconds > 0 {
+		parts = append(parts, fmt.Sprintf("timeout=%ds", a.TimeoutSeconds))
+		parts = append(parts, "on_timeout="+valueOrFallback(a.OnTimeout, "deny"))
+	}
+	if a.RequiredApprovers > 1 {
+		parts = append(parts, fmt.Sprintf("required_approvers=%d", a.RequiredApprovers))
+	}
+	if a.RequireJustification {
+		parts = append(parts, "justification required")
+	}
+	fmt.Fprintf(b, "  Approval: %s\n", joinOrFallback(parts, "default"))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func writeAllowDeny(b *strings.Builder, name string, rule *AllowDeny) {
 	if rule == nil {
 		return
@@ -52,6 +144,29 @@ func writeAllowDeny(b *strings.Builder, name string, rule *AllowDeny) {
 	fmt.Fprintf(b, "      deny: %s\n", joinOrFallback(rule.Deny, "(none)"))
 }
 
+func writeArgv(b *strings.Builder, rules *ArgvRules) {
+	if rules == nil {
+		return
+	}
+	fmt.Fprintf(b, "    argv:\n")
+	if len(rules.DenyPrograms) > 0 {
+		fmt.Fprintf(b, "      deny_programs: %s\n", strings.Join(rules.DenyPrograms, ", "))
+	}
+	for _, rule := range rules.Programs {
+		var parts []string
+		if rule.Argv != nil {
+			parts = append(parts, "argv=["+strings.Join(rule.Argv, " ")+"]")
+		}
+		if len(rule.Args) > 0 {
+			parts = append(parts, "args="+strings.Join(rule.Args, "|"))
+		}
+		if len(rule.ForbidFlags) > 0 {
+			parts = append(parts, "forbid="+strings.Join(rule.ForbidFlags, "|"))
+		}
+		fmt.Fprintf(b, "      program %s: %s\n", rule.Program, joinOrFallback(parts, "any arguments"))
+	}
+}
+
 func joinOrFallback(items []string, fallback string) string {
 	if len(items) == 0 {
 		return fallback
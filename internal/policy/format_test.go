@@ -15,6 +15,9 @@ func TestFormatPolicy(t *testing.T) {
 				Tool:     "fs",
 				Actions:  []string{"read_file", "list_dir"},
 				Decision: "allow",
+				When:     &Conditions{Mode: "gemini", Hours: "09:00-17:00"},
+				Escalate: []Escalation{{Paths: []string{"*.pem"}, Reason: "key material"}},
+				Approval: &Approval{TimeoutSeconds: 30, RequireJustification: true},
 				Constraints: &Constraints{
 					Paths: &AllowDeny{
 						Allow: []string{"./**"},
@@ -36,6 +39,9 @@ func TestFormatPolicy(t *testing.T) {
 		"Tool: fs",
 		"Actions: read_file, list_dir",
 		"Decision: allow",
+		"When: mode=gemini, hours=09:00-17:00",
+		`Escalate to ask: paths=*.pem, reason="key material"`,
+		"Approval: timeout=30s, on_timeout=deny, justification required",
 		"paths:",
 		"allow: ./**",
 		"deny: /etc/**",
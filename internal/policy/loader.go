@@ -1,9 +1,12 @@
 package policy
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,6 +16,10 @@ type PolicyFile struct {
 	Version      string       `yaml:"version"`
 	Default      string       `yaml:"default"`
 	Capabilities []Capability `yaml:"capabilities"`
+	// Roles grants roles to OS users by login name. It is the only source
+	// of the runtime principal's roles; asking for a role on the command
+	// line can narrow the grant but never extend it.
+	Roles map[string][]string `yaml:"roles,omitempty"`
 }
 
 // Capability defines a specific access rule for a tool.
@@ -21,18 +28,87 @@ type Capability struct {
 	Tool        string       `yaml:"tool"`
 	Actions     []string     `yaml:"actions"`
 	Decision    string       `yaml:"decision"`
+	Principals  []string     `yaml:"principals,omitempty"`
+	Roles       []string     `yaml:"roles,omitempty"`
+	When        *Conditions  `yaml:"when,omitempty"`
 	Constraints *Constraints `yaml:"constraints,omitempty"`
+	Escalate    []Escalation `yaml:"escalate,omitempty"`
+	Approval    *Approval    `yaml:"approval,omitempty"`
+	PII         string       `yaml:"pii,omitempty"` // mask (default), hash, block or allow
+	Injection   *Injection   `yaml:"injection,omitempty"`
+}
+
+// Injection configures how suspected prompt injection in the tool's output
+// is handled.
+type Injection struct {
+	Action    string  `yaml:"action,omitempty"`    // annotate (default), quarantine, strip or deny
+	Threshold float64 `yaml:"threshold,omitempty"` // score from 0 to 1; 0.5 when unset
+}
+
+// Escalation tightens a capability's decision for calls whose arguments match
+// one of its patterns, e.g. "allow, but ask for anything under ~/.ssh". The
+// first matching escalation wins. Escalations can only make a decision
+// stricter, so an escalation never turns deny into ask or ask into allow.
+type Escalation struct {
+	Paths    []string `yaml:"paths,omitempty"`    // path globs matched against the "path" arg
+	Commands []string `yaml:"commands,omitempty"` // text globs matched against the "command" arg
+	Domains  []string `yaml:"domains,omitempty"`  // domain patterns matched like domain constraints
+	// Sensitivity matches calls the sandbox labelled with this sensitivity,
+	// e.g. "secret", whatever the path is called.
+	Sensitivity string `yaml:"sensitivity,omitempty"`
+	Decision    string `yaml:"decision,omitempty"` // ask (default) or deny
+	Reason      string `yaml:"reason,omitempty"`
+}
+
+// Approval configures how an ask decision from this capability is resolved.
+type Approval struct {
+	TimeoutSeconds       int    `yaml:"timeout_seconds,omitempty"`       // how long to wait for a human
+	OnTimeout            string `yaml:"on_timeout,omitempty"`            // deny, the only choice; an unanswered ask never runs
+	RequiredApprovers    int    `yaml:"required_approvers,omitempty"`    // distinct approvals needed
+	RequireJustification bool   `yaml:"require_justification,omitempty"` // approver must explain why
+}
+
+// Conditions restricts when a capability applies. A capability whose
+// conditions do not hold is skipped as if its tool and action did not match,
+// so evaluation falls through to later capabilities or the default.
+type Conditions struct {
+	Mode     string            `yaml:"mode,omitempty"`     // runtime mode, e.g. "gemini"
+	TTY      *bool             `yaml:"tty,omitempty"`      // require (or forbid) an interactive terminal
+	Models   []string          `yaml:"models,omitempty"`   // model name patterns
+	Env      []string          `yaml:"env,omitempty"`      // "NAME" (present) or "NAME=value"
+	Labels   map[string]string `yaml:"labels,omitempty"`   // exact label matches
+	Hours    string            `yaml:"hours,omitempty"`    // "HH:MM-HH:MM", may wrap midnight
+	Days     []string          `yaml:"days,omitempty"`     // e.g. [mon, tue, wed]
+	Timezone string            `yaml:"timezone,omitempty"` // IANA zone for hours/days, default local
 }
 
 // Constraints defines limits on how a capability can be used.
 type Constraints struct {
 	Paths          *AllowDeny `yaml:"paths,omitempty"`
 	Commands       *AllowDeny `yaml:"commands,omitempty"`
+	Argv           *ArgvRules `yaml:"argv,omitempty"`
 	Domains        *AllowDeny `yaml:"domains,omitempty"`
 	MaxSizeBytes   int64      `yaml:"max_size_bytes,omitempty"`
 	TimeoutSeconds int        `yaml:"timeout_seconds,omitempty"`
 }
 
+// ArgvRules constrains a "command" arg by its parsed argv rather than its raw
+// text. Commands that chain, pipe, redirect, or substitute are always rejected
+// when argv rules are present.
+type ArgvRules struct {
+	Programs     []ProgramRule `yaml:"programs,omitempty"`      // allowed programs; empty allows any not denied
+	DenyPrograms []string      `yaml:"deny_programs,omitempty"` // basename patterns that are never allowed
+}
+
+// ProgramRule describes how one program may be invoked. Argument patterns are
+// globs, or anchored regular expressions when prefixed with "re:".
+type ProgramRule struct {
+	Program     string   `yaml:"program"`                // name pattern for argv[0], which must resolve through PATH
+	Argv        []string `yaml:"argv,omitempty"`         // exact positional patterns for argv[1:]
+	Args        []string `yaml:"args,omitempty"`         // every argument must match one of these
+	ForbidFlags []string `yaml:"forbid_flags,omitempty"` // e.g. -exec, --to-command
+}
+
 // AllowDeny defines explicit allow and deny lists for string matching.
 type AllowDeny struct {
 	Allow []string `yaml:"allow,omitempty"`
@@ -63,3 +139,35 @@ func LoadPath(path string) (*PolicyFile, error) {
 
 	return &pf, nil
 }
+
+// RolesFor returns the roles the policy grants user. When requested is not
+// empty only those roles are returned, and requesting a role the policy does
+// not grant user is an error.
+func (pf *PolicyFile) RolesFor(user string, requested []string) ([]string, error) {
+	granted := pf.Roles[user]
+	if len(requested) == 0 {
+		return slices.Clone(granted), nil
+	}
+	var roles []string
+	for _, role := range requested {
+		if !slices.Contains(granted, role) {
+			return nil, fmt.Errorf("policy does not grant role %q to user %q", role, user)
+		}
+		if !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil
+}
+
+// Hash returns a hex SHA-256 digest of the policy's content. Comments and
+// formatting in the source YAML do not affect it, so it changes only when
+// the rules do.
+func (pf *PolicyFile) Hash() string {
+	data, err := yaml.Marshal(pf)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// migrationWarnings flags path and command patterns whose meaning changed when
+// constraints moved from prefix-style "**" handling to gitignore-style globbing.
+// Previously any pattern containing "**" matched every value starting with the
+// text before the first "**", and path patterns without "**" were matched
+// against the whole path with filepath.Match.
+func migrationWarnings(cap Capability) []string {
+	if cap.Constraints == nil {
+		return nil
+	}
+
+	var out []string
+	warn := func(kind, pattern, why string) {
+		out = append(out, fmt.Sprintf("capability %q: %s pattern %q changed meaning: %s", cap.Name, kind, pattern, why))
+	}
+
+	for _, pattern := range allowDenyPatterns(cap.Constraints.Paths) {
+		if why := pathPatternChange(pattern); why != "" {
+			warn("path", pattern, why)
+		}
+	}
+	for _, pattern := range allowDenyPatterns(cap.Constraints.Commands) {
+		if why := commandPatternChange(pattern); why != "" {
+			warn("command", pattern, why)
+		}
+	}
+	return out
+}
+
+func allowDenyPatterns(ad *AllowDeny) []string {
+	if ad == nil {
+		return nil
+	}
+	return append(append([]string(nil), ad.Allow...), ad.Deny...)
+}
+
+func pathPatternChange(pattern string) string {
+	switch {
+	case strings.HasPrefix(pattern, "!"):
+		return "a leading '!' now negates the pattern instead of matching a literal '!'"
+	case len(pattern) > 1 && strings.HasSuffix(pattern, "/"):
+		return "a trailing '/' is now ignored; previously the pattern could never match a cleaned path"
+	}
+
+	idx := strings.Index(pattern, "**")
+	if idx < 0 {
+		if !strings.Contains(pattern, "/") {
+			return "patterns without '/' now match the file name at any depth instead of the whole path"
+		}
+		return ""
+	}
+
+	prefix, rest := pattern[:idx], pattern[idx+2:]
+	switch {
+	case rest != "":
+		return fmt.Sprintf("text after '**' is now matched; previously this meant any path starting with %q", prefix)
+	case prefix != "" && !strings.HasSuffix(prefix, "/"):
+		return fmt.Sprintf("'**' inside a segment now acts like '*' and stops at '/'; previously this meant any path starting with %q", prefix)
+	case strings.ContainsAny(prefix, `*?[\`):
+		return fmt.Sprintf("wildcards before '**' are now expanded; previously %q was matched literally", prefix)
+	}
+	return ""
+}
+
+func commandPatternChange(pattern string) string {
+	switch {
+	case strings.HasPrefix(pattern, "!"):
+		return "a leading '!' now negates the pattern instead of matching a literal '!'"
+	case strings.ContainsAny(pattern, `[\`):
+		return "'[' and '\\' now introduce character classes and escapes"
+	}
+
+	idx := strings.Index(pattern, "**")
+	if idx < 0 {
+		return ""
+	}
+	prefix, rest := pattern[:idx], pattern[idx+2:]
+	if rest != "" && rest != "*" {
+		return fmt.Sprintf("text after '**' is now matched; previously this meant any command starting with %q", prefix)
+	}
+	if strings.ContainsAny(prefix, "*?") {
+		return fmt.Sprintf("wildcards before '**' are now expanded; previously %q was matched literally", prefix)
+	}
+	return ""
+}
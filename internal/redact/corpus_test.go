@@ -0,0 +1,63 @@
+package redact
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type corpusCase struct {
+	Description string `json:"description"`
+	Text        string `json:"text"`
+	Expect      struct {
+		Sensitive bool     `json:"sensitive"`
+		Reasons   []string `json:"reasons"`
+		Hidden    []string `json:"hidden"` // substrings that must not survive redaction
+	} `json:"expect"`
+}
+
+// TestCorpus runs the built-in detectors over known true and false positives.
+func TestCorpus(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "..", "testdata", "redaction", "corpus.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r := New()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var c corpusCase
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			t.Fatalf("line %d: %v", line, err)
+		}
+		t.Run(c.Description, func(t *testing.T) {
+			got := r.Detect(c.Text)
+			if got.Sensitive != c.Expect.Sensitive {
+				t.Errorf("Detect() = %+v, want sensitive=%v", got, c.Expect.Sensitive)
+			}
+			for _, reason := range c.Expect.Reasons {
+				if !slices.Contains(got.Reasons, reason) {
+					t.Errorf("Detect() reasons = %v, want %s", got.Reasons, reason)
+				}
+			}
+
+			redacted := r.RedactText(c.Text)
+			for _, secret := range c.Expect.Hidden {
+				if strings.Contains(redacted, secret) {
+					t.Errorf("RedactText() = %q still contains %q", redacted, secret)
+				}
+			}
+			if !c.Expect.Sensitive && redacted != c.Text {
+				t.Errorf("RedactText() = %q, want text unchanged", redacted)
+			}
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,230 @@
+package redact
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// EntropyConfig tunes the detector for random-looking strings that carry no
+// known prefix. Thresholds are Shannon entropy in bits per character; a
+// candidate is flagged when its entropy reaches the threshold for its
+// character set. Zero fields take the defaults.
+type EntropyConfig struct {
+	Enabled      *bool   `yaml:"enabled,omitempty"`    // true when unset
+	MinLength    int     `yaml:"min_length,omitempty"` // shortest candidate considered
+	Hex          float64 `yaml:"hex,omitempty"`
+	Base64       float64 `yaml:"base64,omitempty"`
+	Alphanumeric float64 `yaml:"alphanumeric,omitempty"`
+}
+
+const (
+	defaultEntropyMinLength = 20
+	defaultHexEntropy       = 3.0
+	defaultBase64Entropy    = 4.2
+	defaultAlnumEntropy     = 3.8
+)
+
+// entropyCandidate matches runs of characters secrets are usually drawn from.
+var entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/=_-]+`)
+
+// hashContext marks lines where long hex or base64 strings are digests rather
+// than secrets.
+var hashContext = regexp.MustCompile(`(?i)\b(sha1|sha224|sha256|sha384|sha512|md5|checksum|digest|integrity|hash|etag)\b`)
+
+// gitContext marks lines of git output that name objects by hash.
+var gitContext = regexp.MustCompile(`(?i)^\s*(commit|tree|parent|object|merge:)\b|^[0-9a-f]{7,64}\s`)
+
+// goSumLine matches go.sum and go.mod hash lines.
+var goSumLine = regexp.MustCompile(`^\S+\s+v\S+\s+h1:`)
+
+type entropyDetector struct {
+	minLength int
+	hex       float64
+	base64    float64
+	alnum     float64
+}
+
+type entropyFinding struct {
+	start, end int
+	reason     string
+	confidence float64
+}
+
+func newEntropyDetector(cfg *EntropyConfig) (*entropyDetector, error) {
+	d := &entropyDetector{
+		minLength: defaultEntropyMinLength,
+		hex:       defaultHexEntropy,
+		base64:    defaultBase64Entropy,
+		alnum:     defaultAlnumEntropy,
+	}
+	if cfg == nil {
+		return d, nil
+	}
+	if cfg.Enabled != nil && !*cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.MinLength < 0 || cfg.Hex < 0 || cfg.Base64 < 0 || cfg.Alphanumeric < 0 {
+		return nil, fmt.Errorf("entropy: thresholds and min_length must not be negative")
+	}
+	if cfg.MinLength > 0 {
+		d.minLength = cfg.MinLength
+	}
+	if cfg.Hex > 0 {
+		d.hex = cfg.Hex
+	}
+	if cfg.Base64 > 0 {
+		d.base64 = cfg.Base64
+	}
+	if cfg.Alphanumeric > 0 {
+		d.alnum = cfg.Alphanumeric
+	}
+	return d, nil
+}
+
+// findings returns the high-entropy strings in text that are not explained by
+// their context. Output of the git tool is assumed to be full of object
+// hashes, so hex strings are never flagged there.
+func (d *entropyDetector) findings(tool, text string) []entropyFinding {
+	if d == nil {
+		return nil
+	}
+	var out []entropyFinding
+	for _, loc := range entropyCandidate.FindAllStringIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		token := strings.TrimRight(text[start:end], "=")
+		if len(token) < d.minLength {
+			continue
+		}
+
+		charset, size, threshold := d.classify(token)
+		if charset == "" || looksLikePath(token) {
+			continue
+		}
+		line, before := lineAround(text, start, end)
+		if suppressed(tool, charset, line, before) || inPublicBlock(text[:start]) {
+			continue
+		}
+
+		h := shannonEntropy(token)
+		if h < threshold {
+			continue
+		}
+		// The most entropy a string can show is bounded by its length and its
+		// alphabet; scale confidence by how far between the threshold and
+		// that bound the candidate falls.
+		ceiling := math.Log2(math.Min(float64(len(token)), float64(size)))
+		confidence := 0.5
+		if ceiling > threshold {
+			confidence += 0.4 * math.Min(1, (h-threshold)/(ceiling-threshold))
+		}
+		out = append(out, entropyFinding{
+			start:      start,
+			end:        end,
+			reason:     "high_entropy_" + charset,
+			confidence: math.Round(confidence*100) / 100,
+		})
+	}
+	return out
+}
+
+// classify names token's character set and returns the alphabet size and
+// threshold for it, or "" when the token does not look like a secret at all:
+// secrets mix letters and digits, while words and identifiers rarely do.
+func (d *entropyDetector) classify(token string) (string, int, float64) {
+	var hexOnly, upper, lower, digit, symbol = true, false, false, false, false
+	for _, r := range token {
+		switch {
+		case r >= '0' && r <= '9':
+			digit = true
+		case r >= 'a' && r <= 'f', r >= 'A' && r <= 'F':
+			if r >= 'a' {
+				lower = true
+			} else {
+				upper = true
+			}
+		case r >= 'a' && r <= 'z':
+			lower, hexOnly = true, false
+		case r >= 'A' && r <= 'Z':
+			upper, hexOnly = true, false
+		case r == '+' || r == '/':
+			symbol, hexOnly = true, false
+		default:
+			hexOnly = false
+		}
+	}
+	switch {
+	case hexOnly && digit && (lower != upper):
+		return "hex", 16, d.hex
+	case symbol && upper && lower && digit:
+		return "base64", 64, d.base64
+	case !symbol && upper && lower && digit:
+		return "alphanumeric", 64, d.alnum
+	}
+	return "", 0, 0
+}
+
+// suppressed reports whether context explains a candidate as a hash: go.sum
+// lines, git object names, and lines that say they carry a digest.
+func suppressed(tool, charset, line, before string) bool {
+	if goSumLine.MatchString(line) || strings.HasSuffix(before, "h1:") {
+		return true
+	}
+	if hashContext.MatchString(line) {
+		return true
+	}
+	if charset == "hex" && (tool == "git" || gitContext.MatchString(line)) {
+		return true
+	}
+	return false
+}
+
+// looksLikePath reports whether a token is a file path or URL path rather
+// than base64, which starts with a slash or dot far less often.
+func looksLikePath(token string) bool {
+	return strings.HasPrefix(token, "/") || strings.HasPrefix(token, ".") || strings.HasPrefix(token, "~") || strings.Contains(token, "//")
+}
+
+// inPublicBlock reports whether text ends inside a PEM block that holds public
+// material, such as a public key or certificate. Private key blocks are caught
+// by their own rule.
+func inPublicBlock(before string) bool {
+	begin := strings.LastIndex(before, "-----BEGIN ")
+	if begin < 0 || strings.Contains(before[begin:], "-----END ") {
+		return false
+	}
+	header, _, _ := strings.Cut(before[begin:], "\n")
+	return !strings.Contains(header, "PRIVATE")
+}
+
+// lineAround returns the line containing text[start:end] and the part of that
+// line before start.
+func lineAround(text string, start, end int) (string, string) {
+	lineStart := strings.LastIndexByte(text[:start], '\n') + 1
+	lineEnd := strings.IndexByte(text[end:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(text)
+	} else {
+		lineEnd += end
+	}
+	return text[lineStart:lineEnd], text[lineStart:start]
+}
+
+// shannonEntropy returns the entropy of s in bits per byte.
+func shannonEntropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	var h float64
+	n := float64(len(s))
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,140 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"net/netip"
+	"strings"
+)
+
+// piiPrefix marks rules that find personal data rather than secrets. Such
+// rules follow the capability's pii mode instead of always being masked.
+const piiPrefix = "pii_"
+
+// isPII reports whether a rule or reason name denotes personal data.
+func isPII(name string) bool {
+	return strings.HasPrefix(name, piiPrefix)
+}
+
+// PII returns the reasons naming personal data, such as "pii_email".
+func (c Classification) PII() []string {
+	var out []string
+	for _, reason := range c.Reasons {
+		if isPII(reason) {
+			out = append(out, reason)
+		}
+	}
+	return out
+}
+
+// hashPII replaces an item of personal data with a keyed hash of its
+// normalized form, so the model can tell that two outputs mention the same
+// address without learning the address. The key lives only as long as the
+// Redactor, which keeps the hashes from being reversed by guessing.
+func (r *Redactor) hashPII(reason, value string, normalize func(string) string) string {
+	if normalize != nil {
+		value = normalize(value)
+	}
+	mac := hmac.New(sha256.New, r.piiKey)
+	mac.Write([]byte(value))
+	return "[" + strings.TrimPrefix(reason, piiPrefix) + ":" + hex.EncodeToString(mac.Sum(nil))[:12] + "]"
+}
+
+// normalizeEmail lower-cases an address so that case variants hash alike.
+func normalizeEmail(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// normalizePhone keeps the digits of a phone number and a leading plus sign,
+// so that "(555) 123-4567" and "555.123.4567" hash alike.
+func normalizePhone(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if r >= '0' && r <= '9' || r == '+' && i == 0 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// normalizeDigits keeps only the digits and letters of an identifier written
+// with spaces or dashes, such as a card number or IBAN.
+func normalizeDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r != ' ' && r != '-' {
+			b.WriteRune(r)
+		}
+	}
+	return strings.ToUpper(b.String())
+}
+
+// validPhone accepts numbers with 10 to 15 digits, the range E.164 allows for
+// a full national or international number.
+func validPhone(s string) bool {
+	n := len(strings.TrimPrefix(normalizePhone(s), "+"))
+	return n >= 10 && n <= 15
+}
+
+// validPublicIP accepts addresses that identify a host on the internet.
+// Loopback, private and link-local addresses describe infrastructure, not
+// people.
+func validPublicIP(s string) bool {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return false
+	}
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
+
+// validCard checks the length, issuer prefix and Luhn checksum of a payment
+// card number.
+func validCard(s string) bool {
+	digits := normalizeDigits(s)
+	if len(digits) < 13 || len(digits) > 19 || !strings.ContainsRune("3456", rune(digits[0])) {
+		return false
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// validIBAN checks an IBAN's length and ISO 13616 mod-97 checksum.
+func validIBAN(s string) bool {
+	iban := normalizeDigits(s)
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	var numeric strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			numeric.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			numeric.WriteString(big.NewInt(int64(r - 'A' + 10)).String())
+		default:
+			return false
+		}
+	}
+	n, ok := new(big.Int).SetString(numeric.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// validSSN rejects US social security numbers that are never issued: area
+// 000, 666 or 900-999, group 00, or serial 0000.
+func validSSN(s string) bool {
+	area, rest, _ := strings.Cut(s, "-")
+	group, serial, _ := strings.Cut(rest, "-")
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
@@ -0,0 +1,91 @@
+package redact
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"bridgekeeper/internal/types"
+)
+
+func TestDetect_PII(t *testing.T) {
+	r := New()
+	tests := []struct {
+		text   string
+		reason string // empty when no personal data should be found
+	}{
+		{"contact Jane.Doe@acme.io for a refund", "pii_email"},
+		{"call (415) 555-0132 after 5pm", "pii_phone"},
+		{"reach us on +44 20 7946 0958", "pii_phone"},
+		{"login from 203.0.113.45 failed", "pii_ip"},
+		{"login from 2001:4860:4860::8888 failed", "pii_ip"},
+		{"card 4111 1111 1111 1111 declined", "pii_card"},
+		{"card 5500-0000-0000-0004 declined", "pii_card"},
+		{"pay to DE89 3704 0044 0532 0130 00", "pii_iban"},
+		{"pay to GB82WEST12345698765432", "pii_iban"},
+		{"ssn 123-45-6789 on file", "pii_ssn"},
+
+		{"author dev@example.com", ""},
+		{"git clone git@github.com:org/repo.git", ""},
+		{"listening on 127.0.0.1:8080 and 10.0.0.7", ""},
+		{"card 4111 1111 1111 1112 declined", ""},
+		{"pay to DE88 3704 0044 0532 0130 00", ""},
+		{"ssn 000-45-6789 on file", ""},
+		{"build 1700000000123 at 12:30:45", ""},
+		{"released 2024-01-15, version 1.2.3", ""},
+	}
+	for _, tt := range tests {
+		got := r.Detect(tt.text).PII()
+		if tt.reason == "" {
+			if len(got) > 0 {
+				t.Errorf("Detect(%q) PII = %v, want none", tt.text, got)
+			}
+			continue
+		}
+		if !slices.Contains(got, tt.reason) {
+			t.Errorf("Detect(%q) PII = %v, want %s", tt.text, got, tt.reason)
+		}
+	}
+}
+
+func TestRedactOutput_PIIModes(t *testing.T) {
+	r := New()
+	const text = "from Jane.Doe@acme.io token=hunter2"
+
+	if got := r.RedactOutput("", text, types.PIIMask); got != "from [REDACTED] token[REDACTED]" {
+		t.Errorf("mask: got %q", got)
+	}
+	if got := r.RedactOutput("", text, types.PIIAllow); got != "from Jane.Doe@acme.io token[REDACTED]" {
+		t.Errorf("allow: got %q, want personal data kept and secrets masked", got)
+	}
+
+	hashed := r.RedactOutput("", text, types.PIIHash)
+	if strings.Contains(hashed, "Jane") || !strings.HasPrefix(hashed, "from [email:") {
+		t.Errorf("hash: got %q", hashed)
+	}
+	if again := r.RedactOutput("", "from jane.doe@ACME.IO token=x", types.PIIHash); again[:len("from [email:")+12] != hashed[:len("from [email:")+12] {
+		t.Errorf("hash of a case variant = %q, want the same hash as %q", again, hashed)
+	}
+	if other := New().RedactOutput("", text, types.PIIHash); other == hashed {
+		t.Error("two redactors produced the same hash; the key should differ")
+	}
+}
+
+func TestHashPII_NormalizesPhoneNumbers(t *testing.T) {
+	r := New()
+	a := r.RedactOutput("", "call (415) 555-0132", types.PIIHash)
+	b := r.RedactOutput("", "call 415.555.0132", types.PIIHash)
+	if a != b || !strings.Contains(a, "[phone:") {
+		t.Errorf("hashes differ for one number: %q vs %q", a, b)
+	}
+}
+
+func TestNewFromRules_DisablePII(t *testing.T) {
+	r, err := NewFromRules(&RuleFile{Disable: []string{"pii_ip"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Detect("login from 203.0.113.45").PII(); len(got) > 0 {
+		t.Errorf("with pii_ip disabled, PII = %v", got)
+	}
+}
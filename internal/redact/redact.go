@@ -1,70 +1,170 @@
 package redact
 
-import "regexp"
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"bridgekeeper/internal/types"
+)
+
+// mask replaces the redacted part of a match.
+const mask = "[REDACTED]"
 
 // Classification describes whether output looks sensitive enough to redact.
+// Confidence is the strongest single finding, from 0 to 1: a known secret
+// format is 1, a string flagged only for its entropy scores lower.
 type Classification struct {
-	Sensitive bool     `json:"sensitive"`
-	Reasons   []string `json:"reasons,omitempty"`
+	Sensitive  bool     `json:"sensitive"`
+	Confidence float64  `json:"confidence,omitempty"`
+	Reasons    []string `json:"reasons,omitempty"`
 }
 
 // Redactor masks known secret formats before audit logging or model handoff.
 type Redactor struct {
 	patterns []pattern
+	entropy  *entropyDetector // nil when entropy detection is disabled
+	piiKey   []byte           // keys the hashes of personal data
+	allow    []*regexp.Regexp // the rule file's allow list, also applied to values under sensitive keys
 }
 
 type pattern struct {
-	reason string
-	expr   *regexp.Regexp
+	reason    string
+	expr      *regexp.Regexp
+	maskGroup int               // capture group to mask; 0 masks the whole match
+	allow     []*regexp.Regexp  // matches whose masked text matches one are left alone
+	tools     []string          // tools the pattern applies to; empty means all
+	validate  func(string) bool // confirms a candidate secret; nil accepts every match
+	normalize func(string) string
+	pii       bool // the pattern finds personal data rather than a secret
 }
 
 // New returns a Redactor with a small set of pragmatic secret detectors.
 func New() *Redactor {
-	return &Redactor{
-		patterns: []pattern{
-			{reason: "bearer_token", expr: regexp.MustCompile(`(?i)(authorization\s*:\s*bearer\s+)[^\s]+`)},
-			{reason: "openai_key", expr: regexp.MustCompile(`(?i)\b(sk-[A-Za-z0-9_-]{10,})\b`)},
-			{reason: "github_token", expr: regexp.MustCompile(`(?i)\b(ghp_[A-Za-z0-9]{10,}|github_pat_[A-Za-z0-9_]{10,})\b`)},
-			{reason: "google_api_key", expr: regexp.MustCompile(`(?i)\b(AIza[0-9A-Za-z\-_]{10,})\b`)},
-			{reason: "api_key_assignment", expr: regexp.MustCompile(`(?i)\b(api[_-]?key|token|secret|password)\b\s*[:=]\s*['"]?[^\s'"]+['"]?`)},
-		},
+	r, err := NewFromRules(nil)
+	if err != nil {
+		// The built-in rules are fixed and covered by tests.
+		panic(err)
 	}
+	return r
 }
 
-// RedactText masks any known secret-like substrings.
+// RedactText masks any known secret-like substrings and personal data.
 func (r *Redactor) RedactText(input string) string {
+	return r.redact("", input, types.PIIMask, nil)
+}
+
+// RedactTextFor masks secret-like substrings and personal data using the
+// rules that apply to tool. An empty tool uses every rule.
+func (r *Redactor) RedactTextFor(tool, input string) string {
+	return r.redact(tool, input, types.PIIMask, nil)
+}
+
+// RedactOutput redacts a tool's output before it is handed to the model.
+// Secrets are always masked; personal data is masked, hashed or left alone as
+// mode says. Blocking is the caller's job, so PIIBlock masks.
+func (r *Redactor) RedactOutput(tool, input string, mode types.PIIMode) string {
+	return r.redact(tool, input, mode, nil)
+}
+
+// TokenizeOutput is RedactOutput, except that secrets are replaced with
+// placeholders held in vault rather than masked. A nil vault masks. Values
+// the vault already holds are replaced first, wherever they appear.
+func (r *Redactor) TokenizeOutput(tool, input string, mode types.PIIMode, vault *Vault) string {
+	return r.redact(tool, vault.Hide(input), mode, vault)
+}
+
+// Withhold hides the whole of input, for output whose source is known to be
+// secret. The model always gets the mask, never a vault placeholder: a
+// placeholder could be written to an unlabelled file and read back in the
+// clear. Empty input stays empty.
+func Withhold(input string) string {
+	if input == "" {
+		return input
+	}
+	return mask
+}
+
+// redact applies every pattern that applies to tool, or every pattern when
+// tool is empty. Secrets go to vault when it is set.
+func (r *Redactor) redact(tool, input string, mode types.PIIMode, vault *Vault) string {
 	if r == nil || input == "" {
 		return input
 	}
 
-	out := input
-	for _, pattern := range r.patterns {
-		out = pattern.expr.ReplaceAllStringFunc(out, func(match string) string {
-			submatches := pattern.expr.FindStringSubmatch(match)
-			if len(submatches) == 2 && len(submatches[1]) < len(match) {
-				return submatches[1] + "[REDACTED]"
-			}
-			return "[REDACTED]"
-		})
+	out := r.redactStructured(input, vault.substitute())
+	for _, p := range r.patterns {
+		if !p.appliesTo(tool) {
+			continue
+		}
+		switch {
+		case !p.pii:
+			out = p.replace(out, vault.substitute())
+		case mode == types.PIIAllow:
+		case mode == types.PIIHash:
+			out = p.replace(out, func(value string) string {
+				return r.hashPII(p.reason, value, p.normalize)
+			})
+		default:
+			out = p.replace(out, nil)
+		}
 	}
-	return out
+	return r.maskEntropy(tool, out, vault.substitute())
+}
+
+// maskEntropy replaces the high-entropy strings left after the patterns ran
+// with sub(value), or with the mask when sub is nil.
+func (r *Redactor) maskEntropy(tool, text string, sub func(string) string) string {
+	findings := r.entropy.findings(tool, text)
+	if len(findings) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, f := range findings {
+		b.WriteString(text[last:f.start])
+		if sub != nil {
+			b.WriteString(sub(text[f.start:f.end]))
+		} else {
+			b.WriteString(mask)
+		}
+		last = f.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
 }
 
 // RedactValue recursively redacts strings within arbitrary JSON-like values.
+// Every scalar under a key that names a secret, such as "db_pass", is masked
+// whatever it looks like.
 func (r *Redactor) RedactValue(v any) any {
+	return r.redactValue("", v)
+}
+
+// RedactValueFor is RedactValue using the rules that apply to tool.
+func (r *Redactor) RedactValueFor(tool string, v any) any {
+	return r.redactValue(tool, v)
+}
+
+func (r *Redactor) redactValue(tool string, v any) any {
 	switch value := v.(type) {
 	case string:
-		return r.RedactText(value)
+		return r.redact(tool, value, types.PIIMask, nil)
 	case []any:
 		out := make([]any, 0, len(value))
 		for _, item := range value {
-			out = append(out, r.RedactValue(item))
+			out = append(out, r.redactValue(tool, item))
 		}
 		return out
 	case map[string]any:
 		out := make(map[string]any, len(value))
 		for key, item := range value {
-			out[key] = r.RedactValue(item)
+			if sensitiveKey(key) {
+				out[key] = r.maskValue(item)
+			} else {
+				out[key] = r.redactValue(tool, item)
+			}
 		}
 		return out
 	default:
@@ -72,18 +172,137 @@ func (r *Redactor) RedactValue(v any) any {
 	}
 }
 
+// maskValue masks every string and number within v, leaving the structure,
+// booleans, nulls and exempt strings alone.
+func (r *Redactor) maskValue(v any) any {
+	switch value := v.(type) {
+	case string:
+		if r.exempt(value) {
+			return value
+		}
+		return mask
+	case []any:
+		out := make([]any, 0, len(value))
+		for _, item := range value {
+			out = append(out, r.maskValue(item))
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(value))
+		for key, item := range value {
+			out[key] = r.maskValue(item)
+		}
+		return out
+	case bool, nil:
+		return v
+	default:
+		return mask
+	}
+}
+
 // Detect classifies text using the same secret-oriented heuristics used by redaction.
 func (r *Redactor) Detect(text string) Classification {
+	return r.detect("", text)
+}
+
+// DetectFor classifies text using the rules that apply to tool.
+func (r *Redactor) DetectFor(tool, text string) Classification {
+	return r.detect(tool, text)
+}
+
+func (r *Redactor) detect(tool, text string) Classification {
 	if r == nil || text == "" {
 		return Classification{}
 	}
 
 	var out Classification
-	for _, pattern := range r.patterns {
-		if pattern.expr.MatchString(text) {
+	if len(r.structuredSpans(text)) > 0 {
+		out.Sensitive = true
+		out.Confidence = 0.9
+		out.Reasons = append(out.Reasons, "sensitive_key")
+	}
+	for _, p := range r.patterns {
+		if p.appliesTo(tool) && p.matches(text) {
 			out.Sensitive = true
-			out.Reasons = append(out.Reasons, pattern.reason)
+			out.Confidence = 1
+			out.Reasons = append(out.Reasons, p.reason)
+		}
+	}
+	for _, f := range r.entropy.findings(tool, text) {
+		out.Sensitive = true
+		out.Confidence = max(out.Confidence, f.confidence)
+		if !slices.Contains(out.Reasons, f.reason) {
+			out.Reasons = append(out.Reasons, f.reason)
 		}
 	}
 	return out
 }
+
+// appliesTo reports whether p is used for tool. An empty tool selects every
+// pattern, which is the safe choice when the source of text is unknown.
+func (p pattern) appliesTo(tool string) bool {
+	return tool == "" || len(p.tools) == 0 || slices.Contains(p.tools, tool)
+}
+
+// secret returns the span of a match that would be masked, or -1, -1 when the
+// mask group did not take part in the match.
+func (p pattern) secret(loc []int) (int, int) {
+	return loc[2*p.maskGroup], loc[2*p.maskGroup+1]
+}
+
+// allowed reports whether a candidate secret should be left alone: it is
+// already masked, exempted by an allow expression, or fails the pattern's
+// validation.
+func (p pattern) allowed(secret string) bool {
+	// Text already masked, for instance by structured redaction, is left
+	// alone so that redacting twice changes nothing.
+	if strings.Contains(secret, mask) || placeholderPattern.MatchString(secret) {
+		return true
+	}
+	if p.validate != nil && !p.validate(secret) {
+		return true
+	}
+	for _, allow := range p.allow {
+		if allow.MatchString(secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p pattern) matches(text string) bool {
+	for _, loc := range p.expr.FindAllStringSubmatchIndex(text, -1) {
+		start, end := p.secret(loc)
+		if start >= 0 && !p.allowed(text[start:end]) {
+			return true
+		}
+	}
+	return false
+}
+
+// replace substitutes each unallowed secret in text with sub(secret), or with
+// the mask when sub is nil.
+func (p pattern) replace(text string, sub func(string) string) string {
+	locs := p.expr.FindAllStringSubmatchIndex(text, -1)
+	if locs == nil {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range locs {
+		start, end := p.secret(loc)
+		if start < 0 || p.allowed(text[start:end]) {
+			continue
+		}
+		b.WriteString(text[last:start])
+		if sub != nil {
+			b.WriteString(sub(text[start:end]))
+		} else {
+			b.WriteString(mask)
+		}
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
@@ -0,0 +1,249 @@
+package redact
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RuleFile is a redaction rule set loaded from YAML, usually redact.yaml next
+// to the policy. Its rules are added to the built-in rules unless Builtins is
+// false; individual built-ins can be turned off with Disable.
+type RuleFile struct {
+	Version  string         `yaml:"version"`
+	Builtins *bool          `yaml:"builtins,omitempty"` // true when unset
+	Disable  []string       `yaml:"disable,omitempty"`  // names of built-in rules to drop
+	Allow    []string       `yaml:"allow,omitempty"`    // regexes exempting masked text from every rule
+	Entropy  *EntropyConfig `yaml:"entropy,omitempty"`  // high-entropy string detection; on by default
+	Rules    []Rule         `yaml:"rules"`
+}
+
+// Rule is one named secret detector. Rules whose names start with "pii_" find
+// personal data instead, which each capability may mask, hash, block or allow.
+type Rule struct {
+	Name      string    `yaml:"name"`
+	Pattern   string    `yaml:"pattern"`
+	MaskGroup int       `yaml:"mask_group,omitempty"` // capture group to mask; 0 masks the whole match
+	Allow     []string  `yaml:"allow,omitempty"`      // regexes exempting masked text from this rule
+	Tools     []string  `yaml:"tools,omitempty"`      // tools the rule applies to; empty means all
+	Tests     RuleTests `yaml:"tests,omitempty"`
+
+	// validate, when set, confirms a candidate secret, for formats that carry
+	// a checksum or a recognisable structure. normalize, when set, puts
+	// personal data in a canonical form before it is hashed. Only built-in
+	// rules have them.
+	validate  func(string) bool
+	normalize func(string) string
+}
+
+// RuleTests are examples checked when a rule is loaded. Every Match string
+// must be redacted by the rule and no NoMatch string may be.
+type RuleTests struct {
+	Match   []string `yaml:"match,omitempty"`
+	NoMatch []string `yaml:"no_match,omitempty"`
+}
+
+// builtinRules are the detectors used when no rule file says otherwise.
+var builtinRules = []Rule{
+	{Name: "bearer_token", Pattern: `(?i)(authorization\s*:\s*bearer\s+)([^\s]+)`, MaskGroup: 2},
+	{Name: "openai_key", Pattern: `(?i)\b(sk-[A-Za-z0-9_-]{10,})\b`},
+	{Name: "github_token", Pattern: `(?i)\b(ghp_[A-Za-z0-9]{10,}|github_pat_[A-Za-z0-9_]{10,})\b`},
+	{Name: "google_api_key", Pattern: `(?i)\b(AIza[0-9A-Za-z\-_]{10,})\b`},
+	{Name: "api_key_assignment", Pattern: `(?i)\b(api[_-]?key|token|secret|password)\b(\s*[:=]\s*['"]?[^\s'"]+['"]?)`, MaskGroup: 2},
+	{Name: "aws_access_key_id", Pattern: `\b((?:AKIA|ASIA|ABIA|ACCA)[A-Z2-7]{16})\b`},
+	{Name: "aws_secret_access_key", Pattern: `(?i)\b(aws_?secret(?:_?access)?_?key)\b(["']?\s*[:=]\s*["']?)([A-Za-z0-9/+]{40})(?:[^A-Za-z0-9/+=]|$)`, MaskGroup: 3},
+	{Name: "slack_token", Pattern: `\b(xox[abeprs]-[0-9A-Za-z-]{10,})\b`},
+	{Name: "slack_webhook", Pattern: `(https://hooks\.slack\.com/services/)(T[A-Z0-9]+/B[A-Z0-9]+/[A-Za-z0-9]+)`, MaskGroup: 2},
+	{Name: "stripe_key", Pattern: `\b((?:sk|rk)_(?:live|test)_[0-9A-Za-z]{16,})\b`},
+	{Name: "jwt", Pattern: `\b(eyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,})`, validate: validJWT},
+	{Name: "private_key", Pattern: `(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----(?:.*?-----END [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----|.*)`},
+	{Name: "connection_string", Pattern: `\b([a-zA-Z][a-zA-Z0-9+.-]*://[^:/?#\s@]+:)([^@\s/?#]+)@`, MaskGroup: 2, Allow: []string{`^(\$\{[^}]+\}|\$[A-Za-z_][A-Za-z0-9_]*|\*+)$`}},
+	{Name: "netrc_password", Pattern: `\b(machine\s+\S+(?:\s+login\s+\S+)?\s+password\s+)(\S+)`, MaskGroup: 2},
+	{Name: "npmrc_auth", Pattern: `(?m)(:?_(?:authToken|auth|password)\s*=\s*)(\S+)`, MaskGroup: 2, Allow: []string{`^\$\{[^}]+\}$`}},
+	{Name: "npm_token", Pattern: `\b(npm_[A-Za-z0-9]{36})\b`, validate: validNPMToken},
+
+	// Personal data. The leading guards keep matches from starting inside a
+	// longer number, URL or version string.
+	{
+		Name:      "pii_email",
+		Pattern:   `(?:^|[^A-Za-z0-9._%+/:-])([A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,})`,
+		MaskGroup: 1,
+		Allow:     []string{`(?i)@([a-z0-9-]+\.)*example\.(com|net|org)$`, `(?i)\.(test|example|invalid|localhost)$`, `^git@`},
+		normalize: normalizeEmail,
+	},
+	{
+		Name:      "pii_phone",
+		Pattern:   `(?:^|[^\w+.-])((?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\) ?|\d{3}[ .-])\d{3}[ .-]\d{4}|\+\d{1,3}(?:[ .-]?\d{2,4}){3,5})\b`,
+		MaskGroup: 1,
+		validate:  validPhone,
+		normalize: normalizePhone,
+	},
+	{
+		Name:      "pii_ip",
+		Pattern:   `(?:^|[^\w.:])((?:\d{1,3}\.){3}\d{1,3}|(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{1,4})\b`,
+		MaskGroup: 1,
+		validate:  validPublicIP,
+	},
+	{
+		Name:      "pii_card",
+		Pattern:   `(?:^|[^\w.-])(\d(?:[ -]?\d){12,18})\b`,
+		MaskGroup: 1,
+		validate:  validCard,
+		normalize: normalizeDigits,
+	},
+	{
+		Name:      "pii_iban",
+		Pattern:   `\b([A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,4})?)\b`,
+		validate:  validIBAN,
+		normalize: normalizeDigits,
+	},
+	{
+		Name:      "pii_ssn",
+		Pattern:   `(?:^|[^\w-])(\d{3}-\d{2}-\d{4})\b`,
+		MaskGroup: 1,
+		validate:  validSSN,
+	},
+}
+
+// LoadRules reads a rule file from path.
+func LoadRules(path string) (*RuleFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redaction rules: %w", err)
+	}
+	var rf RuleFile
+	if err := yaml.Unmarshal(data, &rf); err != nil {
+		return nil, fmt.Errorf("failed to parse redaction rules YAML: %w", err)
+	}
+	return &rf, nil
+}
+
+// NewFromRules returns a Redactor built from rf, or from the built-in rules
+// alone when rf is nil. Every rule must compile and pass its tests; all
+// problems are reported together.
+func NewFromRules(rf *RuleFile) (*Redactor, error) {
+	if rf == nil {
+		rf = &RuleFile{}
+	}
+
+	var errs []error
+	var globalAllow []*regexp.Regexp
+	for _, raw := range rf.Allow {
+		expr, err := regexp.Compile(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("allow %q: %w", raw, err))
+			continue
+		}
+		globalAllow = append(globalAllow, expr)
+	}
+
+	var rules []Rule
+	if rf.Builtins == nil || *rf.Builtins {
+		for _, name := range rf.Disable {
+			if !slices.ContainsFunc(builtinRules, func(r Rule) bool { return r.Name == name }) {
+				errs = append(errs, fmt.Errorf("disable: no built-in rule %q", name))
+			}
+		}
+		for _, rule := range builtinRules {
+			if !slices.Contains(rf.Disable, rule.Name) {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	rules = append(rules, rf.Rules...)
+
+	entropy, err := newEntropyDetector(rf.Entropy)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		errs = append(errs, fmt.Errorf("generating the PII hash key: %w", err))
+	}
+
+	r := &Redactor{entropy: entropy, piiKey: key, allow: globalAllow}
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		if seen[rule.Name] {
+			errs = append(errs, fmt.Errorf("rule %q: defined more than once", rule.Name))
+			continue
+		}
+		seen[rule.Name] = true
+
+		p, err := compileRule(rule, globalAllow)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := p.check(rule.Tests); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r.patterns = append(r.patterns, p)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return r, nil
+}
+
+func compileRule(rule Rule, globalAllow []*regexp.Regexp) (pattern, error) {
+	if strings.TrimSpace(rule.Name) == "" {
+		return pattern{}, fmt.Errorf("rule with pattern %q: name is required", rule.Pattern)
+	}
+	if rule.Pattern == "" {
+		return pattern{}, fmt.Errorf("rule %q: pattern is required", rule.Name)
+	}
+	expr, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return pattern{}, fmt.Errorf("rule %q: %w", rule.Name, err)
+	}
+	if rule.MaskGroup < 0 || rule.MaskGroup > expr.NumSubexp() {
+		return pattern{}, fmt.Errorf("rule %q: mask_group %d, but the pattern has %d groups", rule.Name, rule.MaskGroup, expr.NumSubexp())
+	}
+
+	p := pattern{
+		reason:    rule.Name,
+		expr:      expr,
+		maskGroup: rule.MaskGroup,
+		allow:     slices.Clone(globalAllow),
+		tools:     rule.Tools,
+		validate:  rule.validate,
+		normalize: rule.normalize,
+		pii:       isPII(rule.Name),
+	}
+	for _, raw := range rule.Allow {
+		allow, err := regexp.Compile(raw)
+		if err != nil {
+			return pattern{}, fmt.Errorf("rule %q: allow %q: %w", rule.Name, raw, err)
+		}
+		p.allow = append(p.allow, allow)
+	}
+	return p, nil
+}
+
+// check runs a rule's examples against its compiled pattern.
+func (p pattern) check(tests RuleTests) error {
+	var failures []string
+	for _, example := range tests.Match {
+		if !p.matches(example) {
+			failures = append(failures, fmt.Sprintf("expected a match in %q", example))
+		}
+	}
+	for _, example := range tests.NoMatch {
+		if p.matches(example) {
+			failures = append(failures, fmt.Sprintf("expected no match in %q", example))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("rule %q: %s", p.reason, strings.Join(failures, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package redact
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewFromRules_Builtins(t *testing.T) {
+	r := New()
+	tests := map[string]string{
+		"Authorization: Bearer abc.def":  "Authorization: Bearer [REDACTED]",
+		"key sk-1234567890abcdef here":   "key [REDACTED] here",
+		"api_key=hunter2 and more":       "api_key[REDACTED] and more",
+		"nothing sensitive in this line": "nothing sensitive in this line",
+	}
+	for in, want := range tests {
+		if got := r.RedactText(in); got != want {
+			t.Errorf("RedactText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNewFromRules_CustomRules(t *testing.T) {
+	no := false
+	rf := &RuleFile{
+		Builtins: &no,
+		Allow:    []string{`^EXAMPLE`},
+		Rules: []Rule{
+			{
+				Name:      "acme_token",
+				Pattern:   `\b(acme_)([a-z0-9]{16})\b`,
+				MaskGroup: 2,
+				Allow:     []string{`^0+$`},
+				Tools:     []string{"http"},
+				Tests: RuleTests{
+					Match:   []string{"token acme_abcdef0123456789"},
+					NoMatch: []string{"acme_0000000000000000", "acme_short"},
+				},
+			},
+			{Name: "ticket", Pattern: `SEC-\d+`},
+		},
+	}
+	r, err := NewFromRules(rf)
+	if err != nil {
+		t.Fatalf("NewFromRules() error = %v", err)
+	}
+
+	if got := r.RedactTextFor("http", "acme_abcdef0123456789"); got != "acme_[REDACTED]" {
+		t.Errorf("RedactTextFor(http) = %q, want group masked", got)
+	}
+	if got := r.RedactTextFor("fs", "acme_abcdef0123456789"); got != "acme_abcdef0123456789" {
+		t.Errorf("RedactTextFor(fs) = %q, want rule not applied", got)
+	}
+	if got := r.RedactText("acme_abcdef0123456789"); got != "acme_[REDACTED]" {
+		t.Errorf("RedactText() = %q, want every rule applied when the tool is unknown", got)
+	}
+	if got := r.RedactText("SEC-42 and EXAMPLE"); got != "[REDACTED] and EXAMPLE" {
+		t.Errorf("RedactText() = %q", got)
+	}
+	if got := r.RedactText("sk-1234567890abcdef"); got != "sk-1234567890abcdef" {
+		t.Errorf("RedactText() = %q, want built-ins disabled", got)
+	}
+	if c := r.DetectFor("fs", "acme_abcdef0123456789"); c.Sensitive {
+		t.Errorf("DetectFor(fs) = %+v, want not sensitive", c)
+	}
+	if c := r.DetectFor("http", "acme_abcdef0123456789"); !c.Sensitive || c.Reasons[0] != "acme_token" {
+		t.Errorf("DetectFor(http) = %+v, want acme_token", c)
+	}
+}
+
+func TestNewFromRules_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		rf   RuleFile
+		want string
+	}{
+		{"bad regex", RuleFile{Rules: []Rule{{Name: "x", Pattern: `(`}}}, `rule "x"`},
+		{"missing name", RuleFile{Rules: []Rule{{Pattern: `x`}}}, "name is required"},
+		{"mask group out of range", RuleFile{Rules: []Rule{{Name: "x", Pattern: `(a)`, MaskGroup: 2}}}, "mask_group 2"},
+		{"failing match test", RuleFile{Rules: []Rule{{Name: "x", Pattern: `abc`, Tests: RuleTests{Match: []string{"xyz"}}}}}, "expected a match"},
+		{"failing no_match test", RuleFile{Rules: []Rule{{Name: "x", Pattern: `abc`, Tests: RuleTests{NoMatch: []string{"abcd"}}}}}, "expected no match"},
+		{"duplicate name", RuleFile{Rules: []Rule{{Name: "openai_key", Pattern: `x`}}}, "more than once"},
+		{"unknown disable", RuleFile{Disable: []string{"nope"}}, `no built-in rule "nope"`},
+		{"bad allow", RuleFile{Allow: []string{`[`}}, "allow"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFromRules(&tt.rf)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("NewFromRules() error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRules_ShippedFile(t *testing.T) {
+	rf, err := LoadRules(filepath.Join("..", "..", "policies", "redact.yaml"))
+	if err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+	r, err := NewFromRules(rf)
+	if err != nil {
+		t.Fatalf("NewFromRules() error = %v", err)
+	}
+	if got := r.RedactText("password=${DB_PASSWORD}"); got != "password=${DB_PASSWORD}" {
+		t.Errorf("RedactText() = %q, want placeholder allowed", got)
+	}
+	if got := r.RedactText("password=hunter2"); got != "password[REDACTED]" {
+		t.Errorf("RedactText() = %q, want masked", got)
+	}
+}
+
+func TestLoadRules_Missing(t *testing.T) {
+	if _, err := LoadRules(filepath.Join(t.TempDir(), "none.yaml")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadRules() error = %v, want not-exist", err)
+	}
+}
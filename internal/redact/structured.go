@@ -0,0 +1,511 @@
+package redact
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Structured redaction masks values by the name of their key, for secrets
+// that look like nothing in particular: {"db_pass": "hunter2"}. JSON, .env,
+// INI and YAML documents are recognised by their content. Masked values are
+// spliced into the original text, so the document keeps its layout and stays
+// readable; text that parses as none of these is left to the patterns.
+
+// sensitiveWords are key words that name a secret on their own.
+var sensitiveWords = map[string]bool{
+	"pass": true, "password": true, "passwd": true, "passphrase": true, "pwd": true,
+	"secret": true, "secrets": true, "token": true, "credential": true, "credentials": true,
+	"auth": true, "authorization": true, "cookie": true, "apikey": true, "salt": true, "dsn": true,
+}
+
+// sensitiveCompounds name a secret when two adjacent key words form them, as
+// in api_key or privateKey.
+var sensitiveCompounds = map[string]bool{
+	"apikey": true, "accesskey": true, "privatekey": true, "secretkey": true, "signingkey": true,
+	"encryptionkey": true, "masterkey": true, "sessionid": true, "connectionstring": true,
+}
+
+// describingWords, at the end of a key, say that the value describes a
+// secret rather than holding it: password_file, token_url, auth_type.
+var describingWords = map[string]bool{
+	"type": true, "url": true, "uri": true, "endpoint": true, "name": true, "file": true,
+	"path": true, "dir": true, "env": true, "var": true, "header": true, "format": true,
+	"length": true, "len": true, "count": true, "expiry": true, "expires": true, "at": true,
+	"in": true, "ttl": true, "policy": true, "required": true, "enabled": true, "hint": true,
+	"prompt": true,
+}
+
+// sensitiveKey reports whether a key names a secret. Keys are split into
+// words at punctuation and camel-case boundaries.
+func sensitiveKey(key string) bool {
+	words := keyWords(key)
+	if len(words) == 0 {
+		return false
+	}
+	last := len(words) - 1
+	for i, word := range words {
+		if sensitiveWords[word] && (i == last || !describingWords[words[last]]) {
+			return true
+		}
+		if i < last && sensitiveCompounds[word+words[i+1]] && (i+1 == last || !describingWords[words[last]]) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyWords splits a key like "dbPassword" or "DB_PASSWORD" into lower-case
+// words.
+func keyWords(key string) []string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, strings.ToLower(string(cur)))
+			cur = cur[:0]
+		}
+	}
+	var prev rune
+	for _, r := range key {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && unicode.IsLower(prev):
+			flush()
+			cur = append(cur, r)
+		default:
+			cur = append(cur, r)
+		}
+		prev = r
+	}
+	flush()
+	return words
+}
+
+// span is a value to mask in a structured document: text[start:end] is the
+// raw value, without the quotes around it. quote is set for values that were
+// not quoted, where a bare mask would change how the document parses.
+type span struct {
+	start, end int
+	quote      bool
+}
+
+// reference matches values that point at a secret instead of holding one.
+var reference = regexp.MustCompile(`^(\$\{[^}]+\}|\$[A-Za-z_][A-Za-z0-9_]*|<[^>]+>)$`)
+
+// exempt reports whether a value under a sensitive key may stay: empty
+// values, references to variables, and values already masked.
+func (r *Redactor) exempt(value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" || reference.MatchString(value) || strings.Contains(value, mask) || placeholderPattern.MatchString(value) {
+		return true
+	}
+	for _, allow := range r.allow {
+		if allow.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// structuredSpans returns the values under sensitive keys when text is a
+// JSON, .env, INI or YAML document, or else the values of key/value pairs
+// written inline in free text.
+func (r *Redactor) structuredSpans(text string) []span {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return nil
+	}
+	var spans []span
+	ok := false
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		spans, ok = jsonSpans(text)
+	}
+	if !ok {
+		spans, ok = lineSpans(text)
+	}
+	if !ok {
+		spans, ok = yamlSpans(text)
+	}
+	if !ok {
+		spans = inlineSpans(text)
+	}
+	return slices.DeleteFunc(spans, func(s span) bool { return r.exempt(text[s.start:s.end]) })
+}
+
+// redactStructured masks the values under sensitive keys, passing each to sub
+// instead when it is set.
+func (r *Redactor) redactStructured(text string, sub func(string) string) string {
+	spans := r.structuredSpans(text)
+	if len(spans) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		b.WriteString(text[last:s.start])
+		switch {
+		case sub != nil:
+			b.WriteString(sub(text[s.start:s.end]))
+		case s.quote:
+			b.WriteString(`"` + mask + `"`)
+		default:
+			b.WriteString(mask)
+		}
+		last = s.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// jsonFrame tracks one open JSON object or array.
+type jsonFrame struct {
+	object    bool
+	wantKey   bool
+	key       string
+	sensitive bool // the container sits under a sensitive key
+}
+
+// valueSensitive reports whether the next value in f is a secret.
+func (f *jsonFrame) valueSensitive() bool {
+	return f.sensitive || f.object && sensitiveKey(f.key)
+}
+
+// jsonSpans finds values under sensitive keys in a JSON document or stream
+// of documents, such as NDJSON.
+func jsonSpans(text string) ([]span, bool) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+
+	var spans []span
+	var stack []*jsonFrame
+	for {
+		before := dec.InputOffset()
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return spans, len(stack) == 0
+		}
+		if err != nil {
+			return nil, false
+		}
+		after := dec.InputOffset()
+
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if delim, ok := tok.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				frame := &jsonFrame{object: delim == '{', wantKey: delim == '{'}
+				if top != nil {
+					frame.sensitive = top.valueSensitive()
+				}
+				stack = append(stack, frame)
+			case '}', ']':
+				stack = stack[:len(stack)-1]
+				if len(stack) > 0 && stack[len(stack)-1].object {
+					stack[len(stack)-1].wantKey = true
+				}
+			}
+			continue
+		}
+		if top == nil {
+			continue
+		}
+		if top.object && top.wantKey {
+			top.key, _ = tok.(string)
+			top.wantKey = false
+			continue
+		}
+		if top.valueSensitive() {
+			start := int(before)
+			for start < int(after) && strings.IndexByte(" \t\r\n,:", text[start]) >= 0 {
+				start++
+			}
+			switch tok.(type) {
+			case string:
+				spans = append(spans, span{start: start + 1, end: int(after) - 1})
+			case json.Number:
+				spans = append(spans, span{start: start, end: int(after), quote: true})
+			}
+		}
+		if top.object {
+			top.wantKey = true
+		}
+	}
+}
+
+var (
+	envLine     = regexp.MustCompile(`^(\s*(?:export\s+)?[A-Za-z_][A-Za-z0-9_.-]*\s*=[ \t]*)(.*)$`)
+	iniSection  = regexp.MustCompile(`^\s*\[[^\]]+\]\s*$`)
+	iniLine     = regexp.MustCompile(`^(\s*[^\s=:\[;#][^=:]*?\s*[=:][ \t]*)(.*)$`)
+	lineComment = regexp.MustCompile(`^\s*[#;]`)
+)
+
+// lineSpans finds values under sensitive keys in a .env file, where every
+// line is KEY=value, or an INI file, which has sections and key = value or
+// key: value lines. Comment and blank lines are allowed in both. A single
+// line is more likely prose or a header than a file, so at least two entries
+// are required.
+func lineSpans(text string) ([]span, bool) {
+	lines := strings.SplitAfter(text, "\n")
+	sections := slices.ContainsFunc(lines, iniSection.MatchString)
+	pattern := envLine
+	if sections {
+		pattern = iniLine
+	}
+
+	var spans []span
+	entries := 0
+	offset := 0
+	for _, line := range lines {
+		content := strings.TrimRight(line, "\r\n")
+		lineStart := offset
+		offset += len(line)
+		if strings.TrimSpace(content) == "" || lineComment.MatchString(content) || sections && iniSection.MatchString(content) {
+			continue
+		}
+		m := pattern.FindStringSubmatchIndex(content)
+		if m == nil {
+			return nil, false
+		}
+		if !sections && strings.ContainsAny(strings.TrimSpace(stripComment(content[m[4]:m[5]])), " \t") && !quoted(content[m[4]:m[5]]) {
+			return nil, false
+		}
+		entries++
+		key := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(content[:m[3]]), "export "))
+		key = strings.TrimRight(key, "=: \t")
+		if !sensitiveKey(key) {
+			continue
+		}
+		start, end := lineValue(content, m[4], m[5])
+		spans = append(spans, span{start: lineStart + start, end: lineStart + end})
+	}
+	return spans, entries > 1
+}
+
+// quoted reports whether value starts with a quote.
+func quoted(value string) bool {
+	return value != "" && (value[0] == '"' || value[0] == '\'')
+}
+
+// stripComment drops an inline comment from an unquoted value.
+func stripComment(value string) string {
+	for _, marker := range []string{" #", " ;", "\t#", "\t;"} {
+		if i := strings.Index(value, marker); i >= 0 {
+			value = value[:i]
+		}
+	}
+	return value
+}
+
+// lineValue trims quotes and an inline comment from the value at
+// line[start:end].
+func lineValue(line string, start, end int) (int, int) {
+	value := line[start:end]
+	if len(value) >= 2 && quoted(value) {
+		if closing := strings.IndexByte(value[1:], value[0]); closing >= 0 {
+			return start + 1, start + 1 + closing
+		}
+	}
+	return start, start + len(strings.TrimRight(stripComment(value), " \t"))
+}
+
+// yamlSpans finds values under sensitive keys in a YAML stream. Only
+// mappings spread over more than one line count as structure: a plain scalar
+// document is just text, and a single "key: value" line is as likely to be
+// prose or an HTTP header.
+func yamlSpans(text string) ([]span, bool) {
+	if strings.Count(strings.TrimSpace(text), "\n") == 0 {
+		return nil, false
+	}
+	lineStarts := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	// offset converts a node position to a byte offset; yaml counts columns
+	// in characters.
+	offset := func(n *yaml.Node) int {
+		if n.Line < 1 || n.Line > len(lineStarts) {
+			return -1
+		}
+		start := lineStarts[n.Line-1]
+		i := start
+		for col := 1; col < n.Column && i < len(text) && text[i] != '\n'; col++ {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+		}
+		return i
+	}
+
+	var spans []span
+	mapping := false
+	var walk func(n *yaml.Node, sensitive bool) bool
+	walk = func(n *yaml.Node, sensitive bool) bool {
+		switch n.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range n.Content {
+				if !walk(child, sensitive) {
+					return false
+				}
+			}
+		case yaml.MappingNode:
+			mapping = true
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if !walk(n.Content[i+1], sensitive || sensitiveKey(n.Content[i].Value)) {
+					return false
+				}
+			}
+		case yaml.ScalarNode:
+			if !sensitive || n.Tag == "!!null" || n.Tag == "!!bool" {
+				return true
+			}
+			start := offset(n)
+			if start < 0 {
+				return false
+			}
+			s, ok := yamlScalarSpan(text, start, n)
+			if !ok {
+				return false
+			}
+			spans = append(spans, s)
+		}
+		return true
+	}
+
+	dec := yaml.NewDecoder(strings.NewReader(text))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil || !walk(&doc, false) {
+			return nil, false
+		}
+	}
+	if !mapping {
+		return nil, false
+	}
+	return spans, true
+}
+
+// yamlScalarSpan locates the raw text of scalar n, which starts at
+// text[start].
+func yamlScalarSpan(text string, start int, n *yaml.Node) (span, bool) {
+	switch n.Style {
+	case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+		quote := text[start]
+		for i := start + 1; i < len(text); i++ {
+			switch {
+			case quote == '"' && text[i] == '\\':
+				i++
+			case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+				i++
+			case text[i] == quote:
+				return span{start: start + 1, end: i}, true
+			}
+		}
+		return span{}, false
+	case yaml.LiteralStyle, yaml.FoldedStyle:
+		return span{start: start, end: blockEnd(text, start), quote: true}, true
+	default:
+		if strings.HasPrefix(text[start:], n.Value) {
+			return span{start: start, end: start + len(n.Value), quote: true}, true
+		}
+		// A plain scalar folded over several lines.
+		return span{start: start, end: blockEnd(text, start), quote: true}, true
+	}
+}
+
+// blockEnd returns the end of a value that starts at text[start] and
+// continues on the following lines that are indented deeper than the line it
+// starts on.
+func blockEnd(text string, start int) int {
+	lineStart := strings.LastIndexByte(text[:start], '\n') + 1
+	indent := len(text[lineStart:]) - len(strings.TrimLeft(text[lineStart:], " "))
+
+	end := strings.IndexByte(text[start:], '\n')
+	if end < 0 {
+		return len(text)
+	}
+	end += start
+	for next := end + 1; next < len(text); {
+		lineEnd := strings.IndexByte(text[next:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(text)
+		} else {
+			lineEnd += next
+		}
+		line := text[next:lineEnd]
+		if strings.TrimSpace(line) != "" {
+			if len(line)-len(strings.TrimLeft(line, " ")) <= indent {
+				break
+			}
+			end = lineEnd
+		}
+		next = lineEnd + 1
+	}
+	return end
+}
+
+// inlineKey finds quoted keys followed by a colon in free text, as in a JSON
+// fragment quoted inside a command line or an error message. The quotes may
+// be escaped when the fragment itself sits inside a quoted string. Bare
+// "key=value" pairs are left to the assignment rules.
+var inlineKey = regexp.MustCompile(`(\\?")([A-Za-z][A-Za-z0-9_.-]*)\\?"\s*:\s*`)
+
+// inlineSpans finds the values of quoted key/value pairs under sensitive keys
+// in text that is not a document of its own.
+func inlineSpans(text string) []span {
+	var spans []span
+	for _, m := range inlineKey.FindAllStringSubmatchIndex(text, -1) {
+		if !sensitiveKey(text[m[4]:m[5]]) {
+			continue
+		}
+		if start, end, ok := inlineValue(text, m[1], text[m[2]:m[3]] == `\"`); ok {
+			bare := start == m[1]
+			spans = append(spans, span{start: start, end: end, quote: bare})
+		}
+	}
+	return spans
+}
+
+// inlineValue returns the extent of the value starting at i, without its
+// quotes. A string value runs to its closing quote, written \" when the key's
+// quotes were escaped; a bare value, such as a number, runs to the next space
+// or separator.
+func inlineValue(text string, i int, escaped bool) (int, int, bool) {
+	rest := text[i:]
+	switch {
+	case escaped && strings.HasPrefix(rest, `\"`):
+		end := strings.Index(rest[2:], `\"`)
+		return i + 2, i + 2 + end, end > 0
+	case strings.HasPrefix(rest, `"`):
+		for j := 1; j < len(rest); j++ {
+			switch rest[j] {
+			case '\\':
+				j++
+			case '"':
+				return i + 1, i + j, j > 1
+			}
+		}
+		return 0, 0, false
+	}
+	end := strings.IndexAny(rest, " \t\r\n,}]\\\"")
+	if end < 0 {
+		end = len(rest)
+	}
+	return i, i + end, end > 0
+}
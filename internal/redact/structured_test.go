@@ -0,0 +1,166 @@
+package redact
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"bridgekeeper/internal/types"
+)
+
+func TestSensitiveKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"password":          true,
+		"db_pass":           true,
+		"DB_PASSWORD":       true,
+		"dbPassword":        true,
+		"api-key":           true,
+		"apiKey":            true,
+		"AWS_ACCESS_KEY":    true,
+		"client_secret":     true,
+		"Authorization":     true,
+		"session_id":        true,
+		"privateKey":        true,
+		"password_file":     false,
+		"token_url":         false,
+		"auth_type":         false,
+		"max_tokens":        false,
+		"author":            false,
+		"key":               false,
+		"public_key":        false,
+		"passenger":         false,
+		"token_expires_at":  false,
+		"refresh_token":     true,
+		"GITHUB_TOKEN":      true,
+		"connection_string": true,
+	} {
+		if got := sensitiveKey(key); got != want {
+			t.Errorf("sensitiveKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestRedactText_Structured(t *testing.T) {
+	r := New()
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"json keeps layout",
+			"{\n  \"user\": \"app\",\n  \"db_pass\": \"hunter2\",\n  \"port\": 5432\n}",
+			"{\n  \"user\": \"app\",\n  \"db_pass\": \"[REDACTED]\",\n  \"port\": 5432\n}",
+		},
+		{
+			"json nested under a sensitive key",
+			`{"credentials": {"user": "app", "pin": 1234, "mfa": true}, "name": "x"}`,
+			`{"credentials": {"user": "[REDACTED]", "pin": "[REDACTED]", "mfa": true}, "name": "x"}`,
+		},
+		{
+			"json escapes and arrays",
+			`[{"token": "a\"b"}, {"secrets": ["s1", "s2"]}]`,
+			`[{"token": "[REDACTED]"}, {"secrets": ["[REDACTED]", "[REDACTED]"]}]`,
+		},
+		{
+			"ndjson",
+			"{\"password\": \"one\"}\n{\"password\": \"two\"}\n",
+			"{\"password\": \"[REDACTED]\"}\n{\"password\": \"[REDACTED]\"}\n",
+		},
+		{
+			"env file",
+			"# db\nexport DB_HOST=db\nDB_PASSWORD=\"hunter 2\"\nSESSION_SECRET=abc123 # rotate\n",
+			"# db\nexport DB_HOST=db\nDB_PASSWORD=\"[REDACTED]\"\nSESSION_SECRET=[REDACTED] # rotate\n",
+		},
+		{
+			"env references stay",
+			"DB_HOST=db\nDB_PASSWORD=${DB_PASSWORD}\n",
+			"DB_HOST=db\nDB_PASSWORD=${DB_PASSWORD}\n",
+		},
+		{
+			"ini file",
+			"[database]\nhost = db\npassword = hunter2\n\n[smtp]\nuser: mail\nsmtp pass: 'p@ss'\n",
+			"[database]\nhost = db\npassword = [REDACTED]\n\n[smtp]\nuser: mail\nsmtp pass: '[REDACTED]'\n",
+		},
+		{
+			"yaml scalars",
+			"db:\n  host: db # primary\n  password: hunter2 # rotate\n  token: 'it''s'\n  secret: \"a\\\"b\"\n  enabled: true\n",
+			"db:\n  host: db # primary\n  password: \"[REDACTED]\" # rotate\n  token: '[REDACTED]'\n  secret: \"[REDACTED]\"\n  enabled: true\n",
+		},
+		{
+			"yaml block scalar and list",
+			"private_key: |\n  -----BEGIN-----\n  abc\nsecrets:\n  - one\n  - two\nname: app\n",
+			"private_key: \"[REDACTED]\"\nsecrets:\n  - \"[REDACTED]\"\n  - \"[REDACTED]\"\nname: app\n",
+		},
+		{
+			"truncated json masks inline pairs",
+			"{\"password\": \"hunter2\"",
+			"{\"password\": \"[REDACTED]\"",
+		},
+		{
+			"json quoted inside a message",
+			`exit status 1: {"id": 7, "db_pass": "hunter2", "token": 1234, "user": "app"}`,
+			`exit status 1: {"id": 7, "db_pass": "[REDACTED]", "token": "[REDACTED]", "user": "app"}`,
+		},
+		{
+			"escaped json inside a quoted string",
+			`command "rm {\"db_pass\": \"hunter2\"}" matches deny pattern "rm *"`,
+			`command "rm {\"db_pass\": \"[REDACTED]\"}" matches deny pattern "rm *"`,
+		},
+		{
+			"prose is untouched",
+			"The password is not stored.\nSee the docs.",
+			"The password is not stored.\nSee the docs.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.RedactText(tt.in); got != tt.want {
+				t.Errorf("RedactText() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetect_SensitiveKey(t *testing.T) {
+	got := New().Detect(`{"db_pass": "hunter2"}`)
+	if !got.Sensitive || !slices.Contains(got.Reasons, "sensitive_key") {
+		t.Errorf("Detect() = %+v, want sensitive_key", got)
+	}
+	if got := New().Detect(`{"db_pass": ""}`); got.Sensitive {
+		t.Errorf("empty value: Detect() = %+v", got)
+	}
+}
+
+func TestRedactValue_KeyAware(t *testing.T) {
+	got := New().RedactValue(map[string]any{
+		"db_pass": "hunter2",
+		"auth":    map[string]any{"user": "app", "retries": float64(3), "tls": true},
+		"note":    "plain",
+		"env":     "${TOKEN}",
+		"token":   "${TOKEN}",
+	})
+	want := map[string]any{
+		"db_pass": "[REDACTED]",
+		"auth":    map[string]any{"user": "[REDACTED]", "retries": "[REDACTED]", "tls": true},
+		"note":    "plain",
+		"env":     "${TOKEN}",
+		"token":   "${TOKEN}",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RedactValue() = %v, want %v", got, want)
+	}
+}
+
+func TestTokenizeOutput_StructuredRoundTrip(t *testing.T) {
+	r := New()
+	v := NewVault()
+	const doc = "{\n  \"db_pass\": \"hun\\\"ter2\"\n}"
+	shown := r.TokenizeOutput("", doc, types.PIIMask, v)
+	if shown != "{\n  \"db_pass\": \"⟦SECRET_1⟧\"\n}" {
+		t.Fatalf("TokenizeOutput() = %q", shown)
+	}
+	if got, _ := v.Resolve(shown); got != doc {
+		t.Errorf("Resolve() = %q, want %q", got, doc)
+	}
+}
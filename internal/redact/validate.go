@@ -0,0 +1,50 @@
+package redact
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"hash/crc32"
+	"strings"
+)
+
+// validJWT reports whether token's header decodes to a JSON object naming a
+// signing algorithm, which rules out look-alike dotted base64 strings.
+func validJWT(token string) bool {
+	header, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(header, "="))
+	if err != nil {
+		return false
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return false
+	}
+	_, ok = fields["alg"]
+	return ok
+}
+
+// validNPMToken checks the CRC32 checksum carried in the last six characters
+// of an npm access token, the scheme GitHub introduced for its tokens.
+func validNPMToken(token string) bool {
+	body, ok := strings.CutPrefix(token, "npm_")
+	if !ok || len(body) != 36 {
+		return false
+	}
+	return base62CRC32(body[:30]) == body[30:]
+}
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// base62CRC32 encodes the CRC32 of s in base62, left-padded to six characters.
+func base62CRC32(s string) string {
+	n := crc32.ChecksumIEEE([]byte(s))
+	out := []byte("000000")
+	for i := len(out) - 1; i >= 0 && n > 0; i-- {
+		out[i] = base62Alphabet[n%62]
+		n /= 62
+	}
+	return string(out)
+}
@@ -0,0 +1,136 @@
+package redact
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// placeholderPattern matches the placeholders a Vault hands out.
+var placeholderPattern = regexp.MustCompile(`⟦SECRET_[0-9]+⟧`)
+
+// Vault maps secrets found in tool output to stable placeholders such as
+// ⟦SECRET_3⟧, so the model can refer to a value it never sees. The mapping is
+// kept in process memory only and is lost when the process exits.
+type Vault struct {
+	mu            sync.Mutex
+	byValue       map[string]string
+	byPlaceholder map[string]string
+}
+
+// NewVault returns an empty vault.
+func NewVault() *Vault {
+	return &Vault{
+		byValue:       map[string]string{},
+		byPlaceholder: map[string]string{},
+	}
+}
+
+// placeholder returns the placeholder for value, allocating one the first
+// time value is seen. The same value always gets the same placeholder.
+func (v *Vault) placeholder(value string) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if p, ok := v.byValue[value]; ok {
+		return p
+	}
+	p := fmt.Sprintf("⟦SECRET_%d⟧", len(v.byValue)+1)
+	v.byValue[value] = p
+	v.byPlaceholder[p] = value
+	return p
+}
+
+// Len returns the number of secrets held.
+func (v *Vault) Len() int {
+	if v == nil {
+		return 0
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.byValue)
+}
+
+// Resolve replaces the placeholders in text with the values they stand for
+// and reports how many it replaced. Placeholders the vault did not hand out
+// are left alone.
+func (v *Vault) Resolve(text string) (string, int) {
+	if v == nil {
+		return text, 0
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	n := 0
+	out := placeholderPattern.ReplaceAllStringFunc(text, func(p string) string {
+		value, ok := v.byPlaceholder[p]
+		if !ok {
+			return p
+		}
+		n++
+		return value
+	})
+	return out, n
+}
+
+// Hide replaces every value the vault holds with its placeholder, longest
+// first, whether or not a detector would recognise it where it now appears. A
+// secret written to a file through a placeholder and read back without its
+// surrounding key is caught here.
+func (v *Vault) Hide(text string) string {
+	if v == nil || text == "" {
+		return text
+	}
+	v.mu.Lock()
+	values := slices.Collect(maps.Keys(v.byValue))
+	v.mu.Unlock()
+	slices.SortFunc(values, func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(b), len(a)), strings.Compare(a, b))
+	})
+	for _, value := range values {
+		if value != "" && strings.Contains(text, value) {
+			text = strings.ReplaceAll(text, value, v.placeholder(value))
+		}
+	}
+	return text
+}
+
+// ResolveValue is Resolve applied to every string within a JSON-like value.
+// The input is not modified.
+func (v *Vault) ResolveValue(value any) (any, int) {
+	switch x := value.(type) {
+	case string:
+		return v.Resolve(x)
+	case []any:
+		out := make([]any, 0, len(x))
+		total := 0
+		for _, item := range x {
+			resolved, n := v.ResolveValue(item)
+			out = append(out, resolved)
+			total += n
+		}
+		return out, total
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		total := 0
+		for key, item := range x {
+			resolved, n := v.ResolveValue(item)
+			out[key] = resolved
+			total += n
+		}
+		return out, total
+	default:
+		return value, 0
+	}
+}
+
+// substitute returns the replacement function that stores secrets in v, or
+// nil, which masks them, when v is nil.
+func (v *Vault) substitute() func(string) string {
+	if v == nil {
+		return nil
+	}
+	return v.placeholder
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -67,6 +68,132 @@ func TestSecretWorkflowFixture_RedactsOutput(t *testing.T) {
 	}
 }
 
+func TestPromptInjectionFixture_FlagsOutput(t *testing.T) {
+	mediator := newFixtureMediator(t)
+	f, err := os.Open(filepath.Join("..", "..", "testdata", "adversarial", "prompt_injection.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var row struct {
+			Description string          `json:"description"`
+			Request     json.RawMessage `json:"request"`
+			Output      *string         `json:"output"`
+			Expect      struct {
+				Handling string `json:"handling"`
+			} `json:"expect"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatal(err)
+		}
+		if row.Output == nil {
+			continue
+		}
+		call := mustParseFixtureCall(t, row.Request)
+		result, err := mediator.Execute(context.Background(), call, func(context.Context, map[string]any) (string, error) {
+			return *row.Output, nil
+		})
+		if err != nil {
+			t.Fatalf("%s: Execute() error = %v", row.Description, err)
+		}
+		var want string
+		switch row.Expect.Handling {
+		case "annotate":
+			want = "[bridgekeeper: this tool output may contain a prompt injection"
+		case "quarantine":
+			want = "<untrusted-tool-output"
+		case "none":
+			if result != *row.Output {
+				t.Errorf("%s: result = %q, want the output unchanged", row.Description, result)
+			}
+			continue
+		default:
+			t.Fatalf("%s: unknown handling %q", row.Description, row.Expect.Handling)
+		}
+		if !strings.HasPrefix(result, want) {
+			t.Errorf("%s: result = %q, want prefix %q", row.Description, result, want)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestAuditLog_RedactsFixtureSecrets feeds every secret in the redaction
+// corpus through the mediator as arguments, as output and as an error, and
+// checks that none of them reaches an audit line or the model unredacted.
+func TestAuditLog_RedactsFixtureSecrets(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "..", "testdata", "redaction", "corpus.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var logs bytes.Buffer
+	mediator := newFixtureMediator(t)
+	mediator.Audit = audit.NewLogger(&logs, audit.Info)
+	mediator.Audit.SetRedactor(mediator.Redactor)
+	ctx := context.Background()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var row struct {
+			Description string `json:"description"`
+			Text        string `json:"text"`
+			Expect      struct {
+				Hidden []string `json:"hidden"`
+			} `json:"expect"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatal(err)
+		}
+		if len(row.Expect.Hidden) == 0 {
+			continue
+		}
+		logs.Reset()
+
+		var seen []string
+		output := func(context.Context, map[string]any) (string, error) { return row.Text, nil }
+		failing := func(context.Context, map[string]any) (string, error) {
+			return "", errors.New("exit status 1: " + row.Text)
+		}
+		for _, step := range []struct {
+			call    types.ToolCall
+			handler Handler
+		}{
+			// Denied by a command constraint whose reason quotes the command.
+			{types.ToolCall{ID: "c1", Tool: "shell", Action: "exec", Args: map[string]any{"command": "rm " + row.Text}}, output},
+			// Asked about, then refused by the approver.
+			{types.ToolCall{ID: "c2", Tool: "http", Action: "post", Args: map[string]any{"url": "https://example.com/", "body": row.Text}}, output},
+			// Allowed, with the secret in the output.
+			{types.ToolCall{ID: "c3", Tool: "fs", Action: "read_file", Args: map[string]any{"path": "notes.txt"}}, output},
+			// Allowed, with the secret in the error.
+			{types.ToolCall{ID: "c4", Tool: "fs", Action: "read_file", Args: map[string]any{"path": "notes.txt"}}, failing},
+		} {
+			result, err := mediator.Execute(ctx, step.call, step.handler)
+			seen = append(seen, result)
+			if err != nil {
+				seen = append(seen, err.Error())
+			}
+		}
+		seen = append(seen, logs.String())
+
+		for _, text := range seen {
+			for _, secret := range row.Expect.Hidden {
+				if strings.Contains(text, secret) {
+					t.Errorf("%s: %q leaked:\n%s", row.Description, secret, text)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func newFixtureMediator(t *testing.T) *Mediator {
 	t.Helper()
 
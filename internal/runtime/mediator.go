@@ -2,18 +2,28 @@ package runtime
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 
 	"bridgekeeper/internal/audit"
+	"bridgekeeper/internal/canary"
+	"bridgekeeper/internal/hitl"
+	"bridgekeeper/internal/inspect"
 	"bridgekeeper/internal/policy"
 	"bridgekeeper/internal/redact"
 	"bridgekeeper/internal/sandbox"
+	"bridgekeeper/internal/sanitize"
 	"bridgekeeper/internal/types"
 )
 
-// Approver decides whether an ask-policy tool call may proceed.
+// Approver decides whether an ask-policy tool call may proceed. An approver
+// may return edited arguments along with its approval.
 type Approver interface {
-	Approve(context.Context, types.ToolCall, types.PolicyDecision) (bool, error)
+	Approve(context.Context, types.ToolCall, types.PolicyDecision) (types.ApprovalResult, error)
 }
 
 // Handler executes a mediated tool call once policy and approval allow it.
@@ -23,9 +33,20 @@ type Handler func(context.Context, map[string]any) (string, error)
 type Mediator struct {
 	Policy   *policy.Engine
 	Approver Approver
+	Grants   *hitl.Grants // session grants consulted before asking the approver
+	Memory   *hitl.Memory // approvals remembered across sessions, consulted after grants
 	Audit    *audit.Logger
 	Sandbox  *sandbox.Validator
 	Redactor *redact.Redactor
+	Vault    *redact.Vault    // when set, secrets in output become placeholders the model can refer to
+	Canaries *canary.Registry // planted fake credentials; any call or output carrying one is denied
+}
+
+// placeholderTools are the tool actions whose arguments may carry vault
+// placeholders back to real values. They must not be able to send data off
+// the machine, so network tools are never listed here.
+var placeholderTools = map[string][]string{
+	"fs": {"write_file"},
 }
 
 // Execute evaluates policy, optionally requests approval, audits the outcome,
@@ -40,110 +61,333 @@ func (m *Mediator) Execute(ctx context.Context, call types.ToolCall, handler Han
 
 	call, err := m.validateCall(call)
 	if err != nil {
-		m.Audit.Log(audit.Warning, "tool_call_rejected_by_sandbox", map[string]any{
+		m.log(ctx, audit.Warning, "tool_call_rejected_by_sandbox", map[string]any{
 			"id":     call.ID,
 			"tool":   call.Tool,
 			"action": call.Action,
 			"error":  err.Error(),
 		})
-		return denied(types.PolicyDecision{
+		return m.denied(call.Tool, types.PolicyDecision{
 			Decision: types.Deny,
 			Rule:     "sandbox",
 			Reason:   err.Error(),
 		}), nil
 	}
 
-	m.Audit.Log(audit.Info, "tool_call_received", map[string]any{
+	received := map[string]any{
 		"id":     call.ID,
 		"tool":   call.Tool,
 		"action": call.Action,
-		"args":   m.redactValue(call.Args),
-	})
+		"args":   m.redactValue(call.Tool, call.Args),
+	}
+	if call.Sensitivity != "" {
+		received["sensitivity"] = call.Sensitivity
+	}
+	m.log(ctx, audit.Info, "tool_call_received", received)
+	if tripped := m.Canaries.FindValue(call.Args); len(tripped) > 0 {
+		return m.canaryTripped(ctx, call, "args", tripped), nil
+	}
 
+	// Reasons quote the arguments that matched a rule; mask any secret among
+	// them before the reason reaches the log, an approver or the model.
 	decision := m.Policy.Evaluate(ctx, call)
-	m.Audit.Log(audit.Info, "policy_decision", map[string]any{
+	decision.Reason = m.redactText(call.Tool, decision.Reason)
+	decisionFields := map[string]any{
 		"id":       call.ID,
 		"tool":     call.Tool,
 		"action":   call.Action,
 		"decision": decision.Decision,
 		"rule":     decision.Rule,
 		"reason":   decision.Reason,
-	})
+	}
+	if decision.Approval != nil {
+		decisionFields["approval"] = decision.Approval
+	}
+	m.log(ctx, audit.Info, "policy_decision", decisionFields)
 
+	// humanApproved is set only when a person approved this very call; a
+	// standing grant, a remembered approval or --no-hitl does not count.
+	humanApproved := false
 	switch decision.Decision {
 	case types.Deny:
-		return denied(decision), nil
+		return m.denied(call.Tool, decision), nil
 	case types.Ask:
+		// A standing session grant answers the question without asking again,
+		// but every such implicit approval is still audited.
+		if grant, ok := m.Grants.Match(call, decision); ok {
+			m.log(ctx, audit.Info, "approval_granted", map[string]any{
+				"id":     call.ID,
+				"tool":   call.Tool,
+				"action": call.Action,
+				"grant":  grant.ID,
+				"scope":  grant.Scope,
+			})
+			break
+		}
+		if entry, ok := m.Memory.Match(call, decision); ok {
+			m.log(ctx, audit.Info, "approval_granted", map[string]any{
+				"id":         call.ID,
+				"tool":       call.Tool,
+				"action":     call.Action,
+				"remembered": entry.ID,
+				"expires":    entry.Expires,
+			})
+			break
+		}
 		if m.Approver == nil {
-			m.Audit.Log(audit.Warning, "approval_missing", map[string]any{
+			m.log(ctx, audit.Warning, "approval_missing", map[string]any{
 				"id":     call.ID,
 				"tool":   call.Tool,
 				"action": call.Action,
 			})
-			return denied(types.PolicyDecision{
+			return m.denied(call.Tool, types.PolicyDecision{
 				Decision: types.Deny,
 				Rule:     decision.Rule,
 				Reason:   "approval required but no approver configured",
 			}), nil
 		}
-		approved, err := m.Approver.Approve(ctx, call, decision)
+		answer, err := m.approve(ctx, call, decision)
+		if errors.Is(err, errApprovalTimeout) {
+			settings := decision.Approval
+			m.log(ctx, audit.Warning, "approval_timeout", map[string]any{
+				"id":         call.ID,
+				"tool":       call.Tool,
+				"action":     call.Action,
+				"timeout":    settings.TimeoutSeconds,
+				"on_timeout": settings.OnTimeout,
+			})
+			return m.denied(call.Tool, types.PolicyDecision{
+				Decision: types.Deny,
+				Rule:     decision.Rule,
+				Reason:   fmt.Sprintf("approval timed out after %ds", settings.TimeoutSeconds),
+			}), nil
+		}
+		if errors.Is(err, hitl.ErrStopTurn) {
+			m.log(ctx, audit.Warning, "approval_denied", map[string]any{
+				"id":        call.ID,
+				"tool":      call.Tool,
+				"action":    call.Action,
+				"stop_turn": true,
+			})
+			return "", err
+		}
 		if err != nil {
-			m.Audit.Log(audit.Error, "approval_error", map[string]any{
+			m.log(ctx, audit.Error, "approval_error", map[string]any{
 				"id":    call.ID,
 				"error": err.Error(),
 			})
 			return "", fmt.Errorf("approval failed: %w", err)
 		}
-		if !approved {
-			m.Audit.Log(audit.Warning, "approval_denied", map[string]any{
+		for _, vote := range answer.Votes {
+			m.log(ctx, audit.Info, "approval_vote", map[string]any{
+				"id":       call.ID,
+				"tool":     call.Tool,
+				"action":   call.Action,
+				"member":   vote.Member,
+				"approver": vote.Approver,
+				"approved": vote.Approved,
+				"comment":  vote.Comment,
+				"error":    vote.Error,
+				"time":     vote.Time,
+			})
+		}
+		if !answer.Approved {
+			m.log(ctx, audit.Warning, "approval_denied", map[string]any{
+				"id":       call.ID,
+				"tool":     call.Tool,
+				"action":   call.Action,
+				"approver": answer.Approver,
+				"comment":  answer.Comment,
+			})
+			return m.denied(call.Tool, types.PolicyDecision{
+				Decision: types.Deny,
+				Rule:     decision.Rule,
+				Reason:   "request denied by approver",
+			}), nil
+		}
+		if settings := decision.Approval; settings != nil && settings.RequiredApprovers > 1 {
+			if required, got := settings.RequiredApprovers, distinctApprovers(answer); got < required {
+				m.log(ctx, audit.Warning, "approval_insufficient", map[string]any{
+					"id":        call.ID,
+					"tool":      call.Tool,
+					"action":    call.Action,
+					"approver":  answer.Approver,
+					"required":  required,
+					"approvers": got,
+				})
+				return m.denied(call.Tool, types.PolicyDecision{
+					Decision: types.Deny,
+					Rule:     decision.Rule,
+					Reason:   fmt.Sprintf("approval needs %d distinct approvers, got %d", required, got),
+				}), nil
+			}
+		}
+		granted := map[string]any{
+			"id":            call.ID,
+			"tool":          call.Tool,
+			"action":        call.Action,
+			"approver":      answer.Approver,
+			"comment":       answer.Comment,
+			"justification": answer.Justification,
+		}
+		if answer.Args != nil {
+			edited, rejection, ok := m.checkEdited(ctx, call, decision, answer.Args)
+			granted["edited"] = true
+			granted["original_args"] = m.redactValue(call.Tool, call.Args)
+			granted["edited_args"] = m.redactValue(call.Tool, edited.Args)
+			if !ok {
+				granted["error"] = rejection.Reason
+				m.log(ctx, audit.Warning, "approval_edit_rejected", granted)
+				return m.denied(call.Tool, rejection), nil
+			}
+			call = edited
+		}
+		m.log(ctx, audit.Info, "approval_granted", granted)
+		_, auto := m.Approver.(*hitl.AutoApprover)
+		humanApproved = !auto
+		if answer.Remember != nil {
+			if _, err := m.Memory.Remember(call, decision, answer.Approver, *answer.Remember); err != nil {
+				m.log(ctx, audit.Warning, "approval_remember_failed", map[string]any{
+					"id":    call.ID,
+					"error": err.Error(),
+				})
+			}
+		}
+	}
+
+	if humanApproved {
+		call, err = m.resolvePlaceholders(ctx, call)
+		if err != nil {
+			m.log(ctx, audit.Warning, "tool_call_rejected_by_sandbox", map[string]any{
 				"id":     call.ID,
 				"tool":   call.Tool,
 				"action": call.Action,
+				"error":  err.Error(),
 			})
-			return denied(types.PolicyDecision{
+			return m.denied(call.Tool, types.PolicyDecision{
 				Decision: types.Deny,
-				Rule:     decision.Rule,
-				Reason:   "request denied by approver",
+				Rule:     "sandbox",
+				Reason:   err.Error(),
 			}), nil
 		}
-		m.Audit.Log(audit.Info, "approval_granted", map[string]any{
-			"id":     call.ID,
-			"tool":   call.Tool,
-			"action": call.Action,
-		})
+	}
+
+	// Approver edits and resolved placeholders change the arguments after the
+	// first look, so look again just before running the call.
+	if tripped := m.Canaries.FindValue(call.Args); len(tripped) > 0 {
+		return m.canaryTripped(ctx, call, "args", tripped), nil
 	}
 
 	result, err := handler(ctx, call.Args)
 	if err != nil {
-		m.Audit.Log(audit.Error, "tool_execution_failed", map[string]any{
+		m.log(ctx, audit.Error, "tool_execution_failed", map[string]any{
 			"id":     call.ID,
 			"tool":   call.Tool,
 			"action": call.Action,
 			"error":  err.Error(),
 		})
-		return "", err
+		return "", m.cleanError(ctx, call, err)
+	}
+	// Escape sequences act on whatever terminal prints them; remove them
+	// before the result reaches the console or the model.
+	result, removed := sanitize.Text(result)
+	if len(removed) > 0 {
+		m.log(ctx, audit.Warning, "tool_result_sanitized", map[string]any{
+			"id":      call.ID,
+			"tool":    call.Tool,
+			"action":  call.Action,
+			"removed": removed,
+		})
+	}
+	if tripped := m.Canaries.Find(result); len(tripped) > 0 {
+		return m.canaryTripped(ctx, call, "output", tripped), nil
 	}
 	if err := m.validateResult(result); err != nil {
-		m.Audit.Log(audit.Warning, "tool_result_rejected_by_sandbox", map[string]any{
+		m.log(ctx, audit.Warning, "tool_result_rejected_by_sandbox", map[string]any{
 			"id":     call.ID,
 			"tool":   call.Tool,
 			"action": call.Action,
 			"error":  err.Error(),
 		})
-		return denied(types.PolicyDecision{
+		return m.denied(call.Tool, types.PolicyDecision{
 			Decision: types.Deny,
 			Rule:     "sandbox",
 			Reason:   err.Error(),
 		}), nil
 	}
 
-	classification := m.detect(result)
+	// Output is data, but the model reads it as text all the same; look for
+	// content written to be taken as instructions.
+	injection := injectionSettings(decision)
+	report := inspect.Inspect(result)
+	suspected := len(report.Findings) > 0 && report.Score >= injection.Threshold
+	if suspected {
+		excerpts := make([]any, 0, len(report.Findings))
+		for _, f := range report.Findings {
+			excerpts = append(excerpts, f.Kind+": "+f.Detail)
+		}
+		m.log(ctx, audit.Warning, "tool_result_injection_suspected", map[string]any{
+			"id":       call.ID,
+			"tool":     call.Tool,
+			"action":   call.Action,
+			"score":    report.Score,
+			"markers":  report.Kinds(),
+			"excerpts": m.redactValue(call.Tool, excerpts),
+			"handling": injection.Action,
+		})
+		switch injection.Action {
+		case types.InjectionDeny:
+			return m.denied(call.Tool, types.PolicyDecision{
+				Decision: types.Deny,
+				Rule:     decision.Rule,
+				Reason:   fmt.Sprintf("tool output looks like a prompt injection (%s)", strings.Join(report.Kinds(), ", ")),
+			}), nil
+		case types.InjectionStrip:
+			result = inspect.Strip(result, report)
+		}
+	}
+
+	classification := m.detect(call.Tool, result)
+	if call.Sensitivity == types.SensitivitySecret {
+		// The sandbox knows the file holds secrets, whatever they look like.
+		classification.Sensitive = true
+		classification.Confidence = 1
+		classification.Reasons = append(classification.Reasons, "sensitive_path")
+	}
+	if pii := classification.PII(); len(pii) > 0 && decision.PII == types.PIIBlock {
+		m.log(ctx, audit.Warning, "tool_result_blocked", map[string]any{
+			"id":     call.ID,
+			"tool":   call.Tool,
+			"action": call.Action,
+			"taint":  classification,
+		})
+		return m.denied(call.Tool, types.PolicyDecision{
+			Decision: types.Deny,
+			Rule:     decision.Rule,
+			Reason:   fmt.Sprintf("tool output contains personal data (%s)", strings.Join(pii, ", ")),
+		}), nil
+	}
 	safeResult := result
-	if classification.Sensitive {
-		safeResult = m.redactText(result)
+	switch {
+	case call.Sensitivity == types.SensitivitySecret:
+		safeResult = redact.Withhold(result)
+	case classification.Sensitive:
+		safeResult = m.redactOutput(call.Tool, result, decision.PII)
+	default:
+		// A vaulted value can come back without whatever marked it as a
+		// secret, such as its key, so the vault's own values are always hidden.
+		safeResult = m.Vault.Hide(result)
+	}
+	if suspected {
+		switch injection.Action {
+		case types.InjectionQuarantine:
+			safeResult = inspect.Quarantine(call.Tool+"/"+call.Action, safeResult, report)
+		case types.InjectionAnnotate:
+			safeResult = inspect.Annotate(safeResult, report)
+		}
 	}
 
-	m.Audit.Log(audit.Info, "tool_execution_succeeded", map[string]any{
+	m.log(ctx, audit.Info, "tool_execution_succeeded", map[string]any{
 		"id":     call.ID,
 		"tool":   call.Tool,
 		"action": call.Action,
@@ -152,8 +396,202 @@ func (m *Mediator) Execute(ctx context.Context, call types.ToolCall, handler Han
 	return safeResult, nil
 }
 
-func denied(decision types.PolicyDecision) string {
-	return fmt.Sprintf("Error: execution denied. Reason: %s", decision.Reason)
+// checkEdited re-runs sandbox validation and policy evaluation on a call whose
+// arguments were edited by the approver. The approval covers the edited call
+// only if policy still allows it, or still asks under the same rule that the
+// approver just answered; anything else is rejected with a deny decision.
+func (m *Mediator) checkEdited(ctx context.Context, call types.ToolCall, decision types.PolicyDecision, args map[string]any) (types.ToolCall, types.PolicyDecision, bool) {
+	edited := call
+	edited.Args = args
+
+	edited, err := m.validateCall(edited)
+	if err != nil {
+		return edited, types.PolicyDecision{
+			Decision: types.Deny,
+			Rule:     "sandbox",
+			Reason:   "edited call rejected: " + err.Error(),
+		}, false
+	}
+
+	recheck := m.Policy.Evaluate(ctx, edited)
+	recheck.Reason = m.redactText(edited.Tool, recheck.Reason)
+	switch {
+	case recheck.Decision == types.Deny:
+		return edited, types.PolicyDecision{
+			Decision: types.Deny,
+			Rule:     recheck.Rule,
+			Reason:   "edited call denied: " + recheck.Reason,
+		}, false
+	case recheck.Decision == types.Ask && recheck.Rule != decision.Rule:
+		return edited, types.PolicyDecision{
+			Decision: types.Deny,
+			Rule:     recheck.Rule,
+			Reason:   fmt.Sprintf("edited call needs approval under rule %q; resubmit it instead", recheck.Rule),
+		}, false
+	}
+	return edited, recheck, true
+}
+
+// errApprovalTimeout reports that an approver did not answer within the
+// capability's approval timeout.
+var errApprovalTimeout = errors.New("approval timed out")
+
+// approve asks the configured approver, enforcing the decision's approval
+// timeout. The approver runs on its own goroutine so that one which ignores
+// ctx still cannot hold the call past its deadline; its late answer is
+// discarded.
+func (m *Mediator) approve(ctx context.Context, call types.ToolCall, decision types.PolicyDecision) (types.ApprovalResult, error) {
+	settings := decision.Approval
+	if settings == nil || settings.TimeoutSeconds <= 0 {
+		return m.Approver.Approve(ctx, call, decision)
+	}
+
+	approveCtx, cancel := context.WithTimeout(ctx, time.Duration(settings.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	type answer struct {
+		result types.ApprovalResult
+		err    error
+	}
+	done := make(chan answer, 1)
+	go func() {
+		result, err := m.Approver.Approve(approveCtx, call, decision)
+		done <- answer{result: result, err: err}
+	}()
+
+	select {
+	case a := <-done:
+		if a.err != nil && ctx.Err() == nil && errors.Is(approveCtx.Err(), context.DeadlineExceeded) {
+			return types.ApprovalResult{}, errApprovalTimeout
+		}
+		return a.result, a.err
+	case <-approveCtx.Done():
+		if ctx.Err() != nil {
+			return types.ApprovalResult{}, ctx.Err()
+		}
+		return types.ApprovalResult{}, errApprovalTimeout
+	}
+}
+
+// distinctApprovers counts the independent approvals behind answer. Each
+// approver channel counts at most once, however many names were typed into
+// it, and two channels answered by the same person count once. An answer
+// without votes came from a single channel.
+func distinctApprovers(answer types.ApprovalResult) int {
+	if !answer.Approved {
+		return 0
+	}
+	if len(answer.Votes) == 0 {
+		return 1
+	}
+	channels := map[string]bool{}
+	identities := map[string]bool{}
+	count := 0
+	for _, vote := range answer.Votes {
+		if !vote.Approved || vote.Error != "" {
+			continue
+		}
+		channel, _, _ := strings.Cut(vote.Member, "/")
+		identity := strings.ToLower(strings.TrimSpace(vote.Approver))
+		if identity == "" || identity == "auto" {
+			identity = channel
+		}
+		if channels[channel] || identities[identity] {
+			continue
+		}
+		channels[channel], identities[identity] = true, true
+		count++
+	}
+	return count
+}
+
+// log records an audit event, tagging it with the acting principal carried on
+// ctx so every mediated event can be attributed.
+func (m *Mediator) log(ctx context.Context, severity audit.Severity, message string, fields map[string]any) {
+	if m == nil || m.Audit == nil {
+		return
+	}
+	if principal := policy.EvalContextFrom(ctx).Principal; !principal.IsZero() {
+		if fields == nil {
+			fields = map[string]any{}
+		}
+		fields["principal"] = principal
+	}
+	m.Audit.Log(severity, message, fields)
+}
+
+// canaryTripped raises a critical audit event for canaries found in a call's
+// arguments or output and returns the denial shown to the model, which does
+// not say that the value was a canary.
+func (m *Mediator) canaryTripped(ctx context.Context, call types.ToolCall, where string, tokens []canary.Token) string {
+	canaries := make([]map[string]any, 0, len(tokens))
+	for _, token := range tokens {
+		canaries = append(canaries, map[string]any{
+			"id":       token.ID,
+			"kind":     token.Kind,
+			"location": token.Location,
+		})
+	}
+	m.log(ctx, audit.Critical, "canary_tripped", map[string]any{
+		"id":       call.ID,
+		"tool":     call.Tool,
+		"action":   call.Action,
+		"where":    where,
+		"canaries": canaries,
+	})
+	reason := "the call carries a credential that must not leave the workspace"
+	if where == "output" {
+		reason = "the tool output contains a credential that must not be shared"
+	}
+	return m.denied(call.Tool, types.PolicyDecision{Decision: types.Deny, Rule: "canary", Reason: reason})
+}
+
+// injectionSettings returns the decision's injection handling, defaulting to
+// annotating output at the inspector's default threshold.
+func injectionSettings(decision types.PolicyDecision) types.InjectionSettings {
+	settings := types.InjectionSettings{Action: types.InjectionAnnotate}
+	if decision.Injection != nil {
+		settings = *decision.Injection
+	}
+	if settings.Threshold <= 0 {
+		settings.Threshold = inspect.DefaultThreshold
+	}
+	return settings
+}
+
+// denied is the message the model sees in place of a tool result. The reason
+// may quote arguments or output, so it is redacted once more.
+func (m *Mediator) denied(tool string, decision types.PolicyDecision) string {
+	return fmt.Sprintf("Error: execution denied. Reason: %s", m.redactText(tool, decision.Reason))
+}
+
+// redactedError carries an error whose message has been redacted, keeping the
+// original for errors.Is and errors.As.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// cleanError masks secrets in a handler error and removes the terminal
+// control sequences it may quote from subprocess output, since it is shown to
+// the user and may be passed on to the model.
+func (m *Mediator) cleanError(ctx context.Context, call types.ToolCall, err error) error {
+	msg, removed := sanitize.Text(m.redactText(call.Tool, err.Error()))
+	if len(removed) > 0 {
+		m.log(ctx, audit.Warning, "tool_error_sanitized", map[string]any{
+			"id":      call.ID,
+			"tool":    call.Tool,
+			"action":  call.Action,
+			"removed": removed,
+		})
+	}
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
 }
 
 func (m *Mediator) validateCall(call types.ToolCall) (types.ToolCall, error) {
@@ -170,23 +608,61 @@ func (m *Mediator) validateResult(result string) error {
 	return m.Sandbox.ValidateToolResult(result)
 }
 
-func (m *Mediator) redactText(text string) string {
+func (m *Mediator) redactOutput(tool, text string, mode types.PIIMode) string {
+	if m == nil || m.Redactor == nil {
+		return text
+	}
+	return m.Redactor.TokenizeOutput(tool, text, mode, m.Vault)
+}
+
+// resolvePlaceholders puts the real values back in place of vault
+// placeholders in the content argument of a call a person approved, for the
+// tools listed in placeholderTools only. Policy and the approver judged the call with the
+// placeholders in place, so every other argument, the path included, stays as
+// they saw it; a placeholder there reaches the tool unresolved. The resolved
+// call is validated again, since its content grew after the sandbox last saw
+// it.
+func (m *Mediator) resolvePlaceholders(ctx context.Context, call types.ToolCall) (types.ToolCall, error) {
+	if m.Vault == nil || !slices.Contains(placeholderTools[call.Tool], call.Action) {
+		return call, nil
+	}
+	content, ok := call.Args["content"].(string)
+	if !ok {
+		return call, nil
+	}
+	resolved, n := m.Vault.Resolve(content)
+	if n == 0 {
+		return call, nil
+	}
+	m.log(ctx, audit.Info, "vault_placeholders_resolved", map[string]any{
+		"id":     call.ID,
+		"tool":   call.Tool,
+		"action": call.Action,
+		"count":  n,
+	})
+	args := maps.Clone(call.Args)
+	args["content"] = resolved
+	call.Args = args
+	return m.validateCall(call)
+}
+
+func (m *Mediator) redactText(tool, text string) string {
 	if m == nil || m.Redactor == nil {
 		return text
 	}
-	return m.Redactor.RedactText(text)
+	return m.Redactor.RedactTextFor(tool, text)
 }
 
-func (m *Mediator) redactValue(value any) any {
+func (m *Mediator) redactValue(tool string, value any) any {
 	if m == nil || m.Redactor == nil {
 		return value
 	}
-	return m.Redactor.RedactValue(value)
+	return m.Redactor.RedactValueFor(tool, value)
 }
 
-func (m *Mediator) detect(text string) redact.Classification {
+func (m *Mediator) detect(tool, text string) redact.Classification {
 	if m == nil || m.Redactor == nil {
 		return redact.Classification{}
 	}
-	return m.Redactor.Detect(text)
+	return m.Redactor.DetectFor(tool, text)
 }
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"bridgekeeper/internal/policy"
 	"bridgekeeper/internal/types"
 )
 
@@ -309,6 +310,7 @@ func QueryWithTools(ctx context.Context, userPrompt string, tools []ToolDef, med
 		return "", fmt.Errorf("tool %q unknown", tcall.Function.Name)
 	}
 
+	ctx = policy.WithModel(ctx, model)
 	toolCall := types.ToolCall{
 		ID:     "ollama-internal",
 		Tool:   def.Tool,
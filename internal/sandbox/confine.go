@@ -0,0 +1,294 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Subprocess confinement.
+//
+// Argument checks decide which git, go and cargo commands may run, but the
+// commands themselves would otherwise keep the host's full filesystem rights.
+// Where the kernel supports Landlock they are confined to reading the
+// workspace and the toolchain and writing only the workspace and a scratch
+// directory.
+//
+// Go cannot run code in a child between fork and exec, so a confined command
+// is started through this binary: ConfineCommand rewrites an exec.Cmd to run
+// the current executable with the real command as its arguments and the
+// confinement in its environment, and ExecConfined, called first thing in
+// main, applies the restriction and execs the real command in its place.
+// Landlock rules survive exec, so the command and everything it starts keep
+// them.
+
+// confineEnv carries a Confinement from ConfineCommand to ExecConfined.
+const confineEnv = "BRIDGEKEEPER_CONFINE"
+
+// Confinement is the filesystem access left to a confined subprocess.
+// Paths that do not exist are skipped.
+type Confinement struct {
+	Read  []string `json:"read"`  // read and execute beneath these paths
+	Write []string `json:"write"` // full access beneath these paths
+}
+
+// systemDirs hold the shared libraries, configuration and devices that
+// toolchains read on any Linux system. Of /proc only the confined command's
+// own entry is granted, since /proc/self resolves when the rules are added:
+// the entries of other processes, the parent included, expose their
+// environments and so the host's credentials.
+var systemDirs = []string{
+	"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32",
+	"/etc", "/opt", "/nix/store", "/proc/self", "/dev/urandom", "/dev/zero",
+}
+
+// ConfinementABI returns the Landlock ABI version enforced on subprocesses,
+// or 0 when they run with the host's filesystem rights because confinement
+// is off or the kernel does not support it.
+func (v *Validator) ConfinementABI() int {
+	if v == nil || !v.ConfineSubprocesses {
+		return 0
+	}
+	return LandlockABI()
+}
+
+// Confinement returns the access granted to subprocesses: the workspace, the
+// scratch directory, /dev/null and the toolchain caches may be written,
+// toolchain directories only read.
+func (v *Validator) Confinement() (Confinement, error) {
+	scratch, err := v.ScratchDir()
+	if err != nil {
+		return Confinement{}, err
+	}
+	return Confinement{
+		Read:  ToolchainDirs(),
+		Write: append([]string{v.WorkspaceRoot, scratch, os.DevNull}, ToolchainCaches()...),
+	}, nil
+}
+
+// ScratchDir returns a private temporary directory for subprocesses, created
+// on first use and shared for the life of the validator.
+func (v *Validator) ScratchDir() (string, error) {
+	v.scratchMu.Lock()
+	defer v.scratchMu.Unlock()
+	if v.scratch == "" {
+		dir, err := os.MkdirTemp("", "bridgekeeper-scratch-")
+		if err != nil {
+			return "", fmt.Errorf("create scratch directory: %w", err)
+		}
+		v.scratch = dir
+	}
+	return v.scratch, nil
+}
+
+// RemoveScratch deletes the scratch directory and everything subprocesses
+// left in it. A later ScratchDir call creates a fresh one.
+func (v *Validator) RemoveScratch() error {
+	v.scratchMu.Lock()
+	defer v.scratchMu.Unlock()
+	if v.scratch == "" {
+		return nil
+	}
+	if err := os.RemoveAll(v.scratch); err != nil {
+		return fmt.Errorf("remove scratch directory: %w", err)
+	}
+	v.scratch = ""
+	return nil
+}
+
+// ToolchainDirs returns the directories a confined subprocess may read
+// besides the workspace: system directories, the directories on PATH or the
+// version managers whose shims they hold, the installations of git, go and
+// cargo, and their per-user caches and configuration. The home directory
+// itself is never included, so keys and credentials kept there stay out of
+// reach.
+func ToolchainDirs() []string {
+	home, _ := os.UserHomeDir()
+	dirs := append([]string(nil), systemDirs...)
+	seen := map[string]bool{}
+	add := func(dir string) {
+		if dir == "" || !filepath.IsAbs(dir) {
+			return
+		}
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		dir = filepath.Clean(dir)
+		if seen[dir] || coversHome(dir, home) {
+			return
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		// Version managers such as pyenv, goenv and asdf put shims on PATH
+		// that exec toolchains kept elsewhere under the manager's root.
+		if filepath.Base(dir) == "shims" {
+			dir = filepath.Dir(dir)
+		}
+		add(dir)
+	}
+	for _, name := range []string{"git", "go", "cargo", "rustc"} {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			path = resolved
+		}
+		// A binary in .../bin belongs to an installation rooted one level
+		// up, such as GOROOT.
+		dir := filepath.Dir(path)
+		if filepath.Base(dir) == "bin" {
+			dir = filepath.Dir(dir)
+		}
+		add(dir)
+	}
+	if home == "" {
+		return dirs
+	}
+	add(toolchainDir("CARGO_HOME"))
+	add(toolchainDir("RUSTUP_HOME"))
+	add(filepath.Join(home, ".gitconfig"))
+	add(filepath.Join(envOr("XDG_CONFIG_HOME", filepath.Join(home, ".config")), "git"))
+	return dirs
+}
+
+// ToolchainCaches returns the caches a confined subprocess may write: the Go
+// build and module caches, and cargo's registry and git checkouts. Landlock
+// can only grant paths that exist, so the caches of an installed toolchain are
+// created when missing; those of a missing one are left out.
+func ToolchainCaches() []string {
+	home, _ := os.UserHomeDir()
+	var dirs []string
+	add := func(tool, dir string) {
+		if !filepath.IsAbs(dir) || coversHome(dir, home) {
+			return
+		}
+		if _, err := exec.LookPath(tool); err != nil {
+			return
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return
+		}
+		dirs = append(dirs, dir)
+	}
+	add("go", toolchainDir("GOCACHE"))
+	add("go", toolchainDir("GOMODCACHE"))
+	add("cargo", filepath.Join(toolchainDir("CARGO_HOME"), "registry"))
+	add("cargo", filepath.Join(toolchainDir("CARGO_HOME"), "git"))
+	return dirs
+}
+
+// ToolchainEnv returns settings that point a subprocess's go and cargo at the
+// directories ToolchainDirs and ToolchainCaches grant. The subprocess
+// environment is filtered, so without them the toolchain could fall back to
+// defaults the confinement does not cover.
+func ToolchainEnv() []string {
+	var env []string
+	for _, key := range []string{"GOCACHE", "GOMODCACHE", "CARGO_HOME", "RUSTUP_HOME"} {
+		if dir := toolchainDir(key); filepath.IsAbs(dir) {
+			env = append(env, key+"="+dir)
+		}
+	}
+	return env
+}
+
+// toolchainDir returns where the toolchain variable key points, following the
+// toolchain's own default when it is unset. The result is relative when there
+// is no home directory to default to, and callers skip it.
+func toolchainDir(key string) string {
+	if dir := os.Getenv(key); dir != "" {
+		return dir
+	}
+	home, _ := os.UserHomeDir()
+	switch key {
+	case "GOCACHE":
+		if cache, err := os.UserCacheDir(); err == nil {
+			return filepath.Join(cache, "go-build")
+		}
+	case "GOMODCACHE":
+		return filepath.Join(envOr("GOPATH", filepath.Join(home, "go")), "pkg", "mod")
+	case "CARGO_HOME":
+		return filepath.Join(home, ".cargo")
+	case "RUSTUP_HOME":
+		return filepath.Join(home, ".rustup")
+	}
+	return ""
+}
+
+// coversHome reports whether granting dir would grant the whole of home.
+func coversHome(dir, home string) bool {
+	if dir == string(filepath.Separator) {
+		return true
+	}
+	if home == "" {
+		return false
+	}
+	rel, err := filepath.Rel(dir, home)
+	return err == nil && (rel == "." || !strings.HasPrefix(rel, ".."))
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return filepath.SplitList(value)[0]
+	}
+	return fallback
+}
+
+// ConfineCommand rewrites cmd, which has not been started, to run under c.
+// The current executable must call ExecConfined before doing anything else.
+func ConfineCommand(cmd *exec.Cmd, c Confinement) error {
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate executable for confinement: %w", err)
+	}
+	spec, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Args = append([]string{self, cmd.Path}, cmd.Args...)
+	cmd.Path = self
+	cmd.Env = append(env, confineEnv+"="+string(spec))
+	return nil
+}
+
+// ExecConfined returns at once unless this process was started by
+// ConfineCommand. In that case it confines the process and replaces it with
+// the real command; if either step fails it reports why and exits with
+// status 126 rather than run the command unconfined.
+func ExecConfined() {
+	spec, ok := os.LookupEnv(confineEnv)
+	if !ok {
+		return
+	}
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "bridgekeeper: confined exec: %v\n", err)
+		os.Exit(126)
+	}
+	if len(os.Args) < 3 {
+		fail(fmt.Errorf("no command given"))
+	}
+	var c Confinement
+	if err := json.Unmarshal([]byte(spec), &c); err != nil {
+		fail(fmt.Errorf("parse %s: %w", confineEnv, err))
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, confineEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	fail(execConfined(os.Args[1], os.Args[2:], env, c))
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package sandbox
+
+import "errors"
+
+// LandlockABI returns 0: Landlock is only available on Linux.
+func LandlockABI() int {
+	return 0
+}
+
+func execConfined(path string, argv, env []string, c Confinement) error {
+	return errors.New("landlock is only available on linux")
+}
@@ -5,7 +5,9 @@ import (
 	"net/url"
 	"path/filepath"
 	"strings"
+	"sync"
 
+	"bridgekeeper/internal/glob"
 	"bridgekeeper/internal/types"
 )
 
@@ -18,6 +20,14 @@ type Validator struct {
 	MaxCommandArgs         int
 	SubprocessTimeoutSecs  int
 	SubprocessEnvAllowlist []string
+	// ConfineSubprocesses confines subprocesses with Landlock where the
+	// kernel supports it. It is off by default: a binary that turns it on
+	// must call ExecConfined before anything else.
+	ConfineSubprocesses bool
+
+	sensitivity map[types.Sensitivity]glob.List // compiled by SetSensitivity
+	scratchMu   sync.Mutex
+	scratch     string // created by ScratchDir
 }
 
 // NewValidator constructs a validator rooted at workspaceRoot.
@@ -31,7 +41,7 @@ func NewValidator(workspaceRoot string) (*Validator, error) {
 		return nil, fmt.Errorf("resolve workspace root: %w", err)
 	}
 
-	return &Validator{
+	v := &Validator{
 		WorkspaceRoot:          filepath.Clean(root),
 		MaxOutputBytes:         64 * 1024,
 		MaxReadBytes:           64 * 1024,
@@ -39,16 +49,22 @@ func NewValidator(workspaceRoot string) (*Validator, error) {
 		MaxCommandArgs:         32,
 		SubprocessTimeoutSecs:  5,
 		SubprocessEnvAllowlist: []string{"PATH", "HOME", "LANG", "LC_ALL", "TERM", "SSH_AUTH_SOCK", "SSH_AGENT_PID", "SSH_ASKPASS"},
-	}, nil
+	}
+	if err := v.SetSensitivity(DefaultSensitivity); err != nil {
+		return nil, err
+	}
+	return v, nil
 }
 
-// ValidateToolCall normalizes and validates a tool call before execution.
+// ValidateToolCall normalizes and validates a tool call before execution,
+// labelling it with the sensitivity of the path it touches.
 func (v *Validator) ValidateToolCall(call types.ToolCall) (types.ToolCall, error) {
 	if v == nil {
 		return call, nil
 	}
 
 	args := cloneArgs(call.Args)
+	call.Sensitivity = ""
 	switch call.Tool {
 	case "fs":
 		path, err := v.pathArg(args, "path")
@@ -56,6 +72,7 @@ func (v *Validator) ValidateToolCall(call types.ToolCall) (types.ToolCall, error
 			return call, err
 		}
 		args["path"] = path
+		call.Sensitivity = v.Sensitivity(path)
 		if call.Action == "write_file" {
 			if err := v.validateContentSize(args); err != nil {
 				return call, err
@@ -65,6 +82,7 @@ func (v *Validator) ValidateToolCall(call types.ToolCall) (types.ToolCall, error
 		path, err := v.pathArg(args, "path")
 		if err == nil {
 			args["path"] = path
+			call.Sensitivity = v.Sensitivity(path)
 		}
 		if err := v.validateGitArgs(args); err != nil {
 			return call, err
@@ -1,7 +1,10 @@
 package sandbox
 
 import (
+	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"bridgekeeper/internal/types"
@@ -97,3 +100,144 @@ func TestValidateToolCall_RejectsInvalidHTTPURL(t *testing.T) {
 		t.Fatal("expected invalid URL scheme error")
 	}
 }
+
+func TestValidateToolCall_LabelsSensitivePaths(t *testing.T) {
+	validator, err := NewValidator("/tmp/workspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want types.Sensitivity
+	}{
+		{".env", types.SensitivitySecret},
+		{"services/api/.env.production", types.SensitivitySecret},
+		{".env.example", ""},
+		{"certs/server.pem", types.SensitivitySecret},
+		{"home/.ssh/id_ed25519", types.SensitivitySecret},
+		{"home/.ssh/id_ed25519.pub", ""},
+		{".aws/credentials", types.SensitivitySecret},
+		{"nested/.git/config", types.SensitivitySecret},
+		{"README.md", ""},
+		{"docs/credentials.md", ""},
+	}
+	for _, tt := range tests {
+		call, err := validator.ValidateToolCall(types.ToolCall{
+			Tool:        "fs",
+			Action:      "read_file",
+			Args:        map[string]any{"path": tt.path},
+			Sensitivity: types.SensitivitySecret, // replaced, never trusted
+		})
+		if err != nil {
+			t.Fatalf("%s: ValidateToolCall() error = %v", tt.path, err)
+		}
+		if call.Sensitivity != tt.want {
+			t.Errorf("%s: sensitivity = %q, want %q", tt.path, call.Sensitivity, tt.want)
+		}
+	}
+}
+
+func TestSetSensitivity(t *testing.T) {
+	validator, err := NewValidator("/tmp/workspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validator.SetSensitivity(SensitivityMap{"classified": {"*"}}); err == nil {
+		t.Error("expected an error for an unknown label")
+	}
+
+	err = validator.SetSensitivity(SensitivityMap{
+		types.SensitivitySecret:   {"vault/**"},
+		types.SensitivityInternal: {"vault/**", "roadmap/*.md"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]types.Sensitivity{
+		"/tmp/workspace/vault/db.txt":     types.SensitivitySecret,
+		"/tmp/workspace/roadmap/2027.md":  types.SensitivityInternal,
+		"/tmp/workspace/.env":             "", // the map replaces the defaults
+		"/tmp/workspace/vault-readme.txt": "",
+	} {
+		if got := validator.Sensitivity(path); got != want {
+			t.Errorf("Sensitivity(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestToolchainDirs_KeepsHomeOutOfReach(t *testing.T) {
+	home := t.TempDir()
+	shims := filepath.Join(home, ".pyenv", "shims")
+	if err := os.MkdirAll(shims, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("PATH", strings.Join([]string{"/", home, shims, "relative/bin"}, string(filepath.ListSeparator)))
+
+	dirs := ToolchainDirs()
+	for _, dir := range dirs {
+		if dir == "/" || dir == home || dir == "relative/bin" {
+			t.Errorf("ToolchainDirs() includes %q", dir)
+		}
+	}
+	if !slices.Contains(dirs, filepath.Join(home, ".pyenv")) {
+		t.Errorf("ToolchainDirs() = %v, want the pyenv root for its shims", dirs)
+	}
+}
+
+func TestConfinement_WritesWorkspaceScratchAndCaches(t *testing.T) {
+	validator, err := NewValidator(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := validator.Confinement()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scratch, _ := validator.ScratchDir()
+	t.Cleanup(func() { os.RemoveAll(scratch) })
+	want := append([]string{validator.WorkspaceRoot, scratch, os.DevNull}, ToolchainCaches()...)
+	if !slices.Equal(c.Write, want) {
+		t.Errorf("Write = %v, want %v", c.Write, want)
+	}
+	if slices.Contains(c.Read, validator.WorkspaceRoot) {
+		t.Errorf("Read = %v, want the workspace only in Write", c.Read)
+	}
+	if slices.Contains(c.Read, "/proc") {
+		t.Errorf("Read = %v, want only /proc/self of /proc", c.Read)
+	}
+}
+
+func TestRemoveScratch(t *testing.T) {
+	validator, err := NewValidator(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	scratch, err := validator.ScratchDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(scratch) })
+	if err := os.WriteFile(filepath.Join(scratch, "left-behind"), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := validator.RemoveScratch(); err != nil {
+		t.Fatalf("RemoveScratch() error = %v", err)
+	}
+	if _, err := os.Stat(scratch); !os.IsNotExist(err) {
+		t.Errorf("scratch directory still exists: %v", err)
+	}
+	if err := validator.RemoveScratch(); err != nil {
+		t.Errorf("second RemoveScratch() error = %v", err)
+	}
+	again, err := validator.ScratchDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(again) })
+	if _, err := os.Stat(again); err != nil {
+		t.Errorf("ScratchDir() after removal: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+
+	"bridgekeeper/internal/glob"
+	"bridgekeeper/internal/types"
+)
+
+// SensitivityMap maps a sensitivity label to the path patterns that carry it.
+// Patterns use the policy glob syntax and are matched against paths relative
+// to the workspace root, so "*.pem" covers a key file at any depth.
+type SensitivityMap map[types.Sensitivity][]string
+
+// DefaultSensitivity labels the usual homes of credentials as secret. Reads of
+// these files are caught by name, whether or not their content matches a
+// redaction rule.
+var DefaultSensitivity = SensitivityMap{
+	types.SensitivitySecret: {
+		".env", ".env.*", "!.env.example", "!.env.sample", "!.env.template",
+		"*.pem", "*.key", "*.p12", "*.pfx", "*.keystore", "*.jks",
+		"id_rsa", "id_dsa", "id_ecdsa", "id_ed25519",
+		"**/.ssh/*", "!**/.ssh/*.pub", "!**/.ssh/known_hosts",
+		"**/.aws/credentials", "**/.docker/config.json", "**/.kube/config",
+		"**/.git/config", "**/.git-credentials",
+		".netrc", ".npmrc", ".pypirc", "*.tfstate", "*.tfstate.backup",
+	},
+}
+
+// sensitivityRank orders labels so that the strictest wins when a path
+// matches more than one.
+var sensitivityRank = []types.Sensitivity{types.SensitivitySecret, types.SensitivityInternal}
+
+// LoadSensitivity reads a sensitivity map from a YAML file whose top-level
+// keys are labels and whose values are lists of path patterns.
+func LoadSensitivity(path string) (SensitivityMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sensitivity map: %w", err)
+	}
+	var m SensitivityMap
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse sensitivity map YAML: %w", err)
+	}
+	return m, nil
+}
+
+// SetSensitivity replaces the validator's sensitivity map. Unknown labels and
+// invalid patterns are errors; a nil map turns labelling off.
+func (v *Validator) SetSensitivity(m SensitivityMap) error {
+	compiled := make(map[types.Sensitivity]glob.List, len(m))
+	for label, patterns := range m {
+		if !slices.Contains(sensitivityRank, label) {
+			return fmt.Errorf("unknown sensitivity label %q", label)
+		}
+		list, err := glob.CompilePathList(patterns)
+		if err != nil {
+			return fmt.Errorf("sensitivity %s: %w", label, err)
+		}
+		compiled[label] = list
+	}
+	v.sensitivity = compiled
+	return nil
+}
+
+// Sensitivity returns the label of a path already resolved inside the
+// workspace, or the empty label when no pattern matches.
+func (v *Validator) Sensitivity(path string) types.Sensitivity {
+	if v == nil || len(v.sensitivity) == 0 {
+		return ""
+	}
+	rel, err := filepath.Rel(v.WorkspaceRoot, path)
+	if err != nil {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+	for _, label := range sensitivityRank {
+		if matched, _ := v.sensitivity[label].Match(rel); matched {
+			return label
+		}
+	}
+	return ""
+}
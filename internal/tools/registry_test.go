@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -13,6 +14,12 @@ import (
 	"bridgekeeper/internal/sandbox"
 )
 
+func TestMain(m *testing.M) {
+	// Confined subprocesses re-execute the test binary to apply Landlock.
+	sandbox.ExecConfined()
+	os.Exit(m.Run())
+}
+
 func TestReadFile(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "note.txt")
@@ -128,6 +135,7 @@ func TestRunSubprocess_Timeout(t *testing.T) {
 		t.Fatal(err)
 	}
 	registry := NewRegistry(t.TempDir(), validator)
+	t.Cleanup(func() { validator.RemoveScratch() })
 
 	_, err = registry.runSubprocess(context.Background(), subprocessSpec{
 		name:      "python3",
@@ -146,6 +154,7 @@ func TestRunSubprocess_OutputLimit(t *testing.T) {
 		t.Fatal(err)
 	}
 	registry := NewRegistry(t.TempDir(), validator)
+	t.Cleanup(func() { validator.RemoveScratch() })
 
 	_, err = registry.runSubprocess(context.Background(), subprocessSpec{
 		name:      "python3",
@@ -164,6 +173,7 @@ func TestRunSubprocess_PreservesLeadingWhitespace(t *testing.T) {
 		t.Fatal(err)
 	}
 	registry := NewRegistry(t.TempDir(), validator)
+	t.Cleanup(func() { validator.RemoveScratch() })
 
 	got, err := registry.runSubprocess(context.Background(), subprocessSpec{
 		name:      "python3",
@@ -179,6 +189,90 @@ func TestRunSubprocess_PreservesLeadingWhitespace(t *testing.T) {
 	}
 }
 
+func TestRunSubprocess_Landlock(t *testing.T) {
+	validator, err := sandbox.NewValidator(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator.ConfineSubprocesses = true
+	if validator.ConfinementABI() == 0 {
+		t.Skip("Landlock is not supported by this kernel")
+	}
+	registry := NewRegistry(validator.WorkspaceRoot, validator)
+	t.Cleanup(func() { validator.RemoveScratch() })
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	if err := os.WriteFile(outside, []byte("hunter2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	run := func(script string) (string, error) {
+		return registry.runSubprocess(context.Background(), subprocessSpec{
+			name:    "sh",
+			args:    []string{"-c", script},
+			dir:     validator.WorkspaceRoot,
+			timeout: 5 * time.Second,
+		})
+	}
+
+	if _, err := run("echo ok > inside.txt && cat inside.txt && echo tmp > \"$TMPDIR/x\""); err != nil {
+		t.Fatalf("workspace and scratch writes failed: %v", err)
+	}
+	if got, err := run("cat " + outside); err == nil || strings.Contains(got, "hunter2") {
+		t.Fatalf("read outside the workspace = %q, %v; want permission denied", got, err)
+	}
+	if _, err := run("echo x > " + outside + ".new"); err == nil {
+		t.Fatal("write outside the workspace succeeded")
+	}
+	if _, err := os.Stat(outside + ".new"); err == nil {
+		t.Fatal("file created outside the workspace")
+	}
+	// The parent's environment holds the host's credentials.
+	if got, err := run("cat /proc/$PPID/environ"); err == nil || strings.Contains(got, "PATH=") {
+		t.Fatalf("read %d bytes of the parent's environment; want permission denied", len(got))
+	}
+	if _, err := run("cat /proc/$$/status > /dev/null"); err != nil {
+		t.Fatalf("read own /proc entry failed: %v", err)
+	}
+
+	// Builds write the toolchain's caches.
+	if _, err := exec.LookPath("go"); err == nil {
+		if err := os.WriteFile(filepath.Join(validator.WorkspaceRoot, "go.mod"), []byte("module probe\n\ngo 1.21\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(validator.WorkspaceRoot, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := registry.runSubprocess(context.Background(), subprocessSpec{
+			name:    "go",
+			args:    []string{"build", "-o", os.DevNull, "."},
+			dir:     validator.WorkspaceRoot,
+			timeout: time.Minute,
+		}); err != nil {
+			t.Fatalf("confined go build failed: %v", err)
+		}
+	}
+}
+
+func TestRunSubprocess_UnconfinedByDefault(t *testing.T) {
+	validator, err := sandbox.NewValidator(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry(validator.WorkspaceRoot, validator)
+	outside := filepath.Join(t.TempDir(), "plain.txt")
+	if err := os.WriteFile(outside, []byte("visible"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := registry.runSubprocess(context.Background(), subprocessSpec{
+		name:    "cat",
+		args:    []string{outside},
+		timeout: 5 * time.Second,
+	})
+	if err != nil || got != "visible" {
+		t.Fatalf("runSubprocess() = %q, %v", got, err)
+	}
+}
+
 func TestMinimalEnv_PreservesSSHAgentVars(t *testing.T) {
 	t.Setenv("SSH_AUTH_SOCK", "/tmp/test-agent.sock")
 	t.Setenv("SSH_AGENT_PID", "1234")
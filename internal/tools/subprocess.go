@@ -11,6 +11,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"bridgekeeper/internal/sandbox"
 )
 
 type subprocessSpec struct {
@@ -47,6 +49,11 @@ func (r *Registry) runSubprocess(ctx context.Context, spec subprocessSpec) (stri
 	cmd.Dir = spec.dir
 	cmd.Env = minimalEnv(spec.allowedEnv)
 	cmd.Stdin = spec.stdin
+	if r != nil && r.Validator.ConfinementABI() > 0 {
+		if err := r.confine(cmd); err != nil {
+			return "", fmt.Errorf("%s: %w", spec.name, err)
+		}
+	}
 
 	limiter := &limitedBuffer{limitBytes: spec.maxOutput}
 	cmd.Stdout = limiter
@@ -70,6 +77,23 @@ func (r *Registry) runSubprocess(ctx context.Context, spec subprocessSpec) (stri
 	}
 }
 
+// confine rewrites cmd to run under the validator's Landlock confinement,
+// with the scratch directory as its TMPDIR and the toolchain pointed at the
+// caches it may write.
+func (r *Registry) confine(cmd *exec.Cmd) error {
+	confinement, err := r.Validator.Confinement()
+	if err != nil {
+		return err
+	}
+	scratch, err := r.Validator.ScratchDir()
+	if err != nil {
+		return err
+	}
+	cmd.Env = append(cmd.Env, "TMPDIR="+scratch)
+	cmd.Env = append(cmd.Env, sandbox.ToolchainEnv()...)
+	return sandbox.ConfineCommand(cmd, confinement)
+}
+
 func minimalEnv(allowlist []string) []string {
 	extras := []string{"GIT_TERMINAL_PROMPT=0", "GIT_CONFIG_NOSYSTEM=1"}
 
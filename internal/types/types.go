@@ -1,11 +1,42 @@
 package types
 
+import "time"
+
 // ToolCall represents a request from the LLM to execute a specific tool.
 type ToolCall struct {
 	ID     string         `json:"id,omitempty"`   // e.g., "line" or a genai ID
 	Tool   string         `json:"tool"`           // e.g., "git"
 	Action string         `json:"action"`         // e.g., "execute_git_command"
 	Args   map[string]any `json:"args,omitempty"` // The arguments passed to the tool
+
+	// Sensitivity is the label the sandbox gave the path the call touches.
+	// It is never read from the wire, so a caller cannot claim a file is
+	// harmless.
+	Sensitivity Sensitivity `json:"-"`
+}
+
+// Sensitivity labels a path whose contents are sensitive regardless of what
+// they look like. The empty label means nothing is known about the path.
+type Sensitivity string
+
+const (
+	SensitivitySecret   Sensitivity = "secret"   // credentials and keys; the output is withheld from the model
+	SensitivityInternal Sensitivity = "internal" // not for sharing, but safe to show the model
+)
+
+// Principal identifies who is acting on behalf of a tool call: the human user,
+// the agent driving the session, the session itself, and any roles granted to
+// the user.
+type Principal struct {
+	User    string   `json:"user,omitempty"`
+	Agent   string   `json:"agent,omitempty"`
+	Session string   `json:"session,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+}
+
+// IsZero reports whether no principal information is set.
+func (p Principal) IsZero() bool {
+	return p.User == "" && p.Agent == "" && p.Session == "" && len(p.Roles) == 0
 }
 
 // Decision represents the outcome of a policy evaluation.
@@ -19,9 +50,81 @@ const (
 
 // PolicyDecision represents the final evaluated result.
 type PolicyDecision struct {
-	Decision Decision `json:"decision"`
-	Reason   string   `json:"reason"`
-	Rule     string   `json:"rule"`
+	Decision  Decision           `json:"decision"`
+	Reason    string             `json:"reason"`
+	Rule      string             `json:"rule"`
+	Approval  *ApprovalSettings  `json:"approval,omitempty"`  // set on ask decisions with approval settings
+	PII       PIIMode            `json:"pii,omitempty"`       // handling of personal data in the tool's output
+	Injection *InjectionSettings `json:"injection,omitempty"` // handling of suspected prompt injection in the output
+}
+
+// InjectionAction says what happens to tool output that scores at or above
+// the injection threshold.
+type InjectionAction string
+
+const (
+	InjectionAnnotate   InjectionAction = "annotate"   // prefix the output with a warning
+	InjectionQuarantine InjectionAction = "quarantine" // wrap the output in a delimiter marking it as data
+	InjectionStrip      InjectionAction = "strip"      // remove the markers found
+	InjectionDeny       InjectionAction = "deny"       // withhold the output
+)
+
+// InjectionSettings configures the output inspector for one capability.
+type InjectionSettings struct {
+	Action    InjectionAction `json:"action"`
+	Threshold float64         `json:"threshold"` // score, from 0 to 1, at which Action applies
+}
+
+// PIIMode says what happens to personal data, such as email addresses or card
+// numbers, found in a tool's output before it is handed to the model. The
+// empty mode is PIIMask.
+type PIIMode string
+
+const (
+	PIIMask  PIIMode = "mask"  // replace each item with a placeholder
+	PIIHash  PIIMode = "hash"  // replace each item with a keyed hash, so repeats can be correlated
+	PIIBlock PIIMode = "block" // withhold the whole output
+	PIIAllow PIIMode = "allow" // pass personal data through; secrets are still masked
+)
+
+// ApprovalSettings tells the mediator and approvers how an ask decision must be
+// resolved. The zero value means a single approver, no justification, and no
+// timeout.
+type ApprovalSettings struct {
+	TimeoutSeconds       int      `json:"timeout_seconds,omitempty"`
+	OnTimeout            Decision `json:"on_timeout,omitempty"` // always deny
+	RequiredApprovers    int      `json:"required_approvers,omitempty"`
+	RequireJustification bool     `json:"require_justification,omitempty"`
+}
+
+// ApprovalResult is an approver's answer to an ask decision. Args, when set,
+// replaces the call's arguments: the approver said "yes, but like this". The
+// mediator re-validates and re-evaluates an edited call before running it.
+type ApprovalResult struct {
+	Approved      bool             `json:"approved"`
+	Args          map[string]any   `json:"args,omitempty"`
+	Approver      string           `json:"approver,omitempty"` // who answered, when known
+	Comment       string           `json:"comment,omitempty"`
+	Justification string           `json:"justification,omitempty"`
+	Votes         []Vote           `json:"votes,omitempty"` // individual votes, when several approvers answered
+	Remember      *RememberRequest `json:"remember,omitempty"`
+}
+
+// RememberRequest asks the mediator to keep an approval across sessions, so
+// the same call is not asked about again until it expires.
+type RememberRequest struct {
+	For      time.Duration     `json:"for"`
+	Patterns map[string]string `json:"patterns,omitempty"` // argument globs; other arguments must match exactly
+}
+
+// Vote is one approver's answer within a multi-party approval.
+type Vote struct {
+	Member   string    `json:"member"`             // the approver channel, e.g. "terminal" or "webhook"
+	Approver string    `json:"approver,omitempty"` // who answered on that channel, when known
+	Approved bool      `json:"approved"`
+	Comment  string    `json:"comment,omitempty"`
+	Error    string    `json:"error,omitempty"` // set when the channel failed instead of answering
+	Time     time.Time `json:"time"`
 }
 
 // JSONRPCRequest represents a standard JSON-RPC request wrapper.